@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"github.com/xissg/userManageSystem/core/judge"
+	"github.com/xissg/userManageSystem/core/sanbox"
+	"github.com/xissg/userManageSystem/service/mysql"
+	"github.com/xissg/userManageSystem/service/rabbitmq"
+	"github.com/xissg/userManageSystem/service/redis"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// judge-worker 独立的判题进程，只连接mysql、判题队列和发布判题进度的redis，可以与 API 服务分开部署和扩容
+// 收到 SIGINT 或 SIGTERM 后停止接收新的提交，等待正在进行的判题完成后退出，再次收到信号时立即退出
+func main() {
+	concurrency := flag.Int("concurrency", rabbitmq.Prefetch(), "number of submissions judged at the same time")
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		log.Println("shutting down, waiting for running judgements")
+		//恢复默认的信号处理，再次收到信号时立即退出
+		stop()
+	}()
+
+	judgeQueue, err := rabbitmq.NewJudgeQueue()
+	if err != nil {
+		log.Fatalf("connect judge queue: %v", err)
+	}
+	qsService := mysql.NewQuestionSubmitMysqlService()
+	judgeService := judge.NewJudgeService(mysql.NewQuestionMysqlService(), qsService, redis.NewProgressBroker())
+
+	//恢复崩溃或重启后卡住的提交
+	if config := judge.LoadReaperConfig(); config.Enabled {
+		go judge.NewReaper(qsService, judgeQueue, config).Run(ctx)
+	}
+
+	log.Printf("judge worker started, concurrency %d", *concurrency)
+	err = judge.NewWorker(judgeQueue, judgeService, *concurrency, rabbitmq.LoadRetryPolicy()).Run(ctx)
+
+	//判题全部结束后再关闭队列，保证处理中的消息都已确认
+	if err := sanbox.Close(); err != nil {
+		log.Printf("close sandbox: %v", err)
+	}
+	if err := judgeQueue.Close(); err != nil {
+		log.Printf("close judge queue: %v", err)
+	}
+	if err != nil {
+		log.Fatalf("judge worker stopped: %v", err)
+	}
+	log.Println("judge worker stopped")
+}
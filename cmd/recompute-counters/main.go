@@ -0,0 +1,15 @@
+package main
+
+import (
+	"github.com/xissg/userManageSystem/service/mysql"
+	"log"
+)
+
+// recompute-counters 根据 question_submit 重新统计所有题目的提交数、通过数和通过人数
+// 计数在提交和判题时增量更新，进程崩溃或手动修改数据后可以运行该命令修正
+func main() {
+	if err := mysql.NewQuestionMysqlService().RecomputeCounters(); err != nil {
+		log.Fatalf("recompute question counters: %v", err)
+	}
+	log.Println("question counters recomputed")
+}
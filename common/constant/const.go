@@ -12,3 +12,6 @@ const (
 	Ban       = "ban"
 	Anonymous = ""
 )
+
+// ContextUser 通过 Bearer 令牌认证的用户信息在 gin.Context 中的键
+const ContextUser = "token_user"
@@ -8,6 +8,7 @@ const (
 	MemoryLimitExceeded = "Memory Limit Exceeded"
 	TimeLimitExceeded   = "Time Limit Exceeded"
 	RuntimeError        = "Runtime Error"
+	OutputLimitExceeded = "Output Limit Exceeded"
 	SystemError         = "System Error"
 )
 
@@ -27,3 +28,30 @@ const (
 	Cpp    = "cpp"
 	Go     = "go"
 )
+
+// 判题用例的输入方式
+const (
+	InputStdin = "stdin" //通过标准输入传给程序，需要在判题配置中显式指定
+	InputArgs  = "args"  //按空格拆分后作为命令行参数，未指定时的方式，兼容旧题目
+)
+
+// 判题配置中 compare_mode 的值，用户输出与答案的比较方式
+const (
+	CompareExact      = "exact"      //完全一致，默认方式
+	CompareLine       = "line"       //逐行比较，忽略行尾空白和末尾空行
+	CompareWhitespace = "whitespace" //按空白拆分后逐个比较，忽略空白的数量和换行
+	CompareFloat      = "float"      //按空白拆分后逐个比较，数字允许 epsilon 的误差
+	CompareChecker    = "checker"    //由题目上传的判题程序决定
+)
+
+// 判题配置中 mode 的值，题目的判题方式
+const (
+	ModeStandard    = "standard"    //用例输入传给程序后比较输出，默认方式
+	ModeInteractive = "interactive" //程序与题目上传的交互程序通过管道交互，由交互程序决定结果
+)
+
+// 判题配置中 subtask_rule 的值，子任务的计分方式
+const (
+	SubtaskSum = "sum" //子任务得分为通过用例的分数之和，默认方式
+	SubtaskMin = "min" //子任务得分为各用例得分的最小值，有用例未通过时不得分
+)
@@ -0,0 +1,37 @@
+package constant
+
+// 权限，角色拥有的权限保存在 role_permission 表中，通过 rbac.Authorizer.Can 检查
+const (
+	PermQuestionView       = "question:view"        //查看题目
+	PermQuestionWrite      = "question:write"       //添加、修改、删除题目
+	PermQuestionViewAnswer = "question:view_answer" //查看题目答案
+	PermSubmitCreate       = "submit:create"        //提交代码
+	PermSubmitViewAll      = "submit:view_all"      //查看其他用户的提交及判题详情
+	PermSubmitRequeue      = "submit:requeue"       //查看死信队列并重新判题
+	PermUserView           = "user:view"            //后台查询用户
+	PermUserEdit           = "user:edit"            //后台修改、删除用户
+	PermUserBan            = "user:ban"             //禁用、解禁用户
+	PermRoleManage         = "role:manage"          //为用户分配、撤销角色
+)
+
+// Permissions 所有权限
+var Permissions = []string{
+	PermQuestionView,
+	PermQuestionWrite,
+	PermQuestionViewAnswer,
+	PermSubmitCreate,
+	PermSubmitViewAll,
+	PermSubmitRequeue,
+	PermUserView,
+	PermUserEdit,
+	PermUserBan,
+	PermRoleManage,
+}
+
+// 内置的附加角色，用户的 user_role 字段仍然是 Common、Admin、Ban 之一，附加角色记录在 user_role 表中
+const (
+	ProblemSetter = "problem-setter"
+	Moderator     = "moderator"
+	JudgeOperator = "judge-operator"
+	Auditor       = "auditor"
+)
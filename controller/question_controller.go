@@ -1,13 +1,16 @@
 package controller
 
 import (
+	"encoding/json"
 	"errors"
 	"github.com/gin-gonic/gin"
 	"github.com/xissg/userManageSystem/common/api_response"
 	"github.com/xissg/userManageSystem/common/constant"
+	"github.com/xissg/userManageSystem/core/sanbox"
 	"github.com/xissg/userManageSystem/entity/model_question"
+	"github.com/xissg/userManageSystem/middleware"
 	mysql2 "github.com/xissg/userManageSystem/service/mysql"
-	"github.com/xissg/userManageSystem/service/redis"
+	"github.com/xissg/userManageSystem/service/rbac"
 	"log"
 	"net/http"
 )
@@ -16,13 +19,14 @@ import (
 
 type QuestionController struct {
 	questionService *mysql2.QuestionService
-	session         *redis.SessionService
+	authz           *rbac.Authorizer
 }
 
-func NewQuestionController(questionService *mysql2.QuestionService, session *redis.SessionService) *QuestionController {
+// NewQuestionController 接口的访问权限由路由上的 middleware.RequirePermission 检查，authz 用于决定是否返回答案
+func NewQuestionController(questionService *mysql2.QuestionService, authz *rbac.Authorizer) *QuestionController {
 	return &QuestionController{
 		questionService: questionService,
-		session:         session,
+		authz:           authz,
 	}
 }
 
@@ -38,15 +42,6 @@ func NewQuestionController(questionService *mysql2.QuestionService, session *red
 //	@Failure		400			{object}	api_response.ApiResponse{data=nil}			"Add  question fail"
 //	@Router			/api/question/admin/add    [post]
 func (qc *QuestionController) AddQuestion(c *gin.Context) {
-
-	session, _ := qc.session.GetSession(c)
-	if session.UserRole != constant.Admin {
-		log.Printf("you are not allowed to create a new question")
-		c.JSON(http.StatusBadRequest, api_response.NewResponse(nil, "you are not allowed to create a new question").Response(api_response.AUTHERR))
-
-		return
-	}
-
 	var receiveQuestion model_question.AddQuestionRequest
 	//反序列化取出JSON数据
 	if err := c.ShouldBindJSON(&receiveQuestion); err != nil {
@@ -65,6 +60,13 @@ func (qc *QuestionController) AddQuestion(c *gin.Context) {
 
 		return
 	}
+	err = qc.checkJudgeSettings(add)
+	if err != nil {
+		log.Printf("validate %v", err)
+		c.JSON(http.StatusBadRequest, api_response.NewResponse(nil, err.Error()).Response(api_response.OPERATIONERR))
+
+		return
+	}
 	question := model_question.AddQuestionToQuestion(receiveQuestion)
 	err = qc.questionService.AddQuestion(question)
 	result := model_question.QuestionToReturnQuestion(question)
@@ -90,13 +92,7 @@ func (qc *QuestionController) AddQuestion(c *gin.Context) {
 //	@Failure		400	{object}	api_response.ApiResponse{data=nil}								"Query question fail"
 //	@Router			/api/question/query    [get]
 func (qc *QuestionController) GetQuestion(c *gin.Context) {
-	session, err := qc.session.GetSession(c)
-	if session.UserRole != constant.Common && session.UserRole != constant.Admin {
-		log.Printf("you are not allowed to get a new question")
-		c.JSON(http.StatusBadRequest, api_response.NewResponse(nil, "you are not allowed to get a new question").Response(api_response.AUTHERR))
-
-		return
-	}
+	session := middleware.CurrentUser(c)
 	id := c.Param("id")
 	question, err := qc.questionService.GetQuestion(id)
 	if err != nil {
@@ -105,7 +101,7 @@ func (qc *QuestionController) GetQuestion(c *gin.Context) {
 		return
 	}
 	res := model_question.QuestionToReturnQuestion(question)
-	if session.UserRole != constant.Admin {
+	if !qc.authz.Can(session, constant.PermQuestionViewAnswer) {
 		res.Answer = nil
 	}
 	log.Printf("query model_question success")
@@ -124,13 +120,7 @@ func (qc *QuestionController) GetQuestion(c *gin.Context) {
 //	@Failure		400	{object}	api_response.ApiResponse{data=nil}								"Get question list failed"
 //	@Router			/api/question/query    [post]
 func (qc *QuestionController) GetQuestionList(c *gin.Context) {
-	session, _ := qc.session.GetSession(c)
-	if session.UserRole != constant.Admin && session.UserRole != constant.Common {
-		log.Printf("you are not allowed to get questions")
-		c.JSON(http.StatusBadRequest, api_response.NewResponse(nil, "you are not allowed to create get questions ").Response(api_response.AUTHERR))
-
-		return
-	}
+	session := middleware.CurrentUser(c)
 	var receiveQuestion model_question.QueryQuestionRequest
 	//反序列化取出JSON数据
 	if err := c.ShouldBindJSON(&receiveQuestion); err != nil {
@@ -173,7 +163,7 @@ func (qc *QuestionController) GetQuestionList(c *gin.Context) {
 	}
 	res := model_question.QuestionsToReturnQuestions(questionList)
 
-	if session.UserRole != constant.Admin {
+	if !qc.authz.Can(session, constant.PermQuestionViewAnswer) {
 		for _, v := range res {
 			v.Answer = nil
 		}
@@ -195,13 +185,6 @@ func (qc *QuestionController) GetQuestionList(c *gin.Context) {
 //	@Failure		400			{object}	api_response.ApiResponse{data=nil}			"Update fail"
 //	@Router			/api/question/admin/update    [post]
 func (qc *QuestionController) UpdateQuestion(c *gin.Context) {
-	session, _ := qc.session.GetSession(c)
-	if session.UserRole != constant.Admin {
-		log.Printf("you are not allowed to update a new question")
-		c.JSON(http.StatusBadRequest, api_response.NewResponse(nil, "you are not allowed to update a new question").Response(api_response.AUTHERR))
-
-		return
-	}
 	var receiveQuestion model_question.UpdateQuestionRequest
 
 	//反序列化取出JSON数据
@@ -232,6 +215,13 @@ func (qc *QuestionController) UpdateQuestion(c *gin.Context) {
 	}
 
 	question := model_question.UpdateQuestionToQuestion(queryQuestion, receiveQuestion)
+	err = qc.checkJudgeSettings(question)
+	if err != nil {
+		log.Printf("validate %v", err)
+		c.JSON(http.StatusBadRequest, api_response.NewResponse(nil, err.Error()).Response(api_response.OPERATIONERR))
+
+		return
+	}
 	err = qc.questionService.UpdateQuestion(question)
 	if err != nil {
 		log.Printf("update model_question %v", err)
@@ -255,14 +245,6 @@ func (qc *QuestionController) UpdateQuestion(c *gin.Context) {
 //	@Failure		400	{object}	api_response.ApiResponse{data=nil}	"Delete fail"
 //	@Router			/api/question/admin/delete    [get]
 func (qc *QuestionController) DeleteQuestion(c *gin.Context) {
-	session, _ := qc.session.GetSession(c)
-	if session.UserRole != constant.Admin {
-		log.Printf("you are not allowed to delete a question")
-		c.JSON(http.StatusBadRequest, api_response.NewResponse(nil, "you are not allowed to delete a question").Response(api_response.AUTHERR))
-
-		return
-	}
-
 	id := c.Param("id")
 	if id == "" {
 		log.Printf("id is empty")
@@ -298,6 +280,85 @@ func (qc *QuestionController) checkQuestion(question model_question.Question) er
 	return nil
 }
 
+// checkJudgeSettings 校验比较方式、判题方式和计分方式，使用判题程序或交互程序时必须上传支持的语言编写的程序
+func (qc *QuestionController) checkJudgeSettings(question model_question.Question) error {
+	var judgeConfig model_question.JudgeConfig
+	if question.JudgeConfig != "" {
+		if err := json.Unmarshal([]byte(question.JudgeConfig), &judgeConfig); err != nil {
+			return errors.New("invalid judge config")
+		}
+	}
+	switch judgeConfig.CompareMode {
+	case "", constant.CompareExact, constant.CompareLine, constant.CompareWhitespace, constant.CompareFloat, constant.CompareChecker:
+	default:
+		return errors.New("unsupported compare mode")
+	}
+	if judgeConfig.Epsilon < 0 {
+		return errors.New("epsilon must not be negative")
+	}
+
+	switch judgeConfig.Mode {
+	case "", constant.ModeStandard, constant.ModeInteractive:
+	default:
+		return errors.New("unsupported judge mode")
+	}
+
+	switch judgeConfig.InputMode {
+	case "", constant.InputStdin, constant.InputArgs:
+	default:
+		return errors.New("unsupported input mode")
+	}
+
+	switch judgeConfig.SubtaskRule {
+	case "", constant.SubtaskSum, constant.SubtaskMin:
+	default:
+		return errors.New("unsupported subtask rule")
+	}
+	var judgeCases []model_question.JudgeCase
+	if question.JudgeCase != "" {
+		if err := json.Unmarshal([]byte(question.JudgeCase), &judgeCases); err != nil {
+			return errors.New("invalid judge case")
+		}
+	}
+	for _, judgeCase := range judgeCases {
+		if judgeCase.Score < 0 || judgeCase.Subtask < 0 {
+			return errors.New("score and subtask must not be negative")
+		}
+	}
+
+	if question.Checker == "" && judgeConfig.CompareMode == constant.CompareChecker {
+		return errors.New("checker is required")
+	}
+	if question.Interactor == "" && judgeConfig.Mode == constant.ModeInteractive {
+		return errors.New("interactor is required")
+	}
+	if err := checkProgram("checker", question.Checker); err != nil {
+		return err
+	}
+	return checkProgram("interactor", question.Interactor)
+}
+
+// checkProgram 校验题目上传的判题程序或交互程序，为空时不校验
+func checkProgram(name string, program string) error {
+	if program == "" {
+		return nil
+	}
+	if len(program) > 65536 {
+		return errors.New(name + " is too long")
+	}
+	var checker model_question.Checker
+	if err := json.Unmarshal([]byte(program), &checker); err != nil {
+		return errors.New("invalid " + name)
+	}
+	if checker.Code == "" {
+		return errors.New(name + " code is empty")
+	}
+	if _, err := sanbox.GetToolchain(checker.Language); err != nil {
+		return err
+	}
+	return nil
+}
+
 func (qc *QuestionController) checkQueryOrUpdateQuestion(question model_question.Question) error {
 	if question.ID != "" && len(question.ID) > 256 {
 		return errors.New("id is too long")
@@ -323,7 +384,7 @@ func (qc *QuestionController) checkQueryOrUpdateQuestion(question model_question
 	if question.UserId != "" && len(question.UserId) > 256 {
 		return errors.New("user id is too long")
 	}
-	if question.JudgeConfig != "" && len(question.JudgeConfig) > 64 {
+	if question.JudgeConfig != "" && len(question.JudgeConfig) > 1024 {
 		return errors.New("judge config is  too long")
 	}
 	return nil
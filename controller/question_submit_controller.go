@@ -5,27 +5,39 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/xissg/userManageSystem/common/api_response"
 	"github.com/xissg/userManageSystem/common/constant"
-	"github.com/xissg/userManageSystem/core/judge"
+	"github.com/xissg/userManageSystem/core/sanbox"
 	"github.com/xissg/userManageSystem/entity/model_question"
+	"github.com/xissg/userManageSystem/middleware"
 	"github.com/xissg/userManageSystem/service/mysql"
-	"github.com/xissg/userManageSystem/service/redis"
+	"github.com/xissg/userManageSystem/service/progress"
+	"github.com/xissg/userManageSystem/service/queue"
+	"github.com/xissg/userManageSystem/service/rbac"
+	"io"
 	"log"
 	"net/http"
 	"strings"
-	"sync"
+	"time"
 )
 
+// progressHeartbeat 判题进度流没有事件时发送心跳的间隔，避免代理断开空闲连接
+const progressHeartbeat = 15 * time.Second
+
 type QuestionSubmitController struct {
 	qsService       *mysql.QuestionSubmitService
 	questionService *mysql.QuestionService
-	sessionService  *redis.SessionService
+	judgeQueue      queue.Queue
+	progress        progress.Broker
+	authz           *rbac.Authorizer
 }
 
-func NewQuestionSubmitController(qsService *mysql.QuestionSubmitService, questionService *mysql.QuestionService, sessionService *redis.SessionService) *QuestionSubmitController {
+// NewQuestionSubmitController 提交保存后将提交id发送到 judgeQueue，由判题进程异步判题，判题进度通过 broker 推送给提交者
+func NewQuestionSubmitController(qsService *mysql.QuestionSubmitService, questionService *mysql.QuestionService, judgeQueue queue.Queue, broker progress.Broker, authz *rbac.Authorizer) *QuestionSubmitController {
 	return &QuestionSubmitController{
 		qsService:       qsService,
 		questionService: questionService,
-		sessionService:  sessionService,
+		judgeQueue:      judgeQueue,
+		progress:        broker,
+		authz:           authz,
 	}
 }
 
@@ -41,18 +53,12 @@ func NewQuestionSubmitController(qsService *mysql.QuestionSubmitService, questio
 //	@Failure		400			{object}	api_response.ApiResponse{data=nil}		"Submit failed"
 //	@Router			/api/submit/add    [post]
 func (qsc *QuestionSubmitController) Submit(c *gin.Context) {
-	//用户身份校验
-	session, err := qsc.sessionService.GetSession(c)
-	if session.UserRole != constant.Common && session.UserRole != constant.Admin {
-		log.Printf("you are not login")
-		c.JSON(http.StatusBadRequest, api_response.NewResponse(nil, "you are not login").Response(api_response.AUTHERR))
-
-		return
-	}
+	//当前用户，登录和角色由路由上的中间件检查
+	session := middleware.CurrentUser(c)
 
 	var qsAdd model_question.AddQuestionSubmitRequest
 	//取出数据
-	if err = c.ShouldBindJSON(&qsAdd); err != nil {
+	if err := c.ShouldBindJSON(&qsAdd); err != nil {
 		log.Printf("Failed to unmarshal")
 		c.JSON(http.StatusBadRequest, api_response.NewResponse(nil, "unmarshal error ").Response(api_response.OPERATIONERR))
 
@@ -68,13 +74,20 @@ func (qsc *QuestionSubmitController) Submit(c *gin.Context) {
 		return
 	}
 
+	//校验题目是否存在
+	if _, err := qsc.questionService.GetQuestion(qsAdd.QuestionId); err != nil {
+		log.Printf("query question %v", err)
+		c.JSON(http.StatusBadRequest, api_response.NewResponse(nil, "question not found").Response(api_response.PARAMSERR))
+
+		return
+	}
+
 	//转换成数据中的存储类型
 	questionSubmit := model_question.AddQSToQS(qsAdd)
-	//获取用户id
-	question, _ := qsc.questionService.GetQuestion(qsAdd.QuestionId)
-	questionSubmit.UserId = question.UserId
+	//记录提交用户的id
+	questionSubmit.UserId = session.ID
 
-	err = qsc.qsService.AddSubmitQuestion(questionSubmit)
+	err := qsc.qsService.AddSubmitQuestion(questionSubmit)
 	if err != nil {
 		log.Printf("Failed to submit")
 		c.JSON(http.StatusBadRequest, api_response.NewResponse(nil, "submit error ").Response(api_response.OPERATIONERR))
@@ -82,19 +95,24 @@ func (qsc *QuestionSubmitController) Submit(c *gin.Context) {
 		return
 	}
 
-	log.Printf("submit success")
-	c.JSON(http.StatusOK, api_response.NewResponse(questionSubmit.ID, "submit success").Response(api_response.SUCCESS))
+	//提交数只用于展示，更新失败时由重新统计命令修正
+	if err = qsc.questionService.IncrSubmitNum(questionSubmit.QuestionId); err != nil {
+		log.Printf("increase submit num of question %s: %v", questionSubmit.QuestionId, err)
+	}
 
-	//使用消息队列发送信息
-	var wg sync.WaitGroup
-	wg.Add(1)
-	go func(string) {
-		judging := judge.NewJudgeService(qsc.questionService, qsc.qsService)
-		judging.Judge(questionSubmit.ID)
-		wg.Done()
-	}(questionSubmit.ID)
+	//使用消息队列发送提交id，由判题进程异步判题，客户端通过查询接口获取结果
+	err = qsc.judgeQueue.Publish(c.Request.Context(), questionSubmit.ID)
+	if err != nil {
+		log.Printf("publish submit %s: %v", questionSubmit.ID, err)
+		c.JSON(http.StatusBadRequest, api_response.NewResponse(nil, "judge queue unavailable").Response(api_response.OPERATIONERR))
+
+		return
+	}
 
-	wg.Wait()
+	qsc.publishQueued(c, questionSubmit.ID)
+
+	log.Printf("submit success")
+	c.JSON(http.StatusOK, api_response.NewResponse(questionSubmit.ID, "submit success").Response(api_response.SUCCESS))
 }
 
 // GetQuestionSubmit
@@ -109,14 +127,8 @@ func (qsc *QuestionSubmitController) Submit(c *gin.Context) {
 //	@Failure		400		{object}	api_response.ApiResponse{data=nil}							"Query fail"
 //	@Router			/api/submit/query [get]
 func (qsc *QuestionSubmitController) GetQuestionSubmit(c *gin.Context) {
-	//用户身份校验
-	session, err := qsc.sessionService.GetSession(c)
-	if session.UserRole != constant.Common && session.UserRole != constant.Admin {
-		log.Printf("you are not login")
-		c.JSON(http.StatusBadRequest, api_response.NewResponse(nil, "you are not login").Response(api_response.AUTHERR))
-
-		return
-	}
+	//当前用户，登录和角色由路由上的中间件检查
+	session := middleware.CurrentUser(c)
 
 	id := c.Param("id")
 	if id == "" {
@@ -126,6 +138,11 @@ func (qsc *QuestionSubmitController) GetQuestionSubmit(c *gin.Context) {
 		return
 	}
 	submit, err := qsc.qsService.GetSubmitQuestion(id)
+	if err != nil {
+		log.Printf("Failed to get submit result %v", err)
+		c.JSON(http.StatusBadRequest, api_response.NewResponse(nil, "get submit result error ").Response(api_response.OPERATIONERR))
+		return
+	}
 	question, err := qsc.questionService.GetQuestion(submit.QuestionId)
 	if err != nil {
 		log.Printf("Failed to get submit result")
@@ -133,7 +150,12 @@ func (qsc *QuestionSubmitController) GetQuestionSubmit(c *gin.Context) {
 		return
 	}
 
-	if session.UserRole != constant.Admin && question.UserId != submit.UserId {
+	//编译信息包含用户代码的内容，只返回给提交者本人和管理员
+	if session.ID != submit.UserId && !qsc.authz.Can(session, constant.PermSubmitViewAll) {
+		submit.JudgeInfo = model_question.HideJudgeDetail(submit.JudgeInfo)
+	}
+
+	if question.UserId != submit.UserId && !qsc.authz.Can(session, constant.PermQuestionViewAnswer) {
 		result := model_question.QSToReturnQS(submit, "")
 		log.Printf("get submit result success")
 		c.JSON(http.StatusBadRequest, api_response.NewResponse(result, "get submit result success").Response(api_response.SUCCESS))
@@ -146,6 +168,101 @@ func (qsc *QuestionSubmitController) GetQuestionSubmit(c *gin.Context) {
 	return
 }
 
+// SubmitProgress 推送判题进度
+//
+//	@Summary		Stream judge progress
+//	@Description	Server-sent events with the judge progress of a submission, the stream ends after the finished event
+//	@Tags			QuestionSubmit
+//	@Produce		text/event-stream
+//	@Param			id	path		string								true	"Submit id"
+//	@Success		200	{object}	progress.Event						"Progress events"
+//	@Failure		400	{object}	api_response.ApiResponse{data=nil}	"Subscribe fail"
+//	@Router			/api/submit/progress/{id} [get]
+func (qsc *QuestionSubmitController) SubmitProgress(c *gin.Context) {
+	//当前用户，登录和角色由路由上的中间件检查
+	session := middleware.CurrentUser(c)
+
+	id := c.Param("id")
+	if id == "" || len(id) > 256 {
+		log.Printf("invalid id")
+		c.JSON(http.StatusBadRequest, api_response.NewResponse(nil, "invalid id ").Response(api_response.PARAMSERR))
+
+		return
+	}
+	submit, err := qsc.qsService.GetSubmitQuestion(id)
+	if err != nil {
+		log.Printf("Failed to get submit %v", err)
+		c.JSON(http.StatusBadRequest, api_response.NewResponse(nil, "get submit error ").Response(api_response.OPERATIONERR))
+		return
+	}
+
+	//进度包含编译信息等详细结果，只推送给提交者本人和管理员
+	if session.ID != submit.UserId && !qsc.authz.Can(session, constant.PermSubmitViewAll) {
+		log.Printf("you are not the submitter")
+		c.JSON(http.StatusBadRequest, api_response.NewResponse(nil, "you are not the submitter").Response(api_response.AUTHERR))
+		return
+	}
+
+	//先订阅再读取当前状态，读取期间产生的事件不会丢失
+	events, err := qsc.progress.Subscribe(c.Request.Context(), id)
+	if err != nil {
+		log.Printf("subscribe progress %v", err)
+		c.JSON(http.StatusBadRequest, api_response.NewResponse(nil, "subscribe progress error").Response(api_response.OPERATIONERR))
+		return
+	}
+	submit, err = qsc.qsService.GetSubmitQuestion(id)
+	if err != nil {
+		log.Printf("Failed to get submit %v", err)
+		c.JSON(http.StatusBadRequest, api_response.NewResponse(nil, "get submit error ").Response(api_response.OPERATIONERR))
+		return
+	}
+
+	heartbeat := time.NewTicker(progressHeartbeat)
+	defer heartbeat.Stop()
+	current := submitEvent(submit)
+	c.SSEvent("progress", current)
+	if current.Stage == progress.StageFinished {
+		return
+	}
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return false
+			}
+			c.SSEvent("progress", event)
+			return event.Stage != progress.StageFinished
+		case <-heartbeat.C:
+			c.SSEvent("heartbeat", "")
+			return true
+		}
+	})
+}
+
+// submitEvent 根据保存的状态生成当前的进度
+func submitEvent(submit model_question.QuestionSubmit) progress.Event {
+	event := progress.Event{SubmitId: submit.ID, Status: submit.Status}
+	switch submit.Status {
+	case constant.WAITING:
+		event.Stage = progress.StageQueued
+	case constant.JUDGING:
+		event.Stage = progress.StageJudging
+	default:
+		event.Stage = progress.StageFinished
+		event.JudgeInfo = model_question.QSToReturnQS(submit, "").JudgeInfo
+		event.Summary = &submit.JudgeSummary
+	}
+	return event
+}
+
+// publishQueued 提交进入判题队列，进度只用于展示，发送失败不影响提交
+func (qsc *QuestionSubmitController) publishQueued(c *gin.Context, id string) {
+	err := qsc.progress.Publish(c.Request.Context(), progress.Event{SubmitId: id, Stage: progress.StageQueued})
+	if err != nil {
+		log.Printf("publish progress of submit %s: %v", id, err)
+	}
+}
+
 // GetQuestionSubmitList 获取代码结果
 //
 //	@Summary		Get question submit list
@@ -158,24 +275,9 @@ func (qsc *QuestionSubmitController) GetQuestionSubmit(c *gin.Context) {
 //	@Failure		400		{object}	api_response.ApiResponse{data=nil}							"Query fail"
 //	@Router			/api/submit/query [post]
 func (qsc *QuestionSubmitController) GetQuestionSubmitList(c *gin.Context) {
-	//用户身份校验
-	session, err := qsc.sessionService.GetSession(c)
-	if err != nil {
-		log.Printf("you are not login")
-		c.JSON(http.StatusBadRequest, api_response.NewResponse(nil, "you are not login").Response(api_response.AUTHERR))
-
-		return
-	}
-	if session.UserRole != constant.Admin {
-		log.Printf("you are not admin")
-		c.JSON(http.StatusBadRequest, api_response.NewResponse(nil, "you are not admin").Response(api_response.AUTHERR))
-
-		return
-	}
-
 	var qsQuery model_question.QueryQuestionSubmitRequest
 	//取出数据
-	if err = c.ShouldBindJSON(&qsQuery); err != nil {
+	if err := c.ShouldBindJSON(&qsQuery); err != nil {
 		log.Printf("Failed to unmarshal")
 		c.JSON(http.StatusBadRequest, api_response.NewResponse(nil, "unmarshal error ").Response(api_response.OPERATIONERR))
 
@@ -183,7 +285,7 @@ func (qsc *QuestionSubmitController) GetQuestionSubmitList(c *gin.Context) {
 	}
 
 	//校验数据
-	err = qsc.checkQueries(qsQuery)
+	err := qsc.checkQueries(qsQuery)
 	if err != nil {
 		log.Printf("invalid queries %v", err)
 		c.JSON(http.StatusBadRequest, api_response.NewResponse(nil, err.Error()).Response(api_response.PARAMSERR))
@@ -207,14 +309,114 @@ func (qsc *QuestionSubmitController) GetQuestionSubmitList(c *gin.Context) {
 	c.JSON(http.StatusOK, api_response.NewResponse(qsReturns, "get submit result success").Response(api_response.SUCCESS))
 }
 
+// GetDeadSubmits 重试次数用完的提交
+//
+//	@Summary		Get dead letter submits
+//	@Description	List ids of submissions that failed with System Error after all retries
+//	@Tags			QuestionSubmit
+//	@Produce		json
+//	@Success		200	{object}	api_response.ApiResponse{data=[]string}	"Query success"
+//	@Failure		400	{object}	api_response.ApiResponse{data=nil}		"Query fail"
+//	@Router			/api/submit/admin/dead [get]
+func (qsc *QuestionSubmitController) GetDeadSubmits(c *gin.Context) {
+	ids, err := qsc.judgeQueue.Dead(c.Request.Context())
+	if err != nil {
+		log.Printf("list dead letter submits %v", err)
+		c.JSON(http.StatusBadRequest, api_response.NewResponse(nil, "judge queue unavailable").Response(api_response.OPERATIONERR))
+		return
+	}
+
+	log.Printf("get dead letter submits success")
+	c.JSON(http.StatusOK, api_response.NewResponse(ids, "get dead letter submits success").Response(api_response.SUCCESS))
+}
+
+// RequeueSubmit 将死信队列中的提交重新判题
+//
+//	@Summary		Requeue dead letter submit
+//	@Description	Reset a dead letter submission to waiting and send it back to the judge queue
+//	@Tags			QuestionSubmit
+//	@Produce		json
+//	@Param			id	path		string								true	"Submit id"
+//	@Success		200	{object}	api_response.ApiResponse{data=string}	"Requeue success"
+//	@Failure		400	{object}	api_response.ApiResponse{data=nil}		"Requeue fail"
+//	@Router			/api/submit/admin/requeue/{id} [post]
+func (qsc *QuestionSubmitController) RequeueSubmit(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" || len(id) > 256 {
+		log.Printf("invalid id")
+		c.JSON(http.StatusBadRequest, api_response.NewResponse(nil, "invalid id ").Response(api_response.PARAMSERR))
+
+		return
+	}
+	ids, err := qsc.judgeQueue.Dead(c.Request.Context())
+	if err != nil {
+		log.Printf("list dead letter submits %v", err)
+		c.JSON(http.StatusBadRequest, api_response.NewResponse(nil, "judge queue unavailable").Response(api_response.OPERATIONERR))
+		return
+	}
+	if !contains(ids, id) {
+		log.Printf("submit %s is not in the dead letter queue", id)
+		c.JSON(http.StatusBadRequest, api_response.NewResponse(nil, "submit is not in the dead letter queue").Response(api_response.PARAMSERR))
+		return
+	}
+
+	submit, err := qsc.qsService.GetSubmitQuestion(id)
+	if err != nil {
+		log.Printf("query submit %s %v", id, err)
+		c.JSON(http.StatusBadRequest, api_response.NewResponse(nil, "requeue error").Response(api_response.OPERATIONERR))
+		return
+	}
+
+	//先恢复为待判题，判题进程只处理待判题的提交
+	err = qsc.qsService.UpdateSubmitQuestion(model_question.CommonQuestionSubmitRequest{
+		ID:        id,
+		Status:    constant.WAITING,
+		JudgeInfo: "[]",
+	})
+	if err != nil {
+		log.Printf("reset submit %s %v", id, err)
+		c.JSON(http.StatusBadRequest, api_response.NewResponse(nil, "requeue error").Response(api_response.OPERATIONERR))
+		return
+	}
+	found, err := qsc.judgeQueue.Requeue(c.Request.Context(), id)
+	if err != nil || !found {
+		log.Printf("requeue submit %s found %v %v", id, found, err)
+		//没有重新入队时恢复原来的判题结果，避免提交一直停留在待判题
+		err = qsc.qsService.UpdateSubmitQuestion(model_question.CommonQuestionSubmitRequest{
+			ID:           id,
+			Status:       submit.Status,
+			JudgeInfo:    submit.JudgeInfo,
+			JudgeSummary: submit.JudgeSummary,
+		})
+		if err != nil {
+			log.Printf("restore submit %s %v", id, err)
+		}
+		c.JSON(http.StatusBadRequest, api_response.NewResponse(nil, "requeue error").Response(api_response.OPERATIONERR))
+		return
+	}
+
+	qsc.publishQueued(c, id)
+
+	log.Printf("requeue submit success")
+	c.JSON(http.StatusOK, api_response.NewResponse(id, "requeue submit success").Response(api_response.SUCCESS))
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// checkLanguage 只接受沙箱中注册了工具链的语言
 func checkLanguage(lang string) string {
 	lan := strings.ToLower(lang)
-	switch lan {
-	case constant.C, constant.Cpp, constant.Java, constant.Go, constant.Python:
-		return lan
-	default:
+	if _, err := sanbox.GetToolchain(lan); err != nil {
 		return ""
 	}
+	return lan
 }
 
 func (qsc *QuestionSubmitController) checkQueries(qsQuery model_question.QueryQuestionSubmitRequest) error {
@@ -0,0 +1,196 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/xissg/userManageSystem/common/api_response"
+	"github.com/xissg/userManageSystem/entity/model_user"
+	"github.com/xissg/userManageSystem/service/mysql"
+	"github.com/xissg/userManageSystem/service/rbac"
+	"gorm.io/gorm"
+	"log"
+	"net/http"
+)
+
+//角色和权限管理
+
+type RoleController struct {
+	roleService *mysql.RoleService
+	userService *mysql.UserService
+	authz       *rbac.Authorizer
+}
+
+func NewRoleController(roleService *mysql.RoleService, userService *mysql.UserService, authz *rbac.Authorizer) *RoleController {
+	return &RoleController{
+		roleService: roleService,
+		userService: userService,
+		authz:       authz,
+	}
+}
+
+// ListRoles 查询所有角色
+//
+//	@Summary		List roles
+//	@Description	List every role and its permissions
+//	@Tags			Role
+//	@Produce		json
+//	@Success		200	{object}	api_response.ApiResponse{data=[]model_user.RoleInfo}	"Query success"
+//	@Failure		400	{object}	api_response.ApiResponse{data=nil}					"Query fail"
+//	@Router			/api/role/list [get]
+func (rc *RoleController) ListRoles(c *gin.Context) {
+	roles, err := rc.roleService.GetRoles()
+	if err != nil {
+		log.Printf("query roles %v", err)
+		c.JSON(http.StatusBadRequest, api_response.NewResponse(nil, "query roles error").Response(api_response.OPERATIONERR))
+		return
+	}
+
+	log.Printf("query roles success")
+	c.JSON(http.StatusOK, api_response.NewResponse(roles, "query roles success").Response(api_response.SUCCESS))
+}
+
+// GetUserRoles 查询用户的角色和权限
+//
+//	@Summary		Get user roles
+//	@Description	Get the base role, assigned roles and resulting permissions of a user
+//	@Tags			Role
+//	@Produce		json
+//	@Param			account	path		string											true	"User account"
+//	@Success		200		{object}	api_response.ApiResponse{data=model_user.UserRoles}	"Query success"
+//	@Failure		400		{object}	api_response.ApiResponse{data=nil}					"Query fail"
+//	@Router			/api/role/user/{account} [get]
+func (rc *RoleController) GetUserRoles(c *gin.Context) {
+	user, ok := rc.findUser(c, c.Param("account"))
+	if !ok {
+		return
+	}
+
+	roles, err := rc.roleService.UserRoles(user.ID)
+	if err != nil {
+		log.Printf("query roles of %s %v", user.UserAccount, err)
+		c.JSON(http.StatusBadRequest, api_response.NewResponse(nil, "query roles error").Response(api_response.OPERATIONERR))
+		return
+	}
+	permissions, err := rc.authz.Permissions(model_user.UserToUserSession(user))
+	if err != nil {
+		log.Printf("query permissions of %s %v", user.UserAccount, err)
+		c.JSON(http.StatusBadRequest, api_response.NewResponse(nil, "query roles error").Response(api_response.OPERATIONERR))
+		return
+	}
+
+	result := model_user.UserRoles{
+		UserAccount: user.UserAccount,
+		UserRole:    user.UserRole,
+		Roles:       roles,
+		Permissions: permissions,
+	}
+	log.Printf("query user roles success")
+	c.JSON(http.StatusOK, api_response.NewResponse(result, "query user roles success").Response(api_response.SUCCESS))
+}
+
+// AssignRole 为用户分配角色
+//
+//	@Summary		Assign role
+//	@Description	Assign an additional role to a user, the permissions take effect on the next request
+//	@Tags			Role
+//	@Accept			json
+//	@Produce		json
+//	@Param			role	body		model_user.AssignRoleRequest		true	"User account and role"
+//	@Success		200		{object}	api_response.ApiResponse{data=nil}	"Assign success"
+//	@Failure		400		{object}	api_response.ApiResponse{data=nil}	"Assign fail"
+//	@Router			/api/role/assign [post]
+func (rc *RoleController) AssignRole(c *gin.Context) {
+	request, ok := rc.bindRequest(c)
+	if !ok {
+		return
+	}
+	user, ok := rc.findUser(c, request.UserAccount)
+	if !ok {
+		return
+	}
+
+	err := rc.roleService.AssignRole(user.ID, request.Role)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Printf("role %s not found", request.Role)
+		c.JSON(http.StatusBadRequest, api_response.NewResponse(nil, "no such role").Response(api_response.PARAMSERR))
+		return
+	}
+	if err != nil {
+		log.Printf("assign role %v", err)
+		c.JSON(http.StatusBadRequest, api_response.NewResponse(nil, "assign role error").Response(api_response.OPERATIONERR))
+		return
+	}
+
+	log.Printf("assign role %s to %s success", request.Role, user.UserAccount)
+	c.JSON(http.StatusOK, api_response.NewResponse(nil, "assign role success").Response(api_response.SUCCESS))
+}
+
+// RevokeRole 撤销用户的角色
+//
+//	@Summary		Revoke role
+//	@Description	Revoke an additional role from a user
+//	@Tags			Role
+//	@Accept			json
+//	@Produce		json
+//	@Param			role	body		model_user.AssignRoleRequest		true	"User account and role"
+//	@Success		200		{object}	api_response.ApiResponse{data=nil}	"Revoke success"
+//	@Failure		400		{object}	api_response.ApiResponse{data=nil}	"Revoke fail"
+//	@Router			/api/role/revoke [post]
+func (rc *RoleController) RevokeRole(c *gin.Context) {
+	request, ok := rc.bindRequest(c)
+	if !ok {
+		return
+	}
+	user, ok := rc.findUser(c, request.UserAccount)
+	if !ok {
+		return
+	}
+
+	revoked, err := rc.roleService.RevokeRole(user.ID, request.Role)
+	if err != nil {
+		log.Printf("revoke role %v", err)
+		c.JSON(http.StatusBadRequest, api_response.NewResponse(nil, "revoke role error").Response(api_response.OPERATIONERR))
+		return
+	}
+	if !revoked {
+		log.Printf("role %s is not assigned to %s", request.Role, user.UserAccount)
+		c.JSON(http.StatusBadRequest, api_response.NewResponse(nil, "role is not assigned to the user").Response(api_response.PARAMSERR))
+		return
+	}
+
+	log.Printf("revoke role %s from %s success", request.Role, user.UserAccount)
+	c.JSON(http.StatusOK, api_response.NewResponse(nil, "revoke role success").Response(api_response.SUCCESS))
+}
+
+func (rc *RoleController) bindRequest(c *gin.Context) (model_user.AssignRoleRequest, bool) {
+	var request model_user.AssignRoleRequest
+	//反序列化取出JSON数据
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Printf("JSON unmarshal  %v", err)
+		c.JSON(http.StatusBadRequest, api_response.NewResponse(nil, "unmarshal error ").Response(api_response.OPERATIONERR))
+		return request, false
+	}
+	if request.Role == "" || len(request.Role) > 64 {
+		log.Printf("invalid role")
+		c.JSON(http.StatusBadRequest, api_response.NewResponse(nil, "invalid role").Response(api_response.PARAMSERR))
+		return request, false
+	}
+	return request, true
+}
+
+// findUser 查询账号对应的用户，失败时写入响应
+func (rc *RoleController) findUser(c *gin.Context, account string) (model_user.User, bool) {
+	if account == "" || len(account) > 256 {
+		log.Println("not a valid user account")
+		c.JSON(http.StatusBadRequest, api_response.NewResponse(nil, "not a valid user account").Response(api_response.PARAMSERR))
+		return model_user.User{}, false
+	}
+	user, err := rc.userService.GetUser(account)
+	if err != nil {
+		log.Println(fmt.Sprintf("no such user %v", err))
+		c.JSON(http.StatusBadRequest, api_response.NewResponse(nil, "no such user").Response(api_response.OPERATIONERR))
+		return model_user.User{}, false
+	}
+	return user, true
+}
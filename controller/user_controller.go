@@ -8,23 +8,37 @@ import (
 	"github.com/xissg/userManageSystem/common/api_response"
 	"github.com/xissg/userManageSystem/common/constant"
 	"github.com/xissg/userManageSystem/entity/model_user"
+	"github.com/xissg/userManageSystem/middleware"
+	"github.com/xissg/userManageSystem/service/lockout"
 	"github.com/xissg/userManageSystem/service/mysql"
+	"github.com/xissg/userManageSystem/service/rbac"
 	"github.com/xissg/userManageSystem/service/redis"
+	"github.com/xissg/userManageSystem/service/token"
+	"github.com/xissg/userManageSystem/utils"
 	"gorm.io/gorm"
 	"log"
 	"net/http"
+	"strconv"
+	"time"
 )
 
 type UserController struct {
 	sessionService *redis.SessionService
 	userService    *mysql.UserService
+	tokenService   *token.Service
+	authz          *rbac.Authorizer
+	lockout        *lockout.Guard
 }
 
-func NewUserController(userService mysql.UserService, sessionService redis.SessionService) *UserController {
+// NewUserController tokenService 签发和撤销非浏览器客户端使用的访问令牌和刷新令牌，guard 限制连续登录失败
+func NewUserController(userService mysql.UserService, sessionService redis.SessionService, tokenService *token.Service, authz *rbac.Authorizer, guard *lockout.Guard) *UserController {
 
 	return &UserController{
 		sessionService: &sessionService,
 		userService:    &userService,
+		tokenService:   tokenService,
+		authz:          authz,
+		lockout:        guard,
 	}
 }
 
@@ -68,9 +82,15 @@ func (uc *UserController) Register(c *gin.Context) {
 		return
 	}
 
-	//生成用户
-	var user model_user.User
-	user = model_user.AddUserToUser(receiveUser)
+	//生成用户，密码只保存加盐的哈希
+	passwordHash, err := utils.HashPassword(receiveUser.UserPassword)
+	if err != nil {
+		log.Printf("hash password %v", err)
+		c.JSON(http.StatusBadRequest, api_response.NewResponse(nil, "register error").Response(api_response.OPERATIONERR))
+
+		return
+	}
+	user := model_user.AddUserToUser(receiveUser, passwordHash)
 
 	//插入数据库
 	err = uc.userService.AddUser(user)
@@ -95,6 +115,7 @@ func (uc *UserController) Register(c *gin.Context) {
 //	@Param			user	body		model_user.LoginUserRequest						true	"User information"
 //	@Success		200		{object}	api_response.ApiResponse{data=model_user.ReturnUser}	"Login success"
 //	@Failure		400		{object}	api_response.ApiResponse{data=nil}						"Login fail"
+//	@Failure		429		{object}	api_response.ApiResponse{data=nil}						"Too many failed attempts"
 //	@Router			/api/user/login     [post]
 func (uc *UserController) Login(c *gin.Context) {
 	var loginUser model_user.LoginUserRequest
@@ -115,17 +136,48 @@ func (uc *UserController) Login(c *gin.Context) {
 		return
 	}
 
-	user := model_user.LoginUserToUser(loginUser)
-	//查询用户账户和密码是否匹配
-	ret, err := uc.userService.GetUser(user.UserAccount)
-	if ret.UserAccount == "" {
-		log.Println("The user has not registered yet")
-		c.JSON(http.StatusBadRequest, api_response.NewResponse(nil, "The user has not registered yet").Response(api_response.AUTHERR))
+	//同一账号或同一IP连续失败后锁定一段时间，读取锁定状态失败时不阻止登录
+	ip := c.ClientIP()
+	locked, err := uc.lockout.Check(c.Request.Context(), loginUser.UserAccount, ip)
+	if err != nil {
+		log.Printf("check login lockout %v", err)
+	}
+	if locked > 0 {
+		log.Printf("login of %s from %s is locked for %v", loginUser.UserAccount, ip, locked)
+		c.Header("Retry-After", strconv.Itoa(int((locked+time.Second-1)/time.Second)))
+		c.JSON(http.StatusTooManyRequests, api_response.NewResponse(nil, "too many failed attempts, try again later").Response(api_response.AUTHERR))
 
 		return
 	}
 
-	//禁用的账号
+	//查询用户账户和密码是否匹配，账号不存在和密码错误返回相同的信息
+	ret, err := uc.userService.GetUser(loginUser.UserAccount)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Printf("query user %v", err)
+		c.JSON(http.StatusBadRequest, api_response.NewResponse(nil, "login error").Response(api_response.OPERATIONERR))
+
+		return
+	}
+	var ok, rehash bool
+	if err == nil {
+		ok, rehash = utils.VerifyPassword(ret.UserPassword, loginUser.UserPassword)
+	} else {
+		utils.VerifyMissingPassword(loginUser.UserPassword)
+	}
+	if !ok {
+		if err = uc.lockout.Failure(c.Request.Context(), loginUser.UserAccount, ip); err != nil {
+			log.Printf("record login failure %v", err)
+		}
+		log.Println("username or password is wrong")
+		c.JSON(http.StatusBadRequest, api_response.NewResponse(nil, "username or password is wrong").Response(api_response.AUTHERR))
+
+		return
+	}
+	if err = uc.lockout.Success(c.Request.Context(), loginUser.UserAccount); err != nil {
+		log.Printf("reset login failures %v", err)
+	}
+
+	//禁用的账号，密码正确后才提示，避免暴露账号状态
 	if ret.UserRole == constant.Ban {
 		log.Println("The user has been banned")
 		c.JSON(http.StatusBadRequest, api_response.NewResponse(nil, "The user has been banned").Response(api_response.AUTHERR))
@@ -133,15 +185,29 @@ func (uc *UserController) Login(c *gin.Context) {
 		return
 	}
 
-	if ret.UserPassword != user.UserPassword {
-		log.Println("username or password is wrong")
-		c.JSON(http.StatusBadRequest, api_response.NewResponse(nil, "username or password is wrong").Response(api_response.AUTHERR))
+	//旧版本的md5哈希或较低代价的哈希在登录成功后升级，失败时下次登录再试
+	if rehash {
+		uc.rehashPassword(ret, loginUser.UserPassword)
+	}
+
+	//非浏览器客户端使用令牌，不创建session
+	userSession := model_user.UserToUserSession(ret)
+	if loginUser.IssueToken {
+		pair, err := uc.tokenService.Issue(userSession)
+		if err != nil {
+			log.Printf("issue token %v", err)
+			c.JSON(http.StatusBadRequest, api_response.NewResponse(nil, "issue token error").Response(api_response.OPERATIONERR))
+
+			return
+		}
+		result := model_user.TokenLoginResponse{User: model_user.UserToReturnUser(ret), TokenPair: pair}
+		log.Printf("login success")
+		c.JSON(http.StatusOK, api_response.NewResponse(result, "login success").Response(api_response.SUCCESS))
 
 		return
 	}
 
 	//登录成功, 存储session信息
-	userSession := model_user.UserToUserSession(ret)
 	err = uc.sessionService.NewOrUpdateSession(c, userSession)
 	if err != nil {
 		log.Println(fmt.Sprintf("session create %v", err))
@@ -166,14 +232,27 @@ func (uc *UserController) Login(c *gin.Context) {
 //	@Router			/api/user/logout    [get]
 func (uc *UserController) Logout(c *gin.Context) {
 	//判断用户是否登录
-	validity, _ := uc.sessionService.GetSession(c)
-	if validity.UserRole == constant.Anonymous {
+	if middleware.CurrentUser(c).UserRole == constant.Anonymous {
 		log.Printf("you must login first")
 		c.JSON(http.StatusBadRequest, api_response.NewResponse(nil, "you must login first").Response(api_response.AUTHERR))
 
 		return
 	}
 
+	//通过令牌登录时撤销当前的访问令牌，刷新令牌通过撤销接口撤销
+	if bearer := middleware.BearerToken(c); bearer != "" {
+		if err := uc.tokenService.Revoke(c.Request.Context(), bearer); err != nil {
+			log.Printf("revoke token %v", err)
+			c.JSON(http.StatusBadRequest, api_response.NewResponse(nil, "logout error").Response(api_response.OPERATIONERR))
+
+			return
+		}
+		log.Printf("logout success")
+		c.JSON(http.StatusOK, api_response.NewResponse(nil, "logout success").Response(api_response.SUCCESS))
+
+		return
+	}
+
 	//删除session
 	err := uc.sessionService.DeleteSession(c)
 	if err != nil {
@@ -186,6 +265,79 @@ func (uc *UserController) Logout(c *gin.Context) {
 	c.JSON(http.StatusOK, api_response.NewResponse(nil, "logout success").Response(api_response.SUCCESS))
 }
 
+// RefreshToken 使用刷新令牌换取新的令牌
+//
+//	@Summary		Refresh token
+//	@Description	Exchange a refresh token for a new access and refresh token pair, the old refresh token can not be used again
+//	@Tags			User
+//	@Accept			json
+//	@Produce		json
+//	@Param			token	body		model_user.RefreshTokenRequest						true	"Refresh token"
+//	@Success		200		{object}	api_response.ApiResponse{data=model_user.TokenPair}	"Refresh success"
+//	@Failure		400		{object}	api_response.ApiResponse{data=nil}					"Refresh fail"
+//	@Router			/api/user/token/refresh [post]
+func (uc *UserController) RefreshToken(c *gin.Context) {
+	var request model_user.RefreshTokenRequest
+	if err := c.ShouldBindJSON(&request); err != nil || request.RefreshToken == "" {
+		log.Printf("JSON unmarshal  %v", err)
+		c.JSON(http.StatusBadRequest, api_response.NewResponse(nil, "unmarshal error ").Response(api_response.OPERATIONERR))
+
+		return
+	}
+
+	//重新读取用户信息，禁用或删除的用户不能再换取令牌
+	pair, err := uc.tokenService.Refresh(c.Request.Context(), request.RefreshToken, func(user model_user.UserSession) (model_user.UserSession, error) {
+		ret, err := uc.userService.GetUser(user.UserAccount)
+		if err != nil {
+			return model_user.UserSession{}, err
+		}
+		if ret.UserRole == constant.Ban {
+			return model_user.UserSession{}, errors.New("the user has been banned")
+		}
+		return model_user.UserToUserSession(ret), nil
+	})
+	if err != nil {
+		log.Printf("refresh token %v", err)
+		c.JSON(http.StatusBadRequest, api_response.NewResponse(nil, "invalid or expired refresh token").Response(api_response.AUTHERR))
+
+		return
+	}
+
+	log.Printf("refresh token success")
+	c.JSON(http.StatusOK, api_response.NewResponse(pair, "refresh token success").Response(api_response.SUCCESS))
+}
+
+// RevokeToken 撤销令牌
+//
+//	@Summary		Revoke token
+//	@Description	Revoke an access or refresh token before it expires
+//	@Tags			User
+//	@Accept			json
+//	@Produce		json
+//	@Param			token	body		model_user.RevokeTokenRequest		true	"Token"
+//	@Success		200		{object}	api_response.ApiResponse{data=nil}	"Revoke success"
+//	@Failure		400		{object}	api_response.ApiResponse{data=nil}	"Revoke fail"
+//	@Router			/api/user/token/revoke [post]
+func (uc *UserController) RevokeToken(c *gin.Context) {
+	var request model_user.RevokeTokenRequest
+	if err := c.ShouldBindJSON(&request); err != nil || request.Token == "" {
+		log.Printf("JSON unmarshal  %v", err)
+		c.JSON(http.StatusBadRequest, api_response.NewResponse(nil, "unmarshal error ").Response(api_response.OPERATIONERR))
+
+		return
+	}
+
+	if err := uc.tokenService.Revoke(c.Request.Context(), request.Token); err != nil {
+		log.Printf("revoke token %v", err)
+		c.JSON(http.StatusBadRequest, api_response.NewResponse(nil, "revoke token error").Response(api_response.OPERATIONERR))
+
+		return
+	}
+
+	log.Printf("revoke token success")
+	c.JSON(http.StatusOK, api_response.NewResponse(nil, "revoke token success").Response(api_response.SUCCESS))
+}
+
 // GetUserList 查询用户列表
 //
 //	@Summary		Query user
@@ -198,15 +350,6 @@ func (uc *UserController) Logout(c *gin.Context) {
 //	@Failure		400		{object}	api_response.ApiResponse{data=nil}						"Query fail"
 //	@Router			/api/user/query [post]
 func (uc *UserController) GetUserList(c *gin.Context) {
-	//判断用户权限
-	validity, _ := uc.sessionService.GetSession(c)
-
-	if validity.UserRole == constant.Anonymous || validity.UserRole == constant.Ban {
-		log.Printf("you must login first")
-		c.JSON(http.StatusBadRequest, api_response.NewResponse(nil, "you must login first").Response(api_response.AUTHERR))
-
-		return
-	}
 	var queryRequest model_user.UserQueryRequest
 	user := model_user.UserQueryToUser(queryRequest)
 
@@ -258,14 +401,6 @@ func (uc *UserController) GetUserList(c *gin.Context) {
 //	@Failure		400		{object}	api_response.ApiResponse{data=nil}							"Query fail"
 //	@Router			/api/user/admin/query [post]
 func (uc *UserController) AdminGetUserList(c *gin.Context) {
-	//判断用户权限
-	validity, _ := uc.sessionService.GetSession(c)
-	if validity.UserRole != constant.Admin {
-		c.JSON(http.StatusBadRequest, api_response.NewResponse(nil, "you are not admin").Response(api_response.AUTHERR))
-
-		return
-	}
-
 	var queryRequest model_user.AdminUserQueryRequest
 	//反序列化取出JSON数据
 	if err := c.ShouldBindJSON(&queryRequest); err != nil {
@@ -318,15 +453,8 @@ func (uc *UserController) AdminGetUserList(c *gin.Context) {
 //	@Failure		400		{object}	api_response.ApiResponse{data=nil}	"Update user request fail"
 //	@Router			/api/user/update    [post]
 func (uc *UserController) UpdateUser(c *gin.Context) {
-	//判断用户权限
-	validity, _ := uc.sessionService.GetSession(c)
-
-	if validity.UserRole != constant.Common && validity.UserRole != constant.Admin {
-		log.Printf("you are not login")
-		c.JSON(http.StatusBadRequest, api_response.NewResponse(nil, "you are not login").Response(api_response.AUTHERR))
-
-		return
-	}
+	//当前用户，登录和角色由路由上的中间件检查
+	validity := middleware.CurrentUser(c)
 
 	var updateUser model_user.UpdateUserRequest
 	//反序列化取出JSON数据
@@ -357,6 +485,15 @@ func (uc *UserController) UpdateUser(c *gin.Context) {
 		return
 	}
 	user := model_user.UpdateUserToUser(oldInfo, updateUser)
+	if updateUser.UserPassword != "" {
+		user.UserPassword, err = utils.HashPassword(updateUser.UserPassword)
+		if err != nil {
+			log.Printf("hash password %v", err)
+			c.JSON(http.StatusBadRequest, api_response.NewResponse(nil, "update user error").Response(api_response.OPERATIONERR))
+
+			return
+		}
+	}
 	err = uc.userService.UpdateUser(user)
 	if err != nil {
 		log.Println(fmt.Sprintf("update user %v", err))
@@ -381,15 +518,6 @@ func (uc *UserController) UpdateUser(c *gin.Context) {
 //	@Failure		400		{object}	api_response.ApiResponse{data=nil}	"Edit user request fail"
 //	@Router			/api/user/admin/update    [post]
 func (uc *UserController) EditUser(c *gin.Context) {
-	//判断用户权限
-	validity, _ := uc.sessionService.GetSession(c)
-	if validity.UserRole != constant.Admin {
-		log.Printf("you are not admin")
-		c.JSON(http.StatusBadRequest, api_response.NewResponse(nil, "you are not admin").Response(api_response.AUTHERR))
-
-		return
-	}
-
 	var editUser model_user.EditUserRequest
 	//反序列化取出JSON数据
 	if err := c.ShouldBindJSON(&editUser); err != nil {
@@ -420,6 +548,15 @@ func (uc *UserController) EditUser(c *gin.Context) {
 
 	//更新用户信息
 	user := model_user.EditUserToUser(oldInfo, editUser)
+	if editUser.UserPassword != "" {
+		user.UserPassword, err = utils.HashPassword(editUser.UserPassword)
+		if err != nil {
+			log.Printf("hash password %v", err)
+			c.JSON(http.StatusBadRequest, api_response.NewResponse(nil, "update user error").Response(api_response.OPERATIONERR))
+
+			return
+		}
+	}
 	err = uc.userService.UpdateUser(user)
 	if err != nil {
 		log.Println(fmt.Sprintf("update user %v", err))
@@ -444,15 +581,6 @@ func (uc *UserController) EditUser(c *gin.Context) {
 //	@Failure		400		{object}	api_response.ApiResponse{data=nil}	"Delete user fail"
 //	@Router			/api/user/admin/delete [get]
 func (uc *UserController) DeleteUser(c *gin.Context) {
-
-	//判断用户权限
-	validity, _ := uc.sessionService.GetSession(c)
-	if validity.UserRole != constant.Admin {
-		c.JSON(http.StatusBadRequest, api_response.NewResponse(nil, "you are not validity user").Response(api_response.AUTHERR))
-
-		return
-	}
-
 	//获取account参数的值
 	userAccount := c.Param("account")
 	if userAccount == "" {
@@ -475,6 +603,116 @@ func (uc *UserController) DeleteUser(c *gin.Context) {
 	c.JSON(http.StatusOK, api_response.NewResponse(nil, "delete user success").Response(api_response.SUCCESS))
 }
 
+// BanUser 禁用或解禁用户
+//
+//	@Summary		Ban user
+//	@Description	Ban a user or lift the ban, an unbanned user becomes a common user
+//	@Tags			User
+//	@Accept			json
+//	@Produce		json
+//	@Param			user	body		model_user.BanUserRequest			true	"User account"
+//	@Success		200		{object}	api_response.ApiResponse{data=nil}	"Ban user success"
+//	@Failure		400		{object}	api_response.ApiResponse{data=nil}	"Ban user fail"
+//	@Router			/api/user/admin/ban [post]
+func (uc *UserController) BanUser(c *gin.Context) {
+	var request model_user.BanUserRequest
+	//反序列化取出JSON数据
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Printf("JSON unmarshal  %v", err)
+		c.JSON(http.StatusBadRequest, api_response.NewResponse(nil, "unmarshal error ").Response(api_response.OPERATIONERR))
+
+		return
+	}
+	if request.UserAccount == "" || len(request.UserAccount) > 256 {
+		log.Println("not a valid user account")
+		c.JSON(http.StatusBadRequest, api_response.NewResponse(nil, "not a valid user account").Response(api_response.PARAMSERR))
+
+		return
+	}
+
+	user, err := uc.userService.GetUser(request.UserAccount)
+	if err != nil {
+		log.Println(fmt.Sprintf("no such user %v", err))
+		c.JSON(http.StatusBadRequest, api_response.NewResponse(nil, "no such user").Response(api_response.OPERATIONERR))
+
+		return
+	}
+
+	//管理员只能由有修改用户权限的用户禁用
+	if user.UserRole == constant.Admin && !uc.authz.Can(middleware.CurrentUser(c), constant.PermUserEdit) {
+		log.Printf("can not ban admin %s", user.UserAccount)
+		c.JSON(http.StatusBadRequest, api_response.NewResponse(nil, "permission denied").Response(api_response.AUTHERR))
+
+		return
+	}
+
+	user.UserRole = constant.Common
+	if request.Ban {
+		user.UserRole = constant.Ban
+	}
+	err = uc.userService.UpdateUser(user)
+	if err != nil {
+		log.Println(fmt.Sprintf("update user %v", err))
+		c.JSON(http.StatusBadRequest, api_response.NewResponse(nil, "update user error").Response(api_response.OPERATIONERR))
+
+		return
+	}
+
+	log.Printf("ban user success")
+	c.JSON(http.StatusOK, api_response.NewResponse(nil, "ban user success").Response(api_response.SUCCESS))
+}
+
+// UnlockUser 解除账号的登录锁定
+//
+//	@Summary		Unlock user
+//	@Description	Clear the failed login attempts of an account and lift its lockout, lockouts of client IPs are kept
+//	@Tags			User
+//	@Accept			json
+//	@Produce		json
+//	@Param			user	body		model_user.UnlockUserRequest		true	"User account"
+//	@Success		200		{object}	api_response.ApiResponse{data=nil}	"Unlock user success"
+//	@Failure		400		{object}	api_response.ApiResponse{data=nil}	"Unlock user fail"
+//	@Router			/api/user/admin/unlock [post]
+func (uc *UserController) UnlockUser(c *gin.Context) {
+	var request model_user.UnlockUserRequest
+	//反序列化取出JSON数据
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Printf("JSON unmarshal  %v", err)
+		c.JSON(http.StatusBadRequest, api_response.NewResponse(nil, "unmarshal error ").Response(api_response.OPERATIONERR))
+
+		return
+	}
+	if request.UserAccount == "" || len(request.UserAccount) > 256 {
+		log.Println("not a valid user account")
+		c.JSON(http.StatusBadRequest, api_response.NewResponse(nil, "not a valid user account").Response(api_response.PARAMSERR))
+
+		return
+	}
+
+	err := uc.lockout.Unlock(c.Request.Context(), request.UserAccount)
+	if err != nil {
+		log.Printf("unlock user %v", err)
+		c.JSON(http.StatusBadRequest, api_response.NewResponse(nil, "unlock user error").Response(api_response.OPERATIONERR))
+
+		return
+	}
+
+	log.Printf("unlock user %s success", request.UserAccount)
+	c.JSON(http.StatusOK, api_response.NewResponse(nil, "unlock user success").Response(api_response.SUCCESS))
+}
+
+// rehashPassword 使用当前的算法重新生成密码哈希，密码已被修改时不覆盖
+func (uc *UserController) rehashPassword(user model_user.User, password string) {
+	hash, err := utils.HashPassword(password)
+	if err != nil {
+		log.Printf("hash password %v", err)
+		return
+	}
+	if err = uc.userService.UpdatePassword(user.UserAccount, user.UserPassword, hash); err != nil {
+		log.Printf("rehash password of %s: %v", user.UserAccount, err)
+	}
+}
+
 func (uc *UserController) checkUser(account string, password string) error {
 	if account == "" {
 		return errors.New("user account required")
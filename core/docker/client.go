@@ -0,0 +1,66 @@
+package docker
+
+import (
+	"context"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/client"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/xissg/userManageSystem/core/executor"
+	"io"
+	"time"
+)
+
+// Client 判题用到的docker客户端方法，*client.Client 实现了该接口，测试中可替换为假的实现
+type Client interface {
+	ContainerCreate(ctx context.Context, config *container.Config, hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig, platform *ocispec.Platform, containerName string) (container.CreateResponse, error)
+	ContainerAttach(ctx context.Context, container string, options container.AttachOptions) (types.HijackedResponse, error)
+	ContainerStart(ctx context.Context, containerID string, options container.StartOptions) error
+	ContainerWait(ctx context.Context, containerID string, condition container.WaitCondition) (<-chan container.WaitResponse, <-chan error)
+	ContainerKill(ctx context.Context, containerID, signal string) error
+	ContainerInspect(ctx context.Context, containerID string) (types.ContainerJSON, error)
+	ContainerStats(ctx context.Context, containerID string, stream bool) (types.ContainerStats, error)
+	ContainerLogs(ctx context.Context, container string, options container.LogsOptions) (io.ReadCloser, error)
+	ContainerUpdate(ctx context.Context, containerID string, updateConfig container.UpdateConfig) (container.ContainerUpdateOKBody, error)
+	ContainerExecCreate(ctx context.Context, container string, config types.ExecConfig) (types.IDResponse, error)
+	ContainerExecAttach(ctx context.Context, execID string, config types.ExecStartCheck) (types.HijackedResponse, error)
+	ContainerExecInspect(ctx context.Context, execID string) (types.ContainerExecInspect, error)
+	ContainerRemove(ctx context.Context, containerID string, options container.RemoveOptions) error
+	CopyToContainer(ctx context.Context, containerID, dstPath string, content io.Reader, options types.CopyToContainerOptions) error
+	CopyFromContainer(ctx context.Context, containerID, srcPath string) (io.ReadCloser, types.ContainerPathStat, error)
+	Close() error
+}
+
+func initClient() (Client, error) {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return nil, err
+	}
+	return cli, nil
+}
+
+// Runner 使用统一的安全策略创建编译和运行容器
+type Runner struct {
+	newClient func() (Client, error)
+	policy    SecurityPolicy
+	grace     time.Duration //超过时间限制后再等待的时间
+}
+
+// NewRunner 每次编译或运行时连接本机的docker
+func NewRunner(policy SecurityPolicy) *Runner {
+	return &Runner{
+		newClient: initClient,
+		policy:    policy,
+		grace:     executor.DefaultTimeGrace,
+	}
+}
+
+// NewRunnerWithClient 使用指定的客户端，主要用于测试
+func NewRunnerWithClient(cli Client, policy SecurityPolicy) *Runner {
+	return &Runner{
+		newClient: func() (Client, error) { return cli, nil },
+		policy:    policy,
+		grace:     executor.DefaultTimeGrace,
+	}
+}
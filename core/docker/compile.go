@@ -0,0 +1,179 @@
+package docker
+
+import (
+	"archive/tar"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/pkg/archive"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/xissg/userManageSystem/core/executor"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// 编译容器的资源限制
+const (
+	compileTimeout   = 30 * time.Second
+	compileMemory    = 512 << 20 //编译内存上限 512MB
+	compilePids      = 128       //编译进程数上限
+	compileCPU       = 1e9       //编译可用 1 个CPU
+	compileOutputMax = 64 << 10  //编译输出最多保留 64KB
+	artifactMax      = 64 << 20  //编译产物最大 64MB
+)
+
+// Compile 在image镜像创建的隔离容器中编译dir目录中的源码，并将编译产物取回dir
+// 返回编译器输出和退出码，err 仅表示容器本身的异常
+func (r *Runner) Compile(image string, dir string, cmd []string) (string, int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), compileTimeout+10*time.Second)
+	defer cancel()
+
+	cli, err := r.newClient()
+	if err != nil {
+		log.Printf("client initialization error: %v", err)
+		return "", -1, err
+	}
+	defer cli.Close()
+
+	//编译容器禁用网络并限制资源，源码放在匿名卷中
+	config := &container.Config{
+		Image:      image,
+		Cmd:        cmd,
+		WorkingDir: WorkDir,
+		Volumes:    map[string]struct{}{WorkDir: {}},
+	}
+	hc := &container.HostConfig{
+		NetworkMode: "none",
+		Resources: container.Resources{
+			Memory:     compileMemory,
+			MemorySwap: compileMemory,
+			PidsLimit:  int64Ptr(compilePids),
+			NanoCPUs:   compileCPU,
+		},
+	}
+	r.policy.apply(config, hc, true)
+	resp, err := cli.ContainerCreate(ctx, config, hc, nil, nil, "")
+	if err != nil {
+		log.Printf("compile container initialization error: %v", err)
+		return "", -1, err
+	}
+	defer func() {
+		err := cli.ContainerRemove(context.Background(), resp.ID, container.RemoveOptions{Force: true, RemoveVolumes: true})
+		if err != nil {
+			log.Println("container remove error", err)
+		}
+	}()
+
+	tarReader, err := archive.Tar(dir, archive.Uncompressed)
+	if err != nil {
+		return "", -1, err
+	}
+	err = cli.CopyToContainer(ctx, resp.ID, WorkDir, tarReader, types.CopyToContainerOptions{})
+	if err != nil {
+		return "", -1, err
+	}
+
+	if err = cli.ContainerStart(ctx, resp.ID, container.StartOptions{}); err != nil {
+		return "", -1, err
+	}
+
+	//等待编译结束，超时则强制结束容器
+	exitCode, timedOut, err := waitContainer(ctx, cli, resp.ID, compileTimeout)
+	if err != nil {
+		return "", -1, err
+	}
+	if timedOut {
+		return "compilation timed out", -1, nil
+	}
+
+	//收集编译器输出
+	logReader, err := cli.ContainerLogs(ctx, resp.ID, container.LogsOptions{ShowStdout: true, ShowStderr: true})
+	if err != nil {
+		return "", -1, err
+	}
+	defer logReader.Close()
+	output := &executor.LimitedBuffer{Limit: compileOutputMax}
+	_, err = stdcopy.StdCopy(output, output, logReader)
+	if err != nil {
+		return "", -1, err
+	}
+	if exitCode != 0 {
+		return output.String(), exitCode, nil
+	}
+
+	//取回编译产物
+	content, _, err := cli.CopyFromContainer(ctx, resp.ID, WorkDir)
+	if err != nil {
+		return "", -1, err
+	}
+	defer content.Close()
+	err = extractTar(content, dir, artifactMax)
+	if err != nil {
+		return "", -1, err
+	}
+
+	return output.String(), 0, nil
+}
+
+// extractTar 将容器中取出的目录解压到dir，去掉最外层目录，只保留普通文件
+func extractTar(r io.Reader, dir string, limit int64) error {
+	tr := tar.NewReader(r)
+	var total int64
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		name := filepath.Clean(hdr.Name)
+		_, rel, found := strings.Cut(filepath.ToSlash(name), "/")
+		if !found || rel == "" {
+			continue
+		}
+		target := filepath.Join(dir, rel)
+		if !strings.HasPrefix(target, filepath.Clean(dir)+string(os.PathSeparator)) {
+			return fmt.Errorf("invalid path in archive: %s", hdr.Name)
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err = os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			total += hdr.Size
+			if total > limit {
+				return errors.New("compiled artifacts too large")
+			}
+			if err = os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			if err = writeFile(target, tr, os.FileMode(hdr.Mode).Perm()); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func writeFile(path string, r io.Reader, perm os.FileMode) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = io.Copy(file, r)
+	return err
+}
+
+func int64Ptr(v int64) *int64 {
+	return &v
+}
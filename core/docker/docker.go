@@ -3,123 +3,219 @@ package docker
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
-	"github.com/docker/docker/client"
 	"github.com/docker/docker/pkg/archive"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/xissg/userManageSystem/core/executor"
 	"io"
 	"log"
-	"path"
 	"strings"
 	"time"
 )
 
-type Result struct {
-	ExitCode   int
-	ExecResult string
-	CostTime   int64
-	Memory     uint64
-}
-
 const (
-	imageName = "my-golang-image" //执行的镜像
-	dstDir    = "/app"            //docker中执行的路径
-)
+	WorkDir = "/app" //docker中执行的路径，编译输出中会出现该路径
 
-func Docker(codePath string, input string) (Result, error) {
-	var result Result
+	minMemoryLimit = 6 << 10 //docker 允许的最小内存限制，单位为kb
+	pidsLimit      = 64      //用户程序可创建的进程数上限
+	cpuPeriod      = 100000  //CPU 调度周期，单位为us
+	cpuQuota       = 100000  //每个周期可用的CPU时间，即最多使用 1 个CPU
+	streamTimeout  = 5 * time.Second
+)
 
+// Run 将dir目录中的文件复制到image镜像创建的容器中，在容器工作目录执行cmd，input 作为程序的标准输入
+func (r *Runner) Run(image string, dir string, cmd []string, input string, limits executor.Limits) (executor.Result, error) {
 	//创建连接客户端
 	ctx := context.Background()
 	//初始化
-	cli, err := initClient()
+	cli, err := r.newClient()
 	if err != nil {
 		log.Printf("client initialization error: %v", err)
-		return Result{}, err
+		return executor.Result{}, err
 	}
 	defer cli.Close()
 
+	in, err := r.create(cli, image, dir, cmd, withDefaults(limits))
+	if err != nil {
+		return executor.Result{}, err
+	}
+	defer in.remove(cli)
+
+	//分别收集标准输出和标准错误，输出超限时立即结束容器
+	in.collect(cli)
+
+	//开始执行容器
+	if err = cli.ContainerStart(ctx, in.id, container.StartOptions{}); err != nil {
+		log.Println("container start:", err)
+		return executor.Result{}, err
+	}
+
+	//写入输入后关闭标准输入，程序不读取输入时由超时结束，不会阻塞判题
+	go func() {
+		_, err := io.Copy(in.stream.Conn, strings.NewReader(input))
+		if err != nil {
+			log.Println("write stdin error", err)
+		}
+		_ = in.stream.CloseWrite()
+	}()
+
+	return in.wait(cli)
+}
+
+// instance 已复制文件并连接了标准输入输出，尚未启动的容器
+type instance struct {
+	id        string
+	limits    executor.Limits
+	grace     time.Duration
+	stream    types.HijackedResponse
+	stdout    *executor.LimitedBuffer
+	stderr    *executor.LimitedBuffer
+	forwarded *forwardWriter //交互模式下转发给对方的输出
+	copyDone  chan error     //输出流读取结束
+}
+
+// create 创建容器，将dir目录复制到容器的工作目录，并在启动前连接标准输入输出
+func (r *Runner) create(cli Client, image string, dir string, cmd []string, limits executor.Limits) (*instance, error) {
+	ctx := context.Background()
 	//初始化配置
-	resp, err := initContainer(cli, codePath, input)
+	resp, err := r.initContainer(cli, image, cmd, limits)
 	if err != nil {
 		log.Printf("container initialization error: %v", err)
-		return Result{}, err
+		return nil, err
+	}
+	in := &instance{
+		id:       resp.ID,
+		limits:   limits,
+		grace:    r.grace,
+		stderr:   &executor.LimitedBuffer{Limit: executor.StderrMax},
+		copyDone: make(chan error, 1),
 	}
 
-	// 将文件编译后复制到容器中
-	tarReader, err := archive.Tar(codePath, archive.Uncompressed)
+	// 将编译产物所在目录复制到容器中
+	tarReader, err := archive.Tar(dir, archive.Uncompressed)
 	if err != nil {
-		log.Fatal("compress file error:", err)
-		return Result{}, err
+		log.Println("compress file error:", err)
+		in.remove(cli)
+		return nil, err
 	}
-	err = cli.CopyToContainer(context.Background(), resp.ID, dstDir, tarReader, types.CopyToContainerOptions{})
+	err = cli.CopyToContainer(ctx, in.id, WorkDir, tarReader, types.CopyToContainerOptions{})
 	if err != nil {
-		log.Fatal("copy file error", err)
-		return Result{}, err
+		log.Println("copy file error", err)
+		in.remove(cli)
+		return nil, err
 	}
 
-	//开始执行容器
-	if err = cli.ContainerStart(ctx, resp.ID, container.StartOptions{}); err != nil {
-		log.Println("container start:", err)
-		return Result{}, err
+	//启动前连接容器的标准输入输出
+	in.stream, err = cli.ContainerAttach(ctx, in.id, container.AttachOptions{
+		Stream: true,
+		Stdin:  true,
+		Stdout: true,
+		Stderr: true,
+	})
+	if err != nil {
+		log.Println("container attach:", err)
+		in.remove(cli)
+		return nil, err
 	}
+	return in, nil
+}
 
-	//获取执行状态信息
-	result.ExitCode, result.CostTime, result.Memory, err = getStats(resp, cli)
-	if err != nil {
-		log.Println("get stats error", err)
-		return Result{}, err
+// collect 将标准输出收集到限制大小的缓冲中，超过输出限制时结束容器
+func (in *instance) collect(cli Client) {
+	in.stdout = &executor.LimitedBuffer{Limit: int(in.limits.OutputLimit << 10)}
+	in.stdout.OnOverflow = func() {
+		_ = cli.ContainerKill(context.Background(), in.id, "KILL")
 	}
+	go func() {
+		_, err := stdcopy.StdCopy(in.stdout, in.stderr, in.stream.Reader)
+		in.copyDone <- err
+	}()
+}
+
+// wait 运行期间采样内存，等待程序结束，超时则强制结束，返回运行结果
+func (in *instance) wait(cli Client) (executor.Result, error) {
+	var result executor.Result
+	var err error
+	ctx := context.Background()
 
-	//获取执行结果
-	result.ExecResult, err = getLogs(resp, cli)
+	memCtx, stopMem := context.WithCancel(ctx)
+	memory := watchMemory(memCtx, cli, in.id)
+	//墙钟超时多等待 grace，容器启动不计入程序的运行时间
+	_, result.TimedOut, err = waitContainer(ctx, cli, in.id, time.Duration(in.limits.TimeLimit)*time.Millisecond+in.grace)
+	stopMem()
 	if err != nil {
-		log.Println("get logs error", err)
-		return Result{}, err
+		log.Println("wait container error", err)
+		return executor.Result{}, err
 	}
 
-	//删除容器
-	err = cli.ContainerRemove(ctx, resp.ID, container.RemoveOptions{
-		Force: true,
-	})
+	//获取执行状态信息
+	result.ExitCode, result.CostTime, result.OOMKilled, err = getStats(container.CreateResponse{ID: in.id}, cli)
+	if err != nil {
+		log.Println("get stats error", err)
+		return executor.Result{}, err
+	}
+	result.Memory = <-memory
+	//是否超时以程序自身的运行时间判定
+	result.TimedOut = result.TimedOut || result.CostTime > in.limits.TimeLimit
 
+	//容器退出后输出流随之结束
+	select {
+	case err = <-in.copyDone:
+	case <-time.After(streamTimeout):
+		err = errors.New("read output timeout")
+	}
 	if err != nil {
-		log.Println("container remove error", err)
+		log.Println("read output error", err)
+		return executor.Result{}, err
+	}
+	if in.stdout != nil {
+		result.ExecResult = in.stdout.String()
+		result.OutputLimitExceeded = in.stdout.Truncated
+	}
+	if in.forwarded != nil {
+		result.OutputLimitExceeded = in.forwarded.truncated
 	}
+	result.Stderr = in.stderr.String()
 
 	return result, nil
 }
 
-func initClient() (*client.Client, error) {
-	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+// remove 删除容器及其匿名卷
+func (in *instance) remove(cli Client) {
+	err := cli.ContainerRemove(context.Background(), in.id, container.RemoveOptions{
+		Force:         true,
+		RemoveVolumes: true,
+	})
 	if err != nil {
-		return nil, err
+		log.Println("container remove error", err)
+	}
+	if in.stream.Conn != nil {
+		in.stream.Close()
 	}
-	return cli, nil
 }
 
-func initContainer(cli *client.Client, filePath string, input string) (container.CreateResponse, error) {
-	//初始化配置
-	filename := path.Base(filePath)
-	file := path.Join(dstDir, filename)
-
-	var strSlice []string
-	args := input
-	str := strings.Split(args, " ")
-	strSlice = append(strSlice, file)
-	strSlice = append(strSlice, str...)
-	timeout := new(int)
-	*timeout = 10
-	resp, err := cli.ContainerCreate(context.Background(), &container.Config{
-		Image:        imageName,
+func (r *Runner) initContainer(cli Client, image string, cmd []string, limits executor.Limits) (container.CreateResponse, error) {
+	//初始化配置，不分配tty，否则输入会被回显到输出中
+	config := &container.Config{
+		Image:        image,
+		WorkingDir:   WorkDir,
 		AttachStdin:  true,
 		AttachStdout: true,
 		AttachStderr: true,
-		Tty:          true,
-		StopTimeout:  timeout,
+		OpenStdin:    true,
+		StdinOnce:    true,
+		//工作目录使用匿名卷，根文件系统只读时仍可复制编译产物
+		Volumes: map[string]struct{}{WorkDir: {}},
 
-		Cmd: strSlice,
-	}, nil, nil, nil, "")
+		Cmd: cmd,
+	}
+	hc := hostConfig(limits)
+	//输出直接从attach的流中读取，不写入容器日志
+	hc.LogConfig = container.LogConfig{Type: "none"}
+	r.policy.apply(config, hc, false)
+	resp, err := cli.ContainerCreate(context.Background(), config, hc, nil, nil, "")
 	if err != nil {
 		return container.CreateResponse{}, err
 	}
@@ -127,39 +223,95 @@ func initContainer(cli *client.Client, filePath string, input string) (container
 	return resp, nil
 }
 
-func getLogs(resp container.CreateResponse, cli *client.Client) (string, error) {
-
-	// 容器 ID
-	containerID := resp.ID
+// hostConfig 将判题配置转换为容器的 cgroup 限制
+func hostConfig(limits executor.Limits) *container.HostConfig {
+	memory := int64(limits.MemoryLimit) << 10
+	return &container.HostConfig{
+		Resources: container.Resources{
+			Memory:     memory,
+			MemorySwap: memory, //与内存限制相同，即禁用swap
+			PidsLimit:  int64Ptr(pidsLimit),
+			CPUPeriod:  cpuPeriod,
+			CPUQuota:   cpuQuota,
+		},
+	}
+}
 
-	// 获取容器日志
-	logOptions := container.LogsOptions{
-		ShowStdout: true,
-		ShowStderr: true,
+// withDefaults 未配置的限制使用默认值，内存限制不能低于docker允许的最小值
+func withDefaults(l executor.Limits) executor.Limits {
+	l = l.WithDefaults()
+	if l.MemoryLimit < minMemoryLimit {
+		l.MemoryLimit = minMemoryLimit
 	}
-	logReader, err := cli.ContainerLogs(context.Background(), containerID, logOptions)
-	if err != nil {
-		panic(err)
+	return l
+}
+
+// waitContainer 等待容器退出并返回退出码，超过timeout则强制结束容器，timedOut 为 true
+func waitContainer(ctx context.Context, cli Client, containerID string, timeout time.Duration) (exitCode int, timedOut bool, err error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	waitC, errC := cli.ContainerWait(ctx, containerID, container.WaitConditionNotRunning)
+	select {
+	case res := <-waitC:
+		return int(res.StatusCode), false, nil
+	case err = <-errC:
+		return -1, false, err
+	case <-timer.C:
 	}
-	defer logReader.Close()
 
-	// 读取容器日志
-	logs, err := io.ReadAll(logReader)
-	if err != nil {
-		return "", err
+	//超时，结束容器后等待其完全退出
+	if err = cli.ContainerKill(ctx, containerID, "KILL"); err != nil {
+		return -1, true, err
+	}
+	select {
+	case res := <-waitC:
+		return int(res.StatusCode), true, nil
+	case err = <-errC:
+		return -1, true, err
 	}
-	res := strings.ReplaceAll(string(logs), "\r\n", "")
-	return res, nil
 }
 
-func getStats(resp container.CreateResponse, cli *client.Client) (int, int64, uint64, error) {
+// watchMemory 在容器运行期间持续读取统计信息，返回内存使用峰值，单位为kb
+func watchMemory(ctx context.Context, cli Client, containerID string) <-chan uint64 {
+	res := make(chan uint64, 1)
+	go func() {
+		var peak uint64
+		defer func() { res <- peak >> 10 }()
+
+		stats, err := cli.ContainerStats(ctx, containerID, true)
+		if err != nil {
+			return
+		}
+		defer stats.Body.Close()
+
+		decoder := json.NewDecoder(stats.Body)
+		for {
+			var containerStats types.StatsJSON
+			if err := decoder.Decode(&containerStats); err != nil {
+				return
+			}
+			usage := containerStats.MemoryStats.Usage
+			if containerStats.MemoryStats.MaxUsage > usage {
+				usage = containerStats.MemoryStats.MaxUsage
+			}
+			if usage > peak {
+				peak = usage
+			}
+		}
+	}()
+	return res
+}
+
+// getStats 获取退出码、运行时间以及是否因内存超限被结束
+func getStats(resp container.CreateResponse, cli Client) (int, int64, bool, error) {
 	// 容器 ID
 	containerID := resp.ID
 
 	// 获取容器信息
 	inspect, err := cli.ContainerInspect(context.Background(), containerID)
 	if err != nil {
-		return -1, 0, 0, err
+		return -1, 0, false, err
 	}
 
 	//获取程序执行状态
@@ -168,23 +320,15 @@ func getStats(resp container.CreateResponse, cli *client.Client) (int, int64, ui
 	exitCode := inspect.State.ExitCode
 
 	// 计算执行时间
-	startTime, err := time.Parse(time.RFC3339, startedAt)
+	startTime, err := time.Parse(time.RFC3339Nano, startedAt)
+	if err != nil {
+		return -1, 0, false, err
+	}
 	finishTime, err := time.Parse(time.RFC3339Nano, finishedAt)
 	if err != nil {
-		return -1, 0, 0, err
+		return -1, 0, false, err
 	}
-	executionTime := finishTime.Sub(startTime) * time.Nanosecond
-	miniseconds := executionTime.Milliseconds()
+	miniseconds := finishTime.Sub(startTime).Milliseconds()
 
-	stats, err := cli.ContainerStats(context.Background(), containerID, false)
-
-	defer stats.Body.Close()
-	// 解析统计信息
-	var containerStats types.StatsJSON
-	if err := json.NewDecoder(stats.Body).Decode(&containerStats); err != nil {
-		log.Fatalf("Error decoding container stats: %v", err)
-	}
-	memoryUsage := containerStats.MemoryStats.Usage
-	// 获取内存使用量
-	return exitCode, miniseconds, memoryUsage, nil
+	return exitCode, miniseconds, inspect.State.OOMKilled, nil
 }
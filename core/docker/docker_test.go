@@ -1,23 +1,175 @@
 package docker
 
 import (
+	"archive/tar"
+	"bytes"
 	"fmt"
-	"github.com/xissg/userManageSystem/core/sanbox"
+	"github.com/xissg/userManageSystem/core/executor"
 	"github.com/xissg/userManageSystem/entity/model_question"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
 func TestDocker(t *testing.T) {
-	ctx := &sanbox.JudgeContext{
-		ID:        "test",
-		Language:  "Go",
-		Code:      "package main\nimport (\n\"fmt\"\n\"os\"\n)\nfunc main() {\nargs := os.Args\nfor _, arg := range args {\n fmt.Println(arg+\"\\n\")\n }\npanic(\"error\")\n}",
-		JudgeCase: []model_question.JudgeCase{{Input: "a b", Output: "a b"}, {Input: "c d", Output: "c d"}},
-	}
+	judgeCase := []model_question.JudgeCase{{Input: "a b", Output: "a b"}, {Input: "c d", Output: "c d"}}
 
-	docker, err := Docker("", ctx.JudgeCase[0].Input)
+	docker, err := NewRunner(DefaultSecurityPolicy()).Run("my-golang-image", t.TempDir(), []string{"./main"}, judgeCase[0].Input, executor.Limits{TimeLimit: 1000, MemoryLimit: 64 << 10})
 	if err != nil {
-		return
+		t.Skip("docker unavailable:", err)
 	}
 	fmt.Println(docker)
 }
+
+func TestExtractTar(t *testing.T) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	files := map[string]string{"app/main": "binary", "app/../../../escape": "evil"}
+	for name, content := range files {
+		_ = tw.WriteHeader(&tar.Header{Name: name, Mode: 0755, Size: int64(len(content)), Typeflag: tar.TypeReg})
+		_, _ = tw.Write([]byte(content))
+	}
+	_ = tw.Close()
+
+	dir := t.TempDir()
+	err := extractTar(bytes.NewReader(buf.Bytes()), dir, artifactMax)
+	if err == nil {
+		t.Fatal("path escaping the directory should be rejected")
+	}
+
+	buf.Reset()
+	tw = tar.NewWriter(&buf)
+	_ = tw.WriteHeader(&tar.Header{Name: "app/main", Mode: 0755, Size: 6, Typeflag: tar.TypeReg})
+	_, _ = tw.Write([]byte("binary"))
+	_ = tw.Close()
+	if err = extractTar(&buf, dir, artifactMax); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(filepath.Join(dir, "main"))
+	if err != nil || string(content) != "binary" {
+		t.Fatalf("unexpected artifact %q: %v", content, err)
+	}
+}
+
+func TestRunSeparatesStderr(t *testing.T) {
+	cli := newFakeClient()
+	cli.stdout = "3\n"
+	cli.stderr = "debug: a=1 b=2\n"
+	res, err := NewRunnerWithClient(cli, DefaultSecurityPolicy()).Run("my-golang-image", t.TempDir(), []string{"./main"}, "1 2", executor.Limits{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.ExecResult != "3\n" || res.Stderr != "debug: a=1 b=2\n" {
+		t.Errorf("stdout %q, stderr %q", res.ExecResult, res.Stderr)
+	}
+	if res.OutputLimitExceeded {
+		t.Error("output is within the limit")
+	}
+	if cli.hostConfig[0].LogConfig.Type != "none" {
+		t.Errorf("container logs should be disabled, got %q", cli.hostConfig[0].LogConfig.Type)
+	}
+}
+
+func TestRunOutputLimit(t *testing.T) {
+	cli := newFakeClient()
+	cli.stdout = strings.Repeat("a", 2<<10)
+	cli.stderr = strings.Repeat("e", executor.StderrMax+100)
+	res, err := NewRunnerWithClient(cli, DefaultSecurityPolicy()).Run("my-golang-image", t.TempDir(), []string{"./main"}, "", executor.Limits{OutputLimit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.OutputLimitExceeded || !cli.killed {
+		t.Errorf("program should be killed after exceeding the output limit: %+v", res)
+	}
+	if len(res.ExecResult) != 1<<10 || len(res.Stderr) != executor.StderrMax {
+		t.Errorf("output should be capped, got stdout %d stderr %d bytes", len(res.ExecResult), len(res.Stderr))
+	}
+}
+
+func TestInteract(t *testing.T) {
+	cli := newFakeClient()
+	//用户程序读取一个数后输出它的两倍
+	cli.programs["user-image"] = func(stdin io.Reader, stdout io.Writer) int64 {
+		var n int
+		if _, err := fmt.Fscan(stdin, &n); err != nil {
+			return 1
+		}
+		_, _ = fmt.Fprintln(stdout, n*2)
+		return 0
+	}
+	//交互程序给出一个数并检查回答
+	cli.programs["interactor-image"] = func(stdin io.Reader, stdout io.Writer) int64 {
+		_, _ = fmt.Fprintln(stdout, 21)
+		var n int
+		if _, err := fmt.Fscan(stdin, &n); err != nil || n != 42 {
+			return 1
+		}
+		return 0
+	}
+	runner := NewRunnerWithClient(cli, DefaultSecurityPolicy())
+	user, interactor, err := runner.Interact(
+		executor.Process{Image: "user-image", Dir: t.TempDir(), Cmd: []string{"./main"}},
+		executor.Process{Image: "interactor-image", Dir: t.TempDir(), Cmd: []string{"./interactor"}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.ExitCode != 0 || interactor.ExitCode != 0 {
+		t.Errorf("interaction should succeed, user %+v, interactor %+v", user, interactor)
+	}
+	if user.ExecResult != "" {
+		t.Errorf("forwarded output should not be collected, got %q", user.ExecResult)
+	}
+	if cli.removed != 2 {
+		t.Errorf("both containers should be removed, removed %d", cli.removed)
+	}
+}
+
+func TestInteractOutputLimit(t *testing.T) {
+	cli := newFakeClient()
+	//用户程序不停输出，超过输出限制
+	cli.programs["user-image"] = func(stdin io.Reader, stdout io.Writer) int64 {
+		_, _ = io.WriteString(stdout, strings.Repeat("1\n", 2<<10))
+		return 0
+	}
+	cli.programs["interactor-image"] = func(stdin io.Reader, stdout io.Writer) int64 {
+		_, _ = io.Copy(io.Discard, stdin)
+		return 0
+	}
+	runner := NewRunnerWithClient(cli, DefaultSecurityPolicy())
+	user, interactor, err := runner.Interact(
+		executor.Process{Image: "user-image", Dir: t.TempDir(), Cmd: []string{"./main"}, Limits: executor.Limits{OutputLimit: 1}},
+		executor.Process{Image: "interactor-image", Dir: t.TempDir(), Cmd: []string{"./interactor"}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !user.OutputLimitExceeded || !cli.killed {
+		t.Errorf("user program should be killed after exceeding the output limit: %+v", user)
+	}
+	if interactor.OutputLimitExceeded {
+		t.Errorf("interactor output is within the limit: %+v", interactor)
+	}
+}
+
+func TestRunTimedOutByCostTime(t *testing.T) {
+	cli := newFakeClient()
+	//模拟的容器运行了 15ms
+	res, err := NewRunnerWithClient(cli, DefaultSecurityPolicy()).Run("my-golang-image", t.TempDir(), []string{"./main"}, "", executor.Limits{TimeLimit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.TimedOut || cli.killed {
+		t.Errorf("program over the time limit should time out without being killed: %+v", res)
+	}
+
+	res, err = NewRunnerWithClient(newFakeClient(), DefaultSecurityPolicy()).Run("my-golang-image", t.TempDir(), []string{"./main"}, "", executor.Limits{TimeLimit: 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.TimedOut {
+		t.Errorf("program within the time limit should not time out: %+v", res)
+	}
+}
@@ -0,0 +1,85 @@
+package docker
+
+import (
+	"github.com/xissg/userManageSystem/core/executor"
+	"log"
+)
+
+// Executor 基于docker的执行后端，编译和运行都在隔离的容器中进行，不在本机执行用户代码
+type Executor struct {
+	runner *Runner
+	pool   *Pool //为空时每个用例单独创建容器
+}
+
+// NewExecutor pool 为空时每个用例单独创建容器
+func NewExecutor(runner *Runner, pool *Pool) *Executor {
+	return &Executor{
+		runner: runner,
+		pool:   pool,
+	}
+}
+
+// NewExecutorFromConfig 按 conf/sandbox.yaml 中的安全策略和容器池配置创建，并预热 images 的容器
+func NewExecutorFromConfig(images ...string) *Executor {
+	policy := LoadSecurityPolicy()
+	grace := executor.LoadTimeGrace()
+	runner := NewRunner(policy)
+	runner.grace = grace
+
+	config := LoadPoolConfig()
+	if !config.Enabled {
+		return NewExecutor(runner, nil)
+	}
+	pool, err := NewPool(policy, config)
+	if err != nil {
+		log.Printf("create container pool %v, run each case in a new container", err)
+		return NewExecutor(runner, nil)
+	}
+	pool.grace = grace
+	pool.Warm(images...)
+	return NewExecutor(runner, pool)
+}
+
+func (e *Executor) Compile(image string, dir string, cmd []string) (string, int, error) {
+	return e.runner.Compile(image, dir, cmd)
+}
+
+func (e *Executor) Prepare(image string, dir string, limits executor.Limits) (executor.Session, error) {
+	if e.pool == nil {
+		return &containerSession{runner: e.runner, image: image, dir: dir, limits: limits}, nil
+	}
+	lease, err := e.pool.Acquire(image, dir, limits)
+	if err != nil {
+		return nil, err
+	}
+	return lease, nil
+}
+
+// containerSession 每个用例创建一个新的容器
+type containerSession struct {
+	runner *Runner
+	image  string
+	dir    string
+	limits executor.Limits
+}
+
+func (s *containerSession) Run(cmd []string, input string) (executor.Result, error) {
+	return s.runner.Run(s.image, s.dir, cmd, input, s.limits)
+}
+
+func (s *containerSession) Close() error {
+	return nil
+}
+
+// Close 删除容器池中预热的容器
+func (e *Executor) Close() error {
+	if e.pool == nil {
+		return nil
+	}
+	return e.pool.Close()
+}
+
+// Interact 交互题的两个程序需要同时运行，不使用容器池
+func (e *Executor) Interact(user executor.Process, interactor executor.Process) (executor.Result, executor.Result, error) {
+	return e.runner.Interact(user, interactor)
+}
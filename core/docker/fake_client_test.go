@@ -0,0 +1,264 @@
+package docker
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/pkg/stdcopy"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fakeClient 模拟docker守护进程，记录创建容器时的配置
+type fakeClient struct {
+	mu         sync.Mutex
+	configs    []*container.Config
+	hostConfig []*container.HostConfig
+	removed    int
+	killed     bool
+	killC      chan struct{}
+	stdin      string
+	stdinDone  chan struct{}
+	created    int
+	execs      []types.ExecConfig
+	updates    []container.UpdateConfig
+	dead       map[string]bool //已经退出的容器
+
+	//按镜像模拟的程序，读写容器的标准输入输出并返回退出码
+	programs  map[string]func(stdin io.Reader, stdout io.Writer) int64
+	exited    map[string]chan struct{}
+	exitCodes map[string]int64
+
+	//模拟的运行结果
+	exitCode  int64
+	hang      bool
+	delay     time.Duration //程序读完输入后运行的时间
+	oomKilled bool
+	logs      string //编译容器的日志
+	stdout    string
+	stderr    string
+}
+
+func newFakeClient() *fakeClient {
+	return &fakeClient{
+		killC:     make(chan struct{}),
+		dead:      make(map[string]bool),
+		programs:  make(map[string]func(io.Reader, io.Writer) int64),
+		exited:    make(map[string]chan struct{}),
+		exitCodes: make(map[string]int64),
+	}
+}
+
+// fakeConn 关闭写端即关闭整个连接
+type fakeConn struct {
+	net.Conn
+}
+
+func (c fakeConn) CloseWrite() error {
+	return c.Conn.Close()
+}
+
+// ContainerAttach 读完标准输入后按docker的多路复用格式写出标准输出和标准错误
+func (f *fakeClient) ContainerAttach(ctx context.Context, container string, options container.AttachOptions) (types.HijackedResponse, error) {
+	var id int
+	_, _ = fmt.Sscanf(container, "fake-%d", &id)
+	f.mu.Lock()
+	program := f.programs[f.configs[id-1].Image]
+	f.mu.Unlock()
+	if program != nil {
+		return f.run(container, program), nil
+	}
+	return f.attach(false), nil
+}
+
+// run 模拟的程序与判题同时读写标准输入输出，程序返回后容器退出
+func (f *fakeClient) run(id string, program func(io.Reader, io.Writer) int64) types.HijackedResponse {
+	client, server := net.Pipe()
+	outR, outW := io.Pipe()
+	exited := make(chan struct{})
+	f.mu.Lock()
+	f.exited[id] = exited
+	f.mu.Unlock()
+	go func() {
+		code := program(server, stdcopy.NewStdWriter(outW, stdcopy.Stdout))
+		_ = server.Close()
+		_ = outW.Close()
+		f.mu.Lock()
+		f.exitCodes[id] = code
+		f.mu.Unlock()
+		close(exited)
+	}()
+	return types.HijackedResponse{Conn: fakeConn{client}, Reader: bufio.NewReader(outR)}
+}
+
+// attach 模拟程序的标准输入输出，hang 为 true 时输出流在容器被结束后才关闭
+func (f *fakeClient) attach(hang bool) types.HijackedResponse {
+	client, server := net.Pipe()
+	outR, outW := io.Pipe()
+	done := make(chan struct{})
+	f.mu.Lock()
+	f.stdinDone = done
+	f.mu.Unlock()
+	go func() {
+		data, _ := io.ReadAll(server)
+		f.mu.Lock()
+		f.stdin = string(data)
+		delay := f.delay
+		f.mu.Unlock()
+		time.Sleep(delay)
+		_, _ = stdcopy.NewStdWriter(outW, stdcopy.Stdout).Write([]byte(f.stdout))
+		_, _ = stdcopy.NewStdWriter(outW, stdcopy.Stderr).Write([]byte(f.stderr))
+		if hang {
+			<-f.killC
+		}
+		_ = outW.Close()
+		close(done)
+	}()
+	return types.HijackedResponse{Conn: fakeConn{client}, Reader: bufio.NewReader(outR)}
+}
+
+func (f *fakeClient) ContainerExecCreate(ctx context.Context, container string, config types.ExecConfig) (types.IDResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.execs = append(f.execs, config)
+	return types.IDResponse{ID: fmt.Sprintf("exec-%d", len(f.execs))}, nil
+}
+
+func (f *fakeClient) ContainerExecAttach(ctx context.Context, execID string, config types.ExecStartCheck) (types.HijackedResponse, error) {
+	return f.attach(f.hang), nil
+}
+
+func (f *fakeClient) ContainerExecInspect(ctx context.Context, execID string) (types.ContainerExecInspect, error) {
+	return types.ContainerExecInspect{ExecID: execID, ExitCode: int(f.exitCode)}, nil
+}
+
+func (f *fakeClient) ContainerUpdate(ctx context.Context, containerID string, updateConfig container.UpdateConfig) (container.ContainerUpdateOKBody, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.updates = append(f.updates, updateConfig)
+	return container.ContainerUpdateOKBody{}, nil
+}
+
+func (f *fakeClient) ContainerCreate(ctx context.Context, config *container.Config, hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig, platform *ocispec.Platform, containerName string) (container.CreateResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.configs = append(f.configs, config)
+	f.hostConfig = append(f.hostConfig, hostConfig)
+	f.created++
+	return container.CreateResponse{ID: fmt.Sprintf("fake-%d", f.created)}, nil
+}
+
+func (f *fakeClient) ContainerStart(ctx context.Context, containerID string, options container.StartOptions) error {
+	return nil
+}
+
+func (f *fakeClient) ContainerWait(ctx context.Context, containerID string, condition container.WaitCondition) (<-chan container.WaitResponse, <-chan error) {
+	waitC := make(chan container.WaitResponse, 1)
+	errC := make(chan error, 1)
+	f.mu.Lock()
+	exited := f.exited[containerID]
+	f.mu.Unlock()
+	go func() {
+		if exited != nil {
+			<-exited
+			f.mu.Lock()
+			waitC <- container.WaitResponse{StatusCode: f.exitCodes[containerID]}
+			f.mu.Unlock()
+			return
+		}
+		if f.hang {
+			select {
+			case <-f.killC:
+				waitC <- container.WaitResponse{StatusCode: 137}
+			case <-ctx.Done():
+				errC <- ctx.Err()
+			}
+			return
+		}
+		//模拟程序读完标准输入后退出
+		f.mu.Lock()
+		stdinDone := f.stdinDone
+		f.mu.Unlock()
+		if stdinDone != nil {
+			<-stdinDone
+		}
+		waitC <- container.WaitResponse{StatusCode: f.exitCode}
+	}()
+	return waitC, errC
+}
+
+func (f *fakeClient) ContainerKill(ctx context.Context, containerID, signal string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.killed {
+		f.killed = true
+		close(f.killC)
+	}
+	return nil
+}
+
+func (f *fakeClient) ContainerInspect(ctx context.Context, containerID string) (types.ContainerJSON, error) {
+	start := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	exitCode := int(f.exitCode)
+	if f.killed {
+		exitCode = 137
+	}
+	if code, ok := f.exitCodes[containerID]; ok {
+		exitCode = int(code)
+	}
+	return types.ContainerJSON{ContainerJSONBase: &types.ContainerJSONBase{State: &types.ContainerState{
+		Running:    !f.dead[containerID],
+		ExitCode:   exitCode,
+		OOMKilled:  f.oomKilled,
+		StartedAt:  start.Format(time.RFC3339Nano),
+		FinishedAt: start.Add(15 * time.Millisecond).Format(time.RFC3339Nano),
+	}}}, nil
+}
+
+func (f *fakeClient) ContainerStats(ctx context.Context, containerID string, stream bool) (types.ContainerStats, error) {
+	body := `{"memory_stats":{"usage":2097152}}`
+	return types.ContainerStats{Body: io.NopCloser(strings.NewReader(body))}, nil
+}
+
+func (f *fakeClient) ContainerLogs(ctx context.Context, container string, options container.LogsOptions) (io.ReadCloser, error) {
+	var buf bytes.Buffer
+	_, _ = stdcopy.NewStdWriter(&buf, stdcopy.Stdout).Write([]byte(f.logs))
+	return io.NopCloser(&buf), nil
+}
+
+func (f *fakeClient) ContainerRemove(ctx context.Context, containerID string, options container.RemoveOptions) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.removed++
+	f.dead[containerID] = true
+	return nil
+}
+
+func (f *fakeClient) CopyToContainer(ctx context.Context, containerID, dstPath string, content io.Reader, options types.CopyToContainerOptions) error {
+	_, err := io.Copy(io.Discard, content)
+	return err
+}
+
+func (f *fakeClient) CopyFromContainer(ctx context.Context, containerID, srcPath string) (io.ReadCloser, types.ContainerPathStat, error) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	_ = tw.WriteHeader(&tar.Header{Name: "app/main", Mode: 0755, Size: 4, Typeflag: tar.TypeReg})
+	_, _ = tw.Write([]byte("main"))
+	_ = tw.Close()
+	return io.NopCloser(&buf), types.ContainerPathStat{}, nil
+}
+
+func (f *fakeClient) Close() error {
+	return nil
+}
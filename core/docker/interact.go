@@ -0,0 +1,105 @@
+package docker
+
+import (
+	"context"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/xissg/userManageSystem/core/executor"
+	"io"
+	"log"
+)
+
+// Interact 在两个容器中分别运行用户程序和交互程序，一方的标准输出转发到另一方的标准输入
+// 两个容器各自按自己的限制结束，转发的输出不计入结果
+func (r *Runner) Interact(user executor.Process, interactor executor.Process) (executor.Result, executor.Result, error) {
+	ctx := context.Background()
+	cli, err := r.newClient()
+	if err != nil {
+		log.Printf("client initialization error: %v", err)
+		return executor.Result{}, executor.Result{}, err
+	}
+	defer cli.Close()
+
+	u, err := r.create(cli, user.Image, user.Dir, user.Cmd, withDefaults(user.Limits))
+	if err != nil {
+		return executor.Result{}, executor.Result{}, err
+	}
+	defer u.remove(cli)
+	i, err := r.create(cli, interactor.Image, interactor.Dir, interactor.Cmd, withDefaults(interactor.Limits))
+	if err != nil {
+		return executor.Result{}, executor.Result{}, err
+	}
+	defer i.remove(cli)
+
+	u.forward(cli, i.stream)
+	i.forward(cli, u.stream)
+
+	//先启动交互程序，用户程序读取输入时对方已经在运行
+	for _, in := range []*instance{i, u} {
+		if err = cli.ContainerStart(ctx, in.id, container.StartOptions{}); err != nil {
+			log.Println("container start:", err)
+			return executor.Result{}, executor.Result{}, err
+		}
+	}
+
+	var interactorResult executor.Result
+	var interactorErr error
+	done := make(chan struct{})
+	go func() {
+		interactorResult, interactorErr = i.wait(cli)
+		close(done)
+	}()
+	userResult, err := u.wait(cli)
+	<-done
+	if err != nil {
+		return executor.Result{}, executor.Result{}, err
+	}
+	if interactorErr != nil {
+		return executor.Result{}, executor.Result{}, interactorErr
+	}
+	return userResult, interactorResult, nil
+}
+
+// forward 将容器的标准输出转发到dst的标准输入，输出结束后关闭dst的标准输入
+// 转发的输出同样受输出限制，超过限制时结束容器
+func (in *instance) forward(cli Client, dst types.HijackedResponse) {
+	in.forwarded = &forwardWriter{w: dst.Conn, limit: int(in.limits.OutputLimit << 10)}
+	in.forwarded.onOverflow = func() {
+		_ = cli.ContainerKill(context.Background(), in.id, "KILL")
+	}
+	go func() {
+		_, err := stdcopy.StdCopy(in.forwarded, in.stderr, in.stream.Reader)
+		_ = dst.CloseWrite()
+		in.copyDone <- err
+	}()
+}
+
+// forwardWriter 对方已经退出或输出超过限制时丢弃剩余的输出，保证本容器的输出流能被读完
+type forwardWriter struct {
+	w          io.Writer
+	err        error
+	limit      int
+	written    int
+	truncated  bool
+	onOverflow func()
+}
+
+func (f *forwardWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	if remain := f.limit - f.written; remain < len(p) {
+		if !f.truncated && f.onOverflow != nil {
+			f.onOverflow()
+		}
+		f.truncated = true
+		if remain <= 0 {
+			return n, nil
+		}
+		p = p[:remain]
+	}
+	f.written += len(p)
+	if f.err == nil {
+		_, f.err = f.w.Write(p)
+	}
+	return n, nil
+}
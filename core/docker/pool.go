@@ -0,0 +1,500 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/pkg/archive"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/xissg/userManageSystem/core/executor"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PoolConfig 容器池配置
+type PoolConfig struct {
+	//是否启用容器池，关闭时每个用例单独创建容器
+	Enabled bool `mapstructure:"enabled"`
+	//每个镜像保持的空闲容器数
+	Size int `mapstructure:"size"`
+	//空闲容器的健康检查间隔，为 0 则只在取出时检查
+	CheckInterval time.Duration `mapstructure:"check_interval"`
+}
+
+// DefaultPoolConfig 默认每个镜像预热 2 个容器
+func DefaultPoolConfig() PoolConfig {
+	return PoolConfig{
+		Enabled:       true,
+		Size:          2,
+		CheckInterval: 30 * time.Second,
+	}
+}
+
+var (
+	poolConfigOnce sync.Once
+	poolConfig     PoolConfig
+)
+
+// LoadPoolConfig 读取 conf/sandbox.yaml 中的 pool 配置，读取失败时使用默认配置
+func LoadPoolConfig() PoolConfig {
+	poolConfigOnce.Do(func() {
+		poolConfig = DefaultPoolConfig()
+		if err := executor.LoadConfig("pool", &poolConfig); err != nil {
+			log.Printf("read sandbox config %v, use default pool config", err)
+			poolConfig = DefaultPoolConfig()
+		}
+	})
+	return poolConfig
+}
+
+// 空闲容器只运行一个常驻进程，用例通过 exec 在其中执行
+var idleCmd = []string{"sleep", "infinity"}
+
+// ErrPoolClosed 容器池已关闭
+var ErrPoolClosed = errors.New("container pool closed")
+
+// Pool 按镜像保存预先创建好的运行容器，同一次提交的所有用例在同一个容器中执行
+// 容器只服务一次提交，归还时直接删除并在后台补充新的容器，避免提交之间互相影响
+type Pool struct {
+	cli    Client
+	policy SecurityPolicy
+	size   int
+	grace  time.Duration //超过时间限制后再等待的时间
+
+	mu      sync.Mutex
+	idle    map[string][]string //镜像对应的空闲容器
+	filling map[string]bool     //正在补充空闲容器的镜像
+	closed  bool
+	done    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// NewPool 连接本机的docker创建容器池
+func NewPool(policy SecurityPolicy, config PoolConfig) (*Pool, error) {
+	cli, err := initClient()
+	if err != nil {
+		return nil, err
+	}
+	return NewPoolWithClient(cli, policy, config), nil
+}
+
+// NewPoolWithClient 使用指定的客户端，主要用于测试
+func NewPoolWithClient(cli Client, policy SecurityPolicy, config PoolConfig) *Pool {
+	p := &Pool{
+		cli:     cli,
+		policy:  policy,
+		size:    config.Size,
+		grace:   executor.DefaultTimeGrace,
+		idle:    make(map[string][]string),
+		filling: make(map[string]bool),
+		done:    make(chan struct{}),
+	}
+	if config.CheckInterval > 0 {
+		p.wg.Add(1)
+		go p.checkLoop(config.CheckInterval)
+	}
+	return p
+}
+
+// Warm 为镜像预先创建空闲容器
+func (p *Pool) Warm(images ...string) {
+	for _, image := range images {
+		p.refill(image)
+	}
+}
+
+// Acquire 取出一个空闲容器并复制dir中的编译产物，limits 在整次提交中保持不变
+func (p *Pool) Acquire(image string, dir string, limits executor.Limits) (*Lease, error) {
+	lease := &Lease{
+		pool:   p,
+		image:  image,
+		dir:    dir,
+		limits: withDefaults(limits),
+	}
+	if err := lease.prepare(); err != nil {
+		return nil, err
+	}
+	return lease, nil
+}
+
+// Close 停止健康检查并删除所有空闲容器
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	close(p.done)
+	idle := p.idle
+	p.idle = make(map[string][]string)
+	p.mu.Unlock()
+
+	p.wg.Wait()
+	for _, ids := range idle {
+		for _, id := range ids {
+			p.remove(id)
+		}
+	}
+	return p.cli.Close()
+}
+
+// take 取出一个健康的空闲容器，没有时同步创建
+func (p *Pool) take(image string) (string, error) {
+	for {
+		p.mu.Lock()
+		if p.closed {
+			p.mu.Unlock()
+			return "", ErrPoolClosed
+		}
+		ids := p.idle[image]
+		if len(ids) == 0 {
+			p.mu.Unlock()
+			return p.create(image)
+		}
+		id := ids[len(ids)-1]
+		p.idle[image] = ids[:len(ids)-1]
+		p.mu.Unlock()
+
+		if p.healthy(id) {
+			return id, nil
+		}
+		log.Printf("pool container %s is unhealthy, discard it", id)
+		p.remove(id)
+	}
+}
+
+// create 创建并启动一个空闲容器，隔离策略与单独运行的容器相同
+func (p *Pool) create(image string) (string, error) {
+	ctx := context.Background()
+	config := &container.Config{
+		Image:      image,
+		WorkingDir: WorkDir,
+		Entrypoint: idleCmd,
+		Volumes:    map[string]struct{}{WorkDir: {}},
+	}
+	hc := hostConfig(withDefaults(executor.Limits{}))
+	hc.LogConfig = container.LogConfig{Type: "none"}
+	p.policy.apply(config, hc, false)
+	resp, err := p.cli.ContainerCreate(ctx, config, hc, nil, nil, "")
+	if err != nil {
+		return "", err
+	}
+	if err = p.cli.ContainerStart(ctx, resp.ID, container.StartOptions{}); err != nil {
+		p.remove(resp.ID)
+		return "", err
+	}
+	return resp.ID, nil
+}
+
+// refill 在后台补充空闲容器到 size 个
+func (p *Pool) refill(image string) {
+	p.mu.Lock()
+	if p.closed || p.filling[image] || len(p.idle[image]) >= p.size {
+		p.mu.Unlock()
+		return
+	}
+	p.filling[image] = true
+	p.wg.Add(1)
+	p.mu.Unlock()
+
+	go func() {
+		defer p.wg.Done()
+		defer func() {
+			p.mu.Lock()
+			delete(p.filling, image)
+			p.mu.Unlock()
+		}()
+
+		for {
+			p.mu.Lock()
+			full := p.closed || len(p.idle[image]) >= p.size
+			p.mu.Unlock()
+			if full {
+				return
+			}
+
+			id, err := p.create(image)
+			if err != nil {
+				log.Printf("pool create container for %s: %v", image, err)
+				return
+			}
+			p.mu.Lock()
+			if p.closed {
+				p.mu.Unlock()
+				p.remove(id)
+				return
+			}
+			p.idle[image] = append(p.idle[image], id)
+			p.mu.Unlock()
+		}
+	}()
+}
+
+// healthy 容器仍在运行才能继续使用
+func (p *Pool) healthy(id string) bool {
+	inspect, err := p.cli.ContainerInspect(context.Background(), id)
+	if err != nil || inspect.ContainerJSONBase == nil || inspect.State == nil {
+		return false
+	}
+	return inspect.State.Running
+}
+
+// check 删除已经退出的空闲容器并补充
+func (p *Pool) check() {
+	p.mu.Lock()
+	idle := make(map[string][]string, len(p.idle))
+	for image, ids := range p.idle {
+		idle[image] = append([]string{}, ids...)
+	}
+	p.mu.Unlock()
+
+	for image, ids := range idle {
+		for _, id := range ids {
+			if p.healthy(id) {
+				continue
+			}
+			log.Printf("pool container %s is unhealthy, discard it", id)
+			if p.drop(image, id) {
+				p.remove(id)
+			}
+		}
+		p.refill(image)
+	}
+}
+
+func (p *Pool) checkLoop(interval time.Duration) {
+	defer p.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-ticker.C:
+			p.check()
+		}
+	}
+}
+
+// drop 从空闲列表中移除容器，容器已被取出时返回 false
+func (p *Pool) drop(image, id string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	ids := p.idle[image]
+	for i := range ids {
+		if ids[i] == id {
+			p.idle[image] = append(ids[:i], ids[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Pool) remove(id string) {
+	err := p.cli.ContainerRemove(context.Background(), id, container.RemoveOptions{Force: true, RemoveVolumes: true})
+	if err != nil {
+		log.Println("container remove error", err)
+	}
+}
+
+// Lease 一次提交占用的容器，超时或输出超限的用例会导致容器被替换
+type Lease struct {
+	pool   *Pool
+	image  string
+	dir    string
+	limits executor.Limits
+	id     string //当前使用的容器，为空表示需要重新准备
+}
+
+// prepare 取出容器，按提交的限制调整内存上限并复制编译产物
+func (l *Lease) prepare() error {
+	id, err := l.pool.take(l.image)
+	if err != nil {
+		return err
+	}
+	defer l.pool.refill(l.image)
+
+	ctx := context.Background()
+	memory := int64(l.limits.MemoryLimit) << 10
+	_, err = l.pool.cli.ContainerUpdate(ctx, id, container.UpdateConfig{Resources: container.Resources{
+		Memory:     memory,
+		MemorySwap: memory,
+	}})
+	if err != nil {
+		l.pool.remove(id)
+		return err
+	}
+
+	tarReader, err := archive.Tar(l.dir, archive.Uncompressed)
+	if err != nil {
+		l.pool.remove(id)
+		return err
+	}
+	err = l.pool.cli.CopyToContainer(ctx, id, WorkDir, tarReader, types.CopyToContainerOptions{})
+	if err != nil {
+		l.pool.remove(id)
+		return err
+	}
+	l.id = id
+	return nil
+}
+
+// Run 在容器中执行cmd，input 作为程序的标准输入
+func (l *Lease) Run(cmd []string, input string) (executor.Result, error) {
+	if l.id == "" {
+		if err := l.prepare(); err != nil {
+			return executor.Result{}, err
+		}
+	}
+
+	result, broken, err := l.exec(cmd, input)
+	//被强制结束的程序可能留下子进程，后续用例换一个新的容器
+	if broken || err != nil {
+		l.discard()
+	}
+	return result, err
+}
+
+// Close 归还容器，容器不再复用，直接删除
+func (l *Lease) Close() error {
+	l.discard()
+	return nil
+}
+
+func (l *Lease) discard() {
+	if l.id == "" {
+		return
+	}
+	l.pool.remove(l.id)
+	l.id = ""
+}
+
+// exec 执行一个用例，broken 表示容器被强制结束
+func (l *Lease) exec(cmd []string, input string) (result executor.Result, broken bool, err error) {
+	ctx := context.Background()
+	cli := l.pool.cli
+	execResp, err := cli.ContainerExecCreate(ctx, l.id, types.ExecConfig{
+		User:         l.pool.policy.User,
+		AttachStdin:  true,
+		AttachStdout: true,
+		AttachStderr: true,
+		WorkingDir:   WorkDir,
+		Cmd:          cmd,
+	})
+	if err != nil {
+		return executor.Result{}, false, err
+	}
+	stream, err := cli.ContainerExecAttach(ctx, execResp.ID, types.ExecStartCheck{})
+	if err != nil {
+		return executor.Result{}, false, err
+	}
+	defer stream.Close()
+	start := time.Now()
+
+	var killOnce sync.Once
+	kill := func() {
+		killOnce.Do(func() {
+			broken = true
+			_ = cli.ContainerKill(ctx, l.id, "KILL")
+		})
+	}
+	stdout := &executor.LimitedBuffer{Limit: int(l.limits.OutputLimit << 10), OnOverflow: kill}
+	stderr := &executor.LimitedBuffer{Limit: executor.StderrMax}
+	copyDone := make(chan error, 1)
+	go func() {
+		_, err := stdcopy.StdCopy(stdout, stderr, stream.Reader)
+		copyDone <- err
+	}()
+
+	go func() {
+		_, err := io.Copy(stream.Conn, strings.NewReader(input))
+		if err != nil {
+			log.Println("write stdin error", err)
+		}
+		_ = stream.CloseWrite()
+	}()
+
+	//输出流结束即程序退出，超时则结束整个容器
+	memCtx, stopMem := context.WithCancel(ctx)
+	memory := watchMemory(memCtx, cli, l.id)
+	//墙钟超时多等待 grace，exec 和运行时的启动不计入程序的运行时间
+	timer := time.NewTimer(time.Duration(l.limits.TimeLimit)*time.Millisecond + l.pool.grace)
+	defer timer.Stop()
+	select {
+	case err = <-copyDone:
+	case <-timer.C:
+		result.TimedOut = true
+		kill()
+		select {
+		case err = <-copyDone:
+		case <-time.After(streamTimeout):
+			err = errors.New("read output timeout")
+		}
+	}
+	result.CostTime = time.Since(start).Milliseconds()
+	result.TimedOut = result.TimedOut || result.CostTime > l.limits.TimeLimit
+	stopMem()
+	result.Memory = <-memory
+	if err != nil {
+		return executor.Result{}, broken, err
+	}
+
+	result.ExecResult = stdout.String()
+	result.Stderr = stderr.String()
+	result.OutputLimitExceeded = stdout.Truncated
+	if broken {
+		result.ExitCode = 137
+		return result, true, nil
+	}
+
+	result.ExitCode, err = execExitCode(ctx, cli, execResp.ID)
+	if err != nil {
+		return executor.Result{}, false, err
+	}
+	if result.ExitCode == 0 {
+		return result, false, nil
+	}
+	//程序自己也可以以 137 退出，是否超出内存限制以容器记录的OOM为准
+	//OOM时容器中的常驻进程也可能已被结束，容器不再复用
+	state, err := containerState(ctx, cli, l.id)
+	if err != nil {
+		return executor.Result{}, true, err
+	}
+	result.OOMKilled = state.OOMKilled
+	return result, state.OOMKilled || !state.Running, nil
+}
+
+// containerState 查询容器的状态，OOMKilled 在容器中任一进程被内核因内存不足结束后置位
+func containerState(ctx context.Context, cli Client, id string) (*types.ContainerState, error) {
+	inspect, err := cli.ContainerInspect(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if inspect.ContainerJSONBase == nil || inspect.State == nil {
+		return nil, errors.New("container state unavailable")
+	}
+	return inspect.State, nil
+}
+
+// execExitCode 输出流关闭后 exec 可能还没有被标记为结束，短暂等待其退出码
+func execExitCode(ctx context.Context, cli Client, execID string) (int, error) {
+	deadline := time.Now().Add(streamTimeout)
+	for {
+		inspect, err := cli.ContainerExecInspect(ctx, execID)
+		if err != nil {
+			return -1, err
+		}
+		if !inspect.Running {
+			return inspect.ExitCode, nil
+		}
+		if time.Now().After(deadline) {
+			return -1, errors.New("wait exec exit timeout")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
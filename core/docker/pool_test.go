@@ -0,0 +1,299 @@
+package docker
+
+import (
+	"context"
+	"github.com/docker/docker/client"
+	"github.com/xissg/userManageSystem/core/executor"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// waitIdle 等待后台补充的空闲容器数量达到 n
+func waitIdle(t testing.TB, p *Pool, image string, n int) {
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		p.mu.Lock()
+		idle := len(p.idle[image])
+		p.mu.Unlock()
+		if idle >= n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("pool has not been refilled to %d containers", n)
+}
+
+func TestPoolRunsCasesInWarmContainer(t *testing.T) {
+	cli := newFakeClient()
+	cli.stdout = "3"
+	pool := NewPoolWithClient(cli, DefaultSecurityPolicy(), PoolConfig{Size: 1})
+	defer pool.Close()
+	pool.Warm("my-golang-image")
+	waitIdle(t, pool, "my-golang-image", 1)
+
+	lease, err := pool.Acquire("my-golang-image", t.TempDir(), executor.Limits{MemoryLimit: 64 << 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		res, err := lease.Run([]string{"./main"}, "1 2")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.ExecResult != "3" || res.ExitCode != 0 || res.TimedOut {
+			t.Errorf("unexpected result %+v", res)
+		}
+	}
+	if len(cli.execs) != 3 || cli.execs[0].User != "65534:65534" || cli.execs[0].WorkingDir != WorkDir {
+		t.Errorf("cases should be executed in the container as the sandbox user: %+v", cli.execs)
+	}
+	if cli.stdin != "1 2" {
+		t.Errorf("input should be written to stdin, got %q", cli.stdin)
+	}
+	if len(cli.updates) != 1 || cli.updates[0].Memory != 64<<20 || cli.updates[0].MemorySwap != 64<<20 {
+		t.Errorf("memory limit should be applied once per submission: %+v", cli.updates)
+	}
+
+	//归还后容器被删除，池中补充新的容器
+	_ = lease.Close()
+	if cli.removed != 1 {
+		t.Errorf("container should be recycled after the submission, removed %d", cli.removed)
+	}
+	waitIdle(t, pool, "my-golang-image", 1)
+	if cli.created != 2 {
+		t.Errorf("created %d containers, want 2", cli.created)
+	}
+	if entry := cli.configs[0].Entrypoint; len(entry) != 2 || entry[0] != "sleep" {
+		t.Errorf("idle container should only keep a sleeping process, got %v", entry)
+	}
+	if !cli.hostConfig[0].ReadonlyRootfs || cli.hostConfig[0].NetworkMode != "none" {
+		t.Error("pool container should use the security policy")
+	}
+}
+
+func TestPoolHealthCheck(t *testing.T) {
+	cli := newFakeClient()
+	pool := NewPoolWithClient(cli, DefaultSecurityPolicy(), PoolConfig{Size: 2})
+	defer pool.Close()
+	pool.Warm("python:3.12-slim")
+	waitIdle(t, pool, "python:3.12-slim", 2)
+
+	//空闲容器意外退出，检查时被删除并补充
+	pool.mu.Lock()
+	dead := pool.idle["python:3.12-slim"][0]
+	pool.mu.Unlock()
+	cli.mu.Lock()
+	cli.dead[dead] = true
+	cli.mu.Unlock()
+
+	pool.check()
+	waitIdle(t, pool, "python:3.12-slim", 2)
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	for _, id := range pool.idle["python:3.12-slim"] {
+		if id == dead {
+			t.Error("dead container should be discarded")
+		}
+	}
+	if cli.created != 3 {
+		t.Errorf("created %d containers, want 3", cli.created)
+	}
+}
+
+func TestPoolTimeoutReplacesContainer(t *testing.T) {
+	cli := newFakeClient()
+	cli.hang = true
+	pool := NewPoolWithClient(cli, DefaultSecurityPolicy(), PoolConfig{})
+	pool.grace = 10 * time.Millisecond
+	defer pool.Close()
+
+	lease, err := pool.Acquire("my-golang-image", t.TempDir(), executor.Limits{TimeLimit: 50})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lease.Close()
+	res, err := lease.Run([]string{"./main"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.TimedOut || !cli.killed {
+		t.Errorf("program should be killed after the time limit: %+v", res)
+	}
+	if cli.removed != 1 {
+		t.Errorf("killed container should be discarded, removed %d", cli.removed)
+	}
+
+	//下一个用例在新的容器中执行
+	cli.hang = false
+	if _, err = lease.Run([]string{"./main"}, ""); err != nil {
+		t.Fatal(err)
+	}
+	if cli.created != 2 {
+		t.Errorf("created %d containers, want 2", cli.created)
+	}
+}
+
+func TestPoolExitCode137(t *testing.T) {
+	cli := newFakeClient()
+	//程序自己以 137 退出，容器没有发生OOM
+	cli.exitCode = 137
+	pool := NewPoolWithClient(cli, DefaultSecurityPolicy(), PoolConfig{})
+	defer pool.Close()
+
+	lease, err := pool.Acquire("my-golang-image", t.TempDir(), executor.Limits{MemoryLimit: 64 << 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lease.Close()
+	res, err := lease.Run([]string{"./main"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.OOMKilled || res.ExitCode != 137 {
+		t.Errorf("exit code 137 without OOM should be a runtime error: %+v", res)
+	}
+	if cli.removed != 0 {
+		t.Errorf("container should be reused, removed %d", cli.removed)
+	}
+
+	//内核因内存不足结束了程序
+	cli.mu.Lock()
+	cli.oomKilled = true
+	cli.mu.Unlock()
+	res, err = lease.Run([]string{"./main"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.OOMKilled {
+		t.Errorf("program killed by the OOM killer should exceed the memory limit: %+v", res)
+	}
+	if cli.removed != 1 {
+		t.Errorf("container should be discarded after OOM, removed %d", cli.removed)
+	}
+}
+
+func TestPoolTimeGrace(t *testing.T) {
+	cli := newFakeClient()
+	cli.delay = 50 * time.Millisecond
+	pool := NewPoolWithClient(cli, DefaultSecurityPolicy(), PoolConfig{})
+	defer pool.Close()
+
+	lease, err := pool.Acquire("my-golang-image", t.TempDir(), executor.Limits{TimeLimit: 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lease.Close()
+	//程序在宽限时间内结束，不会被强制结束，但运行时间超过了限制
+	res, err := lease.Run([]string{"./main"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.TimedOut || cli.killed || cli.removed != 0 {
+		t.Errorf("program over the time limit should time out without being killed: %+v, killed %v", res, cli.killed)
+	}
+
+	//宽限时间只用于等待，运行时间在限制内时不超时
+	lease.limits.TimeLimit = 1000
+	res, err = lease.Run([]string{"./main"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.TimedOut {
+		t.Errorf("program within the time limit should not time out: %+v", res)
+	}
+}
+
+// 以下基准测试需要本机的docker和镜像，不可用时跳过
+const (
+	benchImage = "python:3.12-slim"
+	benchCases = 50
+)
+
+func benchDir(b *testing.B) string {
+	dir := b.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "main.py"), []byte("print(sum(map(int, input().split())))\n"), 0644)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return dir
+}
+
+func skipWithoutDocker(b *testing.B) {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		b.Skip("docker unavailable:", err)
+	}
+	defer cli.Close()
+	ctx := context.Background()
+	if _, err = cli.Ping(ctx); err != nil {
+		b.Skip("docker unavailable:", err)
+	}
+	if _, _, err = cli.ImageInspectWithRaw(ctx, benchImage); err != nil {
+		b.Skip("image unavailable:", err)
+	}
+}
+
+// BenchmarkPerCaseRun 每个用例单独创建、运行、删除容器
+func BenchmarkPerCaseRun(b *testing.B) {
+	skipWithoutDocker(b)
+	dir := benchDir(b)
+	runner := NewRunner(DefaultSecurityPolicy())
+	for i := 0; i < b.N; i++ {
+		for j := 0; j < benchCases; j++ {
+			if _, err := runner.Run(benchImage, dir, []string{"python3", "main.py"}, "1 2", executor.Limits{}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+// BenchmarkPoolRun 每次提交从池中取出一个预热的容器，用例通过 exec 执行
+func BenchmarkPoolRun(b *testing.B) {
+	skipWithoutDocker(b)
+	dir := benchDir(b)
+	pool, err := NewPool(DefaultSecurityPolicy(), PoolConfig{Size: 2})
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer pool.Close()
+	pool.Warm(benchImage)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		lease, err := pool.Acquire(benchImage, dir, executor.Limits{})
+		if err != nil {
+			b.Fatal(err)
+		}
+		for j := 0; j < benchCases; j++ {
+			if _, err = lease.Run([]string{"python3", "main.py"}, "1 2"); err != nil {
+				b.Fatal(err)
+			}
+		}
+		_ = lease.Close()
+	}
+}
+
+func TestExecutorSession(t *testing.T) {
+	cli := newFakeClient()
+	cli.stdout = "3"
+	runner := NewRunnerWithClient(cli, DefaultSecurityPolicy())
+	pool := NewPoolWithClient(cli, DefaultSecurityPolicy(), PoolConfig{})
+	defer pool.Close()
+
+	for _, e := range []*Executor{NewExecutor(runner, nil), NewExecutor(runner, pool)} {
+		session, err := e.Prepare("my-golang-image", t.TempDir(), executor.Limits{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := session.Run([]string{"./main"}, "1 2")
+		if err != nil || res.ExecResult != "3" {
+			t.Errorf("pool %v: got %+v, %v", e.pool != nil, res, err)
+		}
+		_ = session.Close()
+	}
+	if len(cli.execs) != 1 {
+		t.Errorf("only the pooled session should use exec, got %d", len(cli.execs))
+	}
+}
@@ -0,0 +1,74 @@
+package docker
+
+import (
+	"github.com/docker/docker/api/types/container"
+	"github.com/xissg/userManageSystem/core/executor"
+	"log"
+	"sync"
+)
+
+// SecurityPolicy 判题容器的隔离策略
+type SecurityPolicy struct {
+	//禁用网络，容器只有回环网卡
+	NetworkDisabled bool `mapstructure:"network_disabled"`
+	//根文件系统只读，用户程序只能写工作目录和临时目录
+	ReadonlyRootfs bool `mapstructure:"readonly_rootfs"`
+	//挂载到 /tmp 的 tmpfs 大小，如 16m，为空则不挂载
+	TmpfsSize string `mapstructure:"tmpfs_size"`
+	//需要去掉的 capability，ALL 表示全部
+	CapDrop []string `mapstructure:"cap_drop"`
+	//禁止通过 setuid 等方式提升权限
+	NoNewPrivileges bool `mapstructure:"no_new_privileges"`
+	//运行用户程序的用户，格式为 uid:gid
+	User string `mapstructure:"user"`
+}
+
+// DefaultSecurityPolicy 默认使用最严格的隔离策略
+func DefaultSecurityPolicy() SecurityPolicy {
+	return SecurityPolicy{
+		NetworkDisabled: true,
+		ReadonlyRootfs:  true,
+		TmpfsSize:       "16m",
+		CapDrop:         []string{"ALL"},
+		NoNewPrivileges: true,
+		User:            "65534:65534",
+	}
+}
+
+var (
+	policyOnce sync.Once
+	policy     SecurityPolicy
+)
+
+// LoadSecurityPolicy 读取 conf/sandbox.yaml 中的 security 配置，读取失败时使用默认策略
+func LoadSecurityPolicy() SecurityPolicy {
+	policyOnce.Do(func() {
+		policy = DefaultSecurityPolicy()
+		if err := executor.LoadConfig("security", &policy); err != nil {
+			log.Printf("read sandbox config %v, use default security policy", err)
+			policy = DefaultSecurityPolicy()
+		}
+	})
+	return policy
+}
+
+// apply 将策略写入容器配置，编译容器需要写入编译缓存，只应用网络和权限相关的限制
+func (p SecurityPolicy) apply(config *container.Config, hostConfig *container.HostConfig, compile bool) {
+	if p.NetworkDisabled {
+		config.NetworkDisabled = true
+		hostConfig.NetworkMode = "none"
+	}
+	hostConfig.CapDrop = p.CapDrop
+	if p.NoNewPrivileges {
+		hostConfig.SecurityOpt = append(hostConfig.SecurityOpt, "no-new-privileges")
+	}
+	if compile {
+		return
+	}
+
+	hostConfig.ReadonlyRootfs = p.ReadonlyRootfs
+	if p.TmpfsSize != "" {
+		hostConfig.Tmpfs = map[string]string{"/tmp": "rw,nosuid,nodev,size=" + p.TmpfsSize}
+	}
+	config.User = p.User
+}
@@ -0,0 +1,123 @@
+package docker
+
+import (
+	"github.com/xissg/userManageSystem/core/executor"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunSecurityPolicy(t *testing.T) {
+	cli := newFakeClient()
+	cli.stdout = "3"
+	runner := NewRunnerWithClient(cli, DefaultSecurityPolicy())
+
+	res, err := runner.Run("my-golang-image", t.TempDir(), []string{"./main"}, "1 2", executor.Limits{TimeLimit: 1000, MemoryLimit: 64 << 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.ExecResult != "3" || res.CostTime != 15 || res.Memory != 2048 {
+		t.Errorf("unexpected result %+v", res)
+	}
+	if cli.stdin != "1 2" {
+		t.Errorf("input should be written to stdin, got %q", cli.stdin)
+	}
+
+	config, hc := cli.configs[0], cli.hostConfig[0]
+	if !config.NetworkDisabled || hc.NetworkMode != "none" {
+		t.Error("network should be disabled")
+	}
+	if !hc.ReadonlyRootfs {
+		t.Error("root filesystem should be read-only")
+	}
+	if !strings.Contains(hc.Tmpfs["/tmp"], "size=16m") {
+		t.Errorf("unexpected tmpfs %v", hc.Tmpfs)
+	}
+	if len(hc.CapDrop) != 1 || hc.CapDrop[0] != "ALL" {
+		t.Errorf("unexpected cap drop %v", hc.CapDrop)
+	}
+	if len(hc.SecurityOpt) != 1 || hc.SecurityOpt[0] != "no-new-privileges" {
+		t.Errorf("unexpected security opt %v", hc.SecurityOpt)
+	}
+	if config.User != "65534:65534" {
+		t.Errorf("program should not run as root, got user %q", config.User)
+	}
+	if config.Tty || !config.OpenStdin || !config.StdinOnce {
+		t.Errorf("stdin should be open without tty: %+v", config)
+	}
+	if _, ok := config.Volumes[WorkDir]; !ok {
+		t.Error("work dir should be a writable volume")
+	}
+	if hc.Memory != 64<<20 || hc.MemorySwap != hc.Memory || *hc.PidsLimit != pidsLimit {
+		t.Errorf("unexpected resources %+v", hc.Resources)
+	}
+	if cli.removed != 1 {
+		t.Errorf("container should be removed, removed %d", cli.removed)
+	}
+}
+
+func TestRunCustomSecurityPolicy(t *testing.T) {
+	cli := newFakeClient()
+	policy := SecurityPolicy{TmpfsSize: "", User: "1000:1000"}
+	_, err := NewRunnerWithClient(cli, policy).Run("python:3.12-slim", t.TempDir(), []string{"python3", "main.py"}, "", executor.Limits{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config, hc := cli.configs[0], cli.hostConfig[0]
+	if config.NetworkDisabled || hc.NetworkMode == "none" || hc.ReadonlyRootfs || hc.Tmpfs != nil || hc.SecurityOpt != nil {
+		t.Errorf("disabled policies should not be applied: %+v", hc)
+	}
+	if config.User != "1000:1000" {
+		t.Errorf("unexpected user %q", config.User)
+	}
+	if hc.Memory != executor.DefaultMemoryLimit<<10 {
+		t.Errorf("default memory limit expected, got %d", hc.Memory)
+	}
+}
+
+func TestCompileSecurityPolicy(t *testing.T) {
+	cli := newFakeClient()
+	dir := t.TempDir()
+	_, exitCode, err := NewRunnerWithClient(cli, DefaultSecurityPolicy()).Compile("my-golang-image", dir, []string{"go", "build"})
+	if err != nil || exitCode != 0 {
+		t.Fatalf("compile failed: %d %v", exitCode, err)
+	}
+	if _, err = os.Stat(filepath.Join(dir, "main")); err != nil {
+		t.Errorf("artifact should be copied back: %v", err)
+	}
+
+	config, hc := cli.configs[0], cli.hostConfig[0]
+	if hc.NetworkMode != "none" || len(hc.CapDrop) == 0 || len(hc.SecurityOpt) == 0 {
+		t.Errorf("compile container should be isolated: %+v", hc)
+	}
+	if hc.ReadonlyRootfs || config.User != "" {
+		t.Error("compile container needs a writable build cache")
+	}
+}
+
+func TestRunTimeLimit(t *testing.T) {
+	cli := newFakeClient()
+	cli.hang = true
+	res, err := NewRunnerWithClient(cli, DefaultSecurityPolicy()).Run("my-golang-image", t.TempDir(), []string{"./main"}, "", executor.Limits{TimeLimit: 50})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.TimedOut || !cli.killed {
+		t.Errorf("program should be killed after the time limit: %+v", res)
+	}
+}
+
+func TestRunMemoryLimit(t *testing.T) {
+	cli := newFakeClient()
+	cli.exitCode = 137
+	cli.oomKilled = true
+	res, err := NewRunnerWithClient(cli, DefaultSecurityPolicy()).Run("my-golang-image", t.TempDir(), []string{"./main"}, "", executor.Limits{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.OOMKilled || res.TimedOut {
+		t.Errorf("oom killed program expected: %+v", res)
+	}
+}
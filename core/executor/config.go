@@ -0,0 +1,66 @@
+package executor
+
+import (
+	"github.com/spf13/viper"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 可选的执行后端
+const (
+	Docker = "docker" //在隔离的docker容器中编译运行，线上使用
+	Local  = "local"  //在本机进程中编译运行，用于开发和CI
+)
+
+var (
+	configOnce    sync.Once
+	sandboxConfig *viper.Viper
+	configErr     error
+)
+
+// LoadConfig 读取 conf/sandbox.yaml 中的 key 配置到 out，只读取一次配置文件
+func LoadConfig(key string, out interface{}) error {
+	configOnce.Do(func() {
+		sandboxConfig = viper.New()
+		sandboxConfig.AddConfigPath("./conf")
+		sandboxConfig.SetConfigName("sandbox")
+		sandboxConfig.SetConfigType("yaml")
+		configErr = sandboxConfig.ReadInConfig()
+	})
+	if configErr != nil {
+		return configErr
+	}
+	return sandboxConfig.UnmarshalKey(key, out)
+}
+
+// LoadBackend 读取 executor 配置选择执行后端，未配置时使用docker
+func LoadBackend() string {
+	var backend string
+	if err := LoadConfig("executor", &backend); err != nil {
+		log.Printf("read sandbox config %v, use docker executor", err)
+		return Docker
+	}
+	backend = strings.ToLower(backend)
+	if backend == "" {
+		return Docker
+	}
+	return backend
+}
+
+// DefaultTimeGrace 超过时间限制后再等待的时间，容器和运行时的启动不计入用户程序的运行时间
+const DefaultTimeGrace = time.Second
+
+// LoadTimeGrace 读取 time_grace 配置，读取失败或未配置时使用默认值
+func LoadTimeGrace() time.Duration {
+	grace := DefaultTimeGrace
+	if err := LoadConfig("time_grace", &grace); err != nil {
+		log.Printf("read sandbox config %v, use default time grace", err)
+		return DefaultTimeGrace
+	}
+	if grace < 0 {
+		return DefaultTimeGrace
+	}
+	return grace
+}
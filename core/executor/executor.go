@@ -0,0 +1,106 @@
+package executor
+
+import "bytes"
+
+// Executor 编译和运行用户代码的后端，可以是docker容器，也可以是本机进程
+type Executor interface {
+	//Compile 在dir目录中执行编译命令，编译产物留在dir中，返回编译器输出和退出码，err 仅表示后端本身的异常
+	Compile(image string, dir string, cmd []string) (string, int, error)
+	//Prepare 准备运行dir中编译产物的环境，同一次提交的所有用例共用返回的 Session
+	Prepare(image string, dir string, limits Limits) (Session, error)
+}
+
+// Session 一次提交的运行环境，用完需要 Close
+type Session interface {
+	//Run 执行cmd，input 作为程序的标准输入，超出限制时需要强制结束程序
+	Run(cmd []string, input string) (Result, error)
+	Close() error
+}
+
+// Process 交互题中需要同时运行的一个程序
+type Process struct {
+	Image  string   //运行程序的镜像，本机后端忽略
+	Dir    string   //编译产物所在目录
+	Cmd    []string //运行命令
+	Limits Limits
+}
+
+// Interactor 支持交互题的执行后端
+type Interactor interface {
+	//Interact 同时运行用户程序和交互程序，用户程序的标准输出连接交互程序的标准输入，反之亦然
+	//交互程序的标准输出不会出现在结果中，ExecResult 为空
+	Interact(user Process, interactor Process) (Result, Result, error)
+}
+
+type Result struct {
+	ExitCode   int
+	ExecResult string //程序的标准输出，只有它会和答案比较
+	Stderr     string //程序的标准错误，最多保留 StderrMax 字节
+	CostTime   int64  //单位为ms
+	Memory     uint64 //单位为kb，运行期间的峰值
+	TimedOut   bool   //超过时间限制被强制结束
+	OOMKilled  bool   //超过内存限制被结束
+
+	OutputLimitExceeded bool //标准输出超过限制被强制结束
+}
+
+// Limits 运行用户程序的资源限制，单位与 model_question.JudgeConfig 一致
+type Limits struct {
+	TimeLimit   int64  //单位为ms
+	MemoryLimit uint64 //单位为kb
+	OutputLimit uint64 //单位为kb
+}
+
+const (
+	DefaultTimeLimit   = 10000     //未配置时间限制时的默认值，单位为ms
+	DefaultMemoryLimit = 256 << 10 //未配置内存限制时的默认值，单位为kb
+	DefaultOutputLimit = 1 << 10   //未配置输出限制时的默认值，单位为kb
+	StderrMax          = 4 << 10   //标准错误最多保留 4KB
+)
+
+// WithDefaults 未配置的限制使用默认值
+func (l Limits) WithDefaults() Limits {
+	if l.TimeLimit <= 0 {
+		l.TimeLimit = DefaultTimeLimit
+	}
+	if l.MemoryLimit == 0 {
+		l.MemoryLimit = DefaultMemoryLimit
+	}
+	if l.OutputLimit == 0 {
+		l.OutputLimit = DefaultOutputLimit
+	}
+	return l
+}
+
+// LimitedBuffer 超出上限的内容直接丢弃，第一次超出时调用 OnOverflow
+// 不嵌入 bytes.Buffer，否则 io.Copy 会通过 ReadFrom 绕过上限
+type LimitedBuffer struct {
+	buf        bytes.Buffer
+	Limit      int
+	Truncated  bool
+	OnOverflow func()
+}
+
+func (b *LimitedBuffer) Write(p []byte) (int, error) {
+	n := len(p)
+	if remain := b.Limit - b.buf.Len(); remain < len(p) {
+		if !b.Truncated && b.OnOverflow != nil {
+			b.OnOverflow()
+		}
+		b.Truncated = true
+		if remain <= 0 {
+			return n, nil
+		}
+		p = p[:remain]
+	}
+	b.buf.Write(p)
+	return n, nil
+}
+
+func (b *LimitedBuffer) String() string {
+	return b.buf.String()
+}
+
+func (b *LimitedBuffer) Len() int {
+	return b.buf.Len()
+}
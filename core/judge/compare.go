@@ -0,0 +1,74 @@
+package judge
+
+import (
+	"github.com/xissg/userManageSystem/common/constant"
+	"github.com/xissg/userManageSystem/entity/model_question"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// defaultEpsilon float 方式未配置误差时的默认值
+const defaultEpsilon = 1e-6
+
+// compareOutput 按题目配置的比较方式判断用户输出是否正确，判题程序方式不在这里处理
+func compareOutput(config model_question.JudgeConfig, output, answer string) bool {
+	switch config.CompareMode {
+	case constant.CompareLine:
+		return normalizeLines(output) == normalizeLines(answer)
+	case constant.CompareWhitespace:
+		return compareTokens(output, answer, func(a, b string) bool { return a == b })
+	case constant.CompareFloat:
+		epsilon := config.Epsilon
+		if epsilon <= 0 {
+			epsilon = defaultEpsilon
+		}
+		return compareTokens(output, answer, func(a, b string) bool { return floatEqual(a, b, epsilon) })
+	default:
+		//未配置比较方式的旧题目保持完全一致的比较
+		return output == answer
+	}
+}
+
+// normalizeLines 去掉行尾空白和末尾的空行
+func normalizeLines(s string) string {
+	lines := strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+	for i := range lines {
+		lines[i] = strings.TrimRight(lines[i], " \t\r")
+	}
+	return strings.TrimRight(strings.Join(lines, "\n"), "\n")
+}
+
+// compareTokens 按空白拆分后逐个比较
+func compareTokens(output, answer string, equal func(a, b string) bool) bool {
+	got, want := strings.Fields(output), strings.Fields(answer)
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if !equal(got[i], want[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// floatEqual 两边都是数字时允许 epsilon 的绝对或相对误差，否则要求完全一致
+func floatEqual(a, b string, epsilon float64) bool {
+	if a == b {
+		return true
+	}
+	x, err := strconv.ParseFloat(a, 64)
+	if err != nil {
+		return false
+	}
+	y, err := strconv.ParseFloat(b, 64)
+	if err != nil {
+		return false
+	}
+	if math.IsNaN(x) || math.IsNaN(y) || math.IsInf(x, 0) || math.IsInf(y, 0) {
+		return false
+	}
+	diff := math.Abs(x - y)
+	return diff <= epsilon || diff <= epsilon*math.Abs(y)
+}
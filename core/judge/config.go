@@ -0,0 +1,27 @@
+package judge
+
+import (
+	"github.com/spf13/viper"
+	"sync"
+)
+
+var (
+	configOnce  sync.Once
+	judgeConfig *viper.Viper
+	configErr   error
+)
+
+// loadConfig 读取 conf/judge.yaml 中的 key 配置到 out，只读取一次配置文件
+func loadConfig(key string, out interface{}) error {
+	configOnce.Do(func() {
+		judgeConfig = viper.New()
+		judgeConfig.AddConfigPath("./conf")
+		judgeConfig.SetConfigName("judge")
+		judgeConfig.SetConfigType("yaml")
+		configErr = judgeConfig.ReadInConfig()
+	})
+	if configErr != nil {
+		return configErr
+	}
+	return judgeConfig.UnmarshalKey(key, out)
+}
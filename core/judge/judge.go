@@ -1,99 +1,226 @@
 package judge
 
 import (
+	"errors"
+	"fmt"
 	"github.com/xissg/userManageSystem/common/constant"
 	"github.com/xissg/userManageSystem/core/sanbox"
 	"github.com/xissg/userManageSystem/entity/model_question"
-	mysql2 "github.com/xissg/userManageSystem/service/mysql"
+	"github.com/xissg/userManageSystem/service/progress"
+	"gorm.io/gorm"
 	"log"
+	"time"
 )
 
+// QuestionStore 判题读取题目并记录通过人数，由 mysql.QuestionService 实现
+type QuestionStore interface {
+	GetQuestion(id string) (model_question.Question, error)
+	RecordAccept(questionId, userId string) error
+}
+
+// ResultStore 判题领取提交并保存结果，由 mysql.QuestionSubmitService 实现
+type ResultStore interface {
+	GetSubmitQuestion(submitId string) (model_question.QuestionSubmit, error)
+	ClaimSubmit(submitId string) (bool, error)
+	ReleaseSubmit(submitId string) (bool, error)
+	TouchSubmit(submitId string) (bool, error)
+	UpdateSubmitInStatus(request model_question.CommonQuestionSubmitRequest, statuses []int) (bool, error)
+}
+
 type JudgeService struct {
-	questionService       *mysql2.QuestionService
-	questionSubmitService *mysql2.QuestionSubmitService
+	questionService       QuestionStore
+	questionSubmitService ResultStore
+	progress              progress.Publisher
+	newSanBox             func() *sanbox.SanBox //测试时替换沙箱的执行后端
+	heartbeat             time.Duration         //判题期间刷新更新时间的间隔
 }
 
-func NewJudgeService(questionService *mysql2.QuestionService, questionSubmitService *mysql2.QuestionSubmitService) *JudgeService {
+// NewJudgeService 判题进度通过 publisher 发送给订阅提交的客户端
+func NewJudgeService(questionService QuestionStore, questionSubmitService ResultStore, publisher progress.Publisher) *JudgeService {
 	return &JudgeService{
 		questionService:       questionService,
 		questionSubmitService: questionSubmitService,
+		progress:              publisher,
+		newSanBox:             sanbox.NewSanBox,
+		//恢复任务只处理超过 Threshold 没有刷新的提交，间隔需要明显小于 Threshold
+		heartbeat: LoadReaperConfig().Threshold / 3,
 	}
 }
-func (s *JudgeService) Judge(submitId string) {
+
+// Judge 判题并保存结果，返回的错误为数据库、沙箱等系统错误，可以重试
+// 提交或题目已经不存在、提交已经判过时不返回错误
+func (s *JudgeService) Judge(submitId string) error {
 	//判断提交判题状态
 	submit, err := s.questionSubmitService.GetSubmitQuestion(submitId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Printf("submit %s not found", submitId)
+		return nil
+	}
 	if err != nil {
-		return
+		return err
 	}
 	if submit.Status != constant.WAITING {
-		return
+		return nil
 	}
 	res, err := s.questionService.GetQuestion(submit.QuestionId)
-	if err != nil || res.ID == "" {
-		return
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Printf("question %s of submit %s not found", submit.QuestionId, submitId)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if res.ID == "" {
+		return nil
 	}
 
-	var update model_question.UpdateQuestionSubmitRequest
-	var judgeInfo model_question.JudgeInfo
-
-	update.ID = submit.ID
-	update.Status = constant.JUDGING
-	judgeContext := sanbox.ToJudgeContext(&submit, &res)
+	//领取提交并保存判题中状态，已被其他判题进程领取时跳过
+	claimed, err := s.questionSubmitService.ClaimSubmit(submitId)
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		log.Printf("submit %s is already claimed", submitId)
+		return nil
+	}
+	p := s.submitProgress(submitId)
+	p.publish(progress.Event{Stage: progress.StageJudging})
+
+	stop := s.keepAlive(submitId)
+	update, err := s.judge(&submit, &res, p)
+	stop()
+	var saved bool
+	if err == nil {
+		saved, err = s.saveResult(update, constant.JUDGING)
+	}
+	//系统错误时放回等待状态，由调用方重试
+	if err != nil {
+		s.release(submitId)
+		p.publish(progress.Event{Stage: progress.StageQueued})
+		return err
+	}
+	//判题期间提交被恢复任务重置，结果由重新领取的判题进程保存
+	if !saved {
+		log.Printf("submit %s is no longer judging, drop the result", submitId)
+		return nil
+	}
+	//结果已经保存，计数更新失败时由重新统计命令修正，不再重试判题
+	if update.Status == constant.SUCCESS {
+		if err = s.questionService.RecordAccept(submit.QuestionId, submit.UserId); err != nil {
+			log.Printf("record accept of submit %s: %v", submitId, err)
+		}
+	}
+	p.finished(update)
+	return nil
+}
 
-	//开始沙箱判题
-	box := sanbox.NewSanBox()
+// judge 在沙箱中运行提交并生成判题结果，编译失败时将编译器输出返回给用户，沙箱的其他错误交给调用方重试
+func (s *JudgeService) judge(submit *model_question.QuestionSubmit, res *model_question.Question, p sanbox.Progress) (model_question.UpdateQuestionSubmitRequest, error) {
+	update := model_question.UpdateQuestionSubmitRequest{ID: submit.ID}
+	judgeContext := sanbox.ToJudgeContext(submit, res)
+	if judgeContext == nil {
+		return update, errors.New("invalid judge context")
+	}
+	config := judgeContext.JudgeConfig
+
+	//开始沙箱判题，配置了遇到失败即停止时不再运行之后的用例
+	box := s.newSanBox().WithProgress(p)
+	if config.StopOnFailure {
+		box.StopWhen(func(i int, result sanbox.CaseResult) bool {
+			return caseVerdict(config, result, judgeContext.ExpectedOutput(i)).Message != constant.Accepted
+		})
+	}
 	result, err := box.Start(judgeContext)
-
-	//沙箱初始化异常处理
 	if err != nil {
+		var compileErr *sanbox.CompileError
+		if !errors.As(err, &compileErr) {
+			return update, fmt.Errorf("sandbox: %w", err)
+		}
 		update.Status = constant.FAIL
-		judgeInfo.Message = constant.CompileError
-		update.JudgeInfo = append(update.JudgeInfo, judgeInfo)
+		update.JudgeInfo = []model_question.JudgeInfo{{Message: constant.CompileError, Detail: compileErr.Output}}
+		update.JudgeSummary = model_question.JudgeSummary{Verdict: constant.CompileError, Total: len(judgeContext.JudgeCase)}
+		_, update.FullScore = score(judgeContext.JudgeCase, nil, config.SubtaskRule)
 
-		return
+		return update, nil
 	}
 
-	//程序执行内存溢出，超时等
-	question := model_question.QuestionToReturnQuestion(res)
-
+	//程序执行内存溢出，超时等，由容器的超时结束和OOM标记判断
+	update.JudgeInfo = []model_question.JudgeInfo{}
 	for i := range result {
-		switch result[i].ExitCode {
-		case -1:
-			update.Status = constant.FAIL
-			judgeInfo.Message = constant.SystemError
-			update.JudgeInfo = append(update.JudgeInfo, judgeInfo)
-		case 0:
-			if result[i].CostTime > question.JudgeConfig.TimeLimit {
-				update.Status = constant.FAIL
-				judgeInfo.Message = constant.TimeLimitExceeded
-
-			} else if result[i].Memory > question.JudgeConfig.MemoryLimit {
-				update.Status = constant.FAIL
-				judgeInfo.Message = constant.MemoryLimitExceeded
-
-			} else if result[i].ExecResult != question.Answer[i] {
-				update.Status = constant.FAIL
-				judgeInfo.Message = constant.WrongAnswer
-
-			} else {
-				update.Status = constant.SUCCESS
-				judgeInfo.Message = constant.Accepted
+		update.JudgeInfo = append(update.JudgeInfo, caseVerdict(config, result[i], judgeContext.ExpectedOutput(i)))
+	}
+	update.JudgeSummary = summarize(update.JudgeInfo, len(judgeContext.JudgeCase))
+	update.Score, update.FullScore = score(judgeContext.JudgeCase, update.JudgeInfo, config.SubtaskRule)
+	update.Status = submitStatus(update.JudgeSummary)
 
+	return update, nil
+}
+
+// Fail 重试次数用完后将提交标记为 System Error
+// 其他投递已经保存了最终结果时不覆盖
+func (s *JudgeService) Fail(submitId string) error {
+	update := systemError(submitId)
+	saved, err := s.saveResult(update, constant.WAITING, constant.JUDGING)
+	if err != nil {
+		return err
+	}
+	if !saved {
+		log.Printf("submit %s is already finished", submitId)
+		return nil
+	}
+	s.submitProgress(submitId).finished(update)
+	return nil
+}
+
+func (s *JudgeService) submitProgress(submitId string) submitProgress {
+	return submitProgress{publisher: s.progress, submitId: submitId}
+}
+
+// keepAlive 判题期间定期刷新提交的更新时间，使恢复任务只处理判题进程已经停止的提交
+// 返回的 stop 结束刷新并等待刷新的协程退出
+func (s *JudgeService) keepAlive(submitId string) (stop func()) {
+	done := make(chan struct{})
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		ticker := time.NewTicker(s.heartbeat)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			ok, err := s.questionSubmitService.TouchSubmit(submitId)
+			if err != nil {
+				log.Printf("refresh submit %s: %v", submitId, err)
+				continue
+			}
+			if !ok {
+				log.Printf("submit %s is no longer judging", submitId)
+				return
 			}
-		default:
-			update.Status = constant.FAIL
-			judgeInfo.Message = constant.RuntimeError
 		}
-		judgeInfo.Time = result[i].CostTime
-		judgeInfo.Memory = result[i].Memory
-		update.JudgeInfo = append(update.JudgeInfo, judgeInfo)
+	}()
+	return func() {
+		close(done)
+		<-exited
 	}
+}
 
-	//判题成功更新数据
-	common := model_question.UpdateQSToCommonQS(update)
-	err = s.questionSubmitService.UpdateSubmitQuestion(common)
+// release 将判题中的提交放回等待状态
+func (s *JudgeService) release(submitId string) {
+	if _, err := s.questionSubmitService.ReleaseSubmit(submitId); err != nil {
+		log.Printf("release submit %s: %v", submitId, err)
+	}
+}
 
+// saveResult 提交仍处于 statuses 之一时保存判题结果，返回是否保存
+func (s *JudgeService) saveResult(update model_question.UpdateQuestionSubmitRequest, statuses ...int) (bool, error) {
+	common := model_question.UpdateQSToCommonQS(update)
+	saved, err := s.questionSubmitService.UpdateSubmitInStatus(common, statuses)
 	if err != nil {
 		log.Printf("update submit question %v", err)
 	}
+	return saved, err
 }
@@ -0,0 +1,345 @@
+package judge
+
+import (
+	"github.com/xissg/userManageSystem/common/constant"
+	"github.com/xissg/userManageSystem/core/executor"
+	"github.com/xissg/userManageSystem/core/sanbox"
+	"github.com/xissg/userManageSystem/entity/model_question"
+	"github.com/xissg/userManageSystem/service/progress"
+	"testing"
+	"time"
+)
+
+func TestCompareOutput(t *testing.T) {
+	tests := []struct {
+		mode           string
+		output, answer string
+		want           bool
+	}{
+		{"", "3\n", "3", false},
+		{"", "3", "3", true},
+		{constant.CompareLine, "3\n", "3", true},
+		{constant.CompareLine, "1 2 \r\n3\r\n\r\n", "1 2\n3", true},
+		{constant.CompareLine, "1\n2", "12", false},
+		{constant.CompareLine, "1  2", "1 2", false},
+		{constant.CompareExact, "3\n", "3", false},
+		{constant.CompareExact, "3", "3", true},
+		{constant.CompareWhitespace, "1  2\n3\n", "1 2 3", true},
+		{constant.CompareWhitespace, "1 2", "1 2 3", false},
+		{constant.CompareFloat, "0.3333334 2\n", "0.333333 2", true},
+		{constant.CompareFloat, "1000000.5", "1000000", true},
+		{constant.CompareFloat, "0.34", "0.333333", false},
+		{constant.CompareFloat, "NaN", "NaN", true},
+		{constant.CompareFloat, "yes 1.0", "no 1.0", false},
+	}
+	for _, tt := range tests {
+		config := model_question.JudgeConfig{CompareMode: tt.mode}
+		if got := compareOutput(config, tt.output, tt.answer); got != tt.want {
+			t.Errorf("%s: compareOutput(%q, %q) = %v, want %v", tt.mode, tt.output, tt.answer, got, tt.want)
+		}
+	}
+
+	config := model_question.JudgeConfig{CompareMode: constant.CompareFloat, Epsilon: 0.1}
+	if !compareOutput(config, "0.34", "0.3") {
+		t.Error("epsilon should be configurable")
+	}
+}
+
+func TestCaseVerdict(t *testing.T) {
+	config := model_question.JudgeConfig{CompareMode: constant.CompareLine}
+	finished := func(output string) sanbox.CaseResult {
+		return sanbox.CaseResult{Result: executor.Result{ExecResult: output, CostTime: 5, Memory: 100}}
+	}
+	tests := []struct {
+		config model_question.JudgeConfig
+		result sanbox.CaseResult
+		want   string
+	}{
+		{config, finished("3\n"), constant.Accepted},
+		{config, finished("4\n"), constant.WrongAnswer},
+		{config, sanbox.CaseResult{Result: executor.Result{TimedOut: true, ExitCode: 137}}, constant.TimeLimitExceeded},
+		{config, sanbox.CaseResult{Result: executor.Result{ExitCode: 1, Stderr: "panic"}}, constant.RuntimeError},
+		{config, sanbox.CaseResult{Result: executor.Result{ExitCode: -1}}, constant.SystemError},
+		{config, sanbox.CaseResult{Result: executor.Result{ExitCode: 141}, Check: sanbox.CheckRejected}, constant.WrongAnswer},
+		//判题程序检查之前不比较输出
+		{model_question.JudgeConfig{CompareMode: constant.CompareChecker}, finished("4\n"), constant.Accepted},
+		{model_question.JudgeConfig{CompareMode: constant.CompareChecker}, sanbox.CaseResult{Result: executor.Result{}, Check: sanbox.CheckFailed}, constant.SystemError},
+	}
+	for i, tt := range tests {
+		if got := caseVerdict(tt.config, tt.result, "3"); got.Message != tt.want {
+			t.Errorf("case %d: got %s, want %s", i, got.Message, tt.want)
+		}
+	}
+	if info := caseVerdict(config, finished("3"), "3"); info.Time != 5 || info.Memory != 100 {
+		t.Errorf("time and memory should be recorded, got %+v", info)
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	info := func(message string, time int64, memory uint64) model_question.JudgeInfo {
+		return model_question.JudgeInfo{Message: message, Time: time, Memory: memory}
+	}
+	tests := []struct {
+		name   string
+		infos  []model_question.JudgeInfo
+		total  int
+		want   model_question.JudgeSummary
+		status int
+	}{
+		{
+			name:   "all accepted",
+			infos:  []model_question.JudgeInfo{info(constant.Accepted, 10, 300), info(constant.Accepted, 30, 200)},
+			total:  2,
+			want:   model_question.JudgeSummary{Verdict: constant.Accepted, Time: 30, Memory: 300, Passed: 2, Total: 2},
+			status: constant.SUCCESS,
+		},
+		{
+			name:   "first failure is the verdict",
+			infos:  []model_question.JudgeInfo{info(constant.WrongAnswer, 10, 100), info(constant.Accepted, 20, 100), info(constant.TimeLimitExceeded, 1000, 100)},
+			total:  3,
+			want:   model_question.JudgeSummary{Verdict: constant.WrongAnswer, Time: 1000, Memory: 100, Passed: 1, Total: 3},
+			status: constant.FAIL,
+		},
+		{
+			name:   "system error wins",
+			infos:  []model_question.JudgeInfo{info(constant.RuntimeError, 1, 1), info(constant.SystemError, 0, 0)},
+			total:  2,
+			want:   model_question.JudgeSummary{Verdict: constant.SystemError, Time: 1, Memory: 1, Total: 2},
+			status: constant.FAIL,
+		},
+		{
+			name:   "stopped early",
+			infos:  []model_question.JudgeInfo{info(constant.Accepted, 1, 1), info(constant.RuntimeError, 2, 2)},
+			total:  5,
+			want:   model_question.JudgeSummary{Verdict: constant.RuntimeError, Time: 2, Memory: 2, Passed: 1, Total: 5},
+			status: constant.FAIL,
+		},
+	}
+	for _, tt := range tests {
+		got := summarize(tt.infos, tt.total)
+		if got != tt.want {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+		if status := submitStatus(got); status != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.name, status, tt.status)
+		}
+	}
+}
+
+func TestScore(t *testing.T) {
+	accepted := model_question.JudgeInfo{Message: constant.Accepted}
+	wrong := model_question.JudgeInfo{Message: constant.WrongAnswer}
+	cases := []model_question.JudgeCase{
+		{Score: 10},
+		{Score: 20, Subtask: 1},
+		{Score: 20, Subtask: 1},
+		{Score: 25, Subtask: 2},
+		{Score: 25, Subtask: 2},
+	}
+	tests := []struct {
+		name        string
+		infos       []model_question.JudgeInfo
+		rule        string
+		earned, max int
+	}{
+		{"sum", []model_question.JudgeInfo{accepted, accepted, wrong, accepted, accepted}, constant.SubtaskSum, 80, 100},
+		{"default rule is sum", []model_question.JudgeInfo{wrong, accepted, wrong, wrong, accepted}, "", 45, 100},
+		{"min of group", []model_question.JudgeInfo{accepted, accepted, wrong, accepted, accepted}, constant.SubtaskMin, 35, 55},
+		{"cases not run earn nothing", []model_question.JudgeInfo{accepted, accepted}, constant.SubtaskMin, 10, 55},
+	}
+	for _, tt := range tests {
+		earned, full := score(cases, tt.infos, tt.rule)
+		if earned != tt.earned || full != tt.max {
+			t.Errorf("%s: got %d/%d, want %d/%d", tt.name, earned, full, tt.earned, tt.max)
+		}
+	}
+
+	//没有配置分数的题目不计分
+	if earned, full := score([]model_question.JudgeCase{{}, {}}, []model_question.JudgeInfo{accepted, accepted}, ""); earned != 0 || full != 0 {
+		t.Errorf("unscored question got %d/%d", earned, full)
+	}
+}
+
+// fakeQuestionStore 返回固定的题目，记录通过的次数
+type fakeQuestionStore struct {
+	question model_question.Question
+	accepts  int
+}
+
+func (s *fakeQuestionStore) GetQuestion(id string) (model_question.Question, error) {
+	return s.question, nil
+}
+
+func (s *fakeQuestionStore) RecordAccept(questionId, userId string) error {
+	s.accepts++
+	return nil
+}
+
+// fakeResultStore 查询总是返回待判题的提交，模拟判题进程读到的旧状态，领取和保存结果按 status 比较
+type fakeResultStore struct {
+	submit  model_question.QuestionSubmit
+	status  int
+	touches int
+	updates []model_question.CommonQuestionSubmitRequest
+	//领取成功后调用，模拟判题期间提交被其他进程修改
+	afterClaim func(s *fakeResultStore)
+}
+
+func (s *fakeResultStore) GetSubmitQuestion(submitId string) (model_question.QuestionSubmit, error) {
+	return s.submit, nil
+}
+
+func (s *fakeResultStore) ClaimSubmit(submitId string) (bool, error) {
+	if s.status != constant.WAITING {
+		return false, nil
+	}
+	s.status = constant.JUDGING
+	if s.afterClaim != nil {
+		s.afterClaim(s)
+	}
+	return true, nil
+}
+
+func (s *fakeResultStore) ReleaseSubmit(submitId string) (bool, error) {
+	if s.status != constant.JUDGING {
+		return false, nil
+	}
+	s.status = constant.WAITING
+	return true, nil
+}
+
+func (s *fakeResultStore) TouchSubmit(submitId string) (bool, error) {
+	if s.status != constant.JUDGING {
+		return false, nil
+	}
+	s.touches++
+	return true, nil
+}
+
+func (s *fakeResultStore) UpdateSubmitInStatus(request model_question.CommonQuestionSubmitRequest, statuses []int) (bool, error) {
+	if !containsStatus(statuses, s.status) {
+		return false, nil
+	}
+	s.status = request.Status
+	s.updates = append(s.updates, request)
+	return true, nil
+}
+
+// fakeExecutor 记录编译和运行的次数，程序运行 delay 后输出 3
+type fakeExecutor struct {
+	compiled int
+	ran      int
+	delay    time.Duration
+}
+
+func (e *fakeExecutor) Compile(image string, dir string, cmd []string) (string, int, error) {
+	e.compiled++
+	return "", 0, nil
+}
+
+func (e *fakeExecutor) Prepare(image string, dir string, limits executor.Limits) (executor.Session, error) {
+	return fakeSession{executor: e}, nil
+}
+
+type fakeSession struct {
+	executor *fakeExecutor
+}
+
+func (s fakeSession) Run(cmd []string, input string) (executor.Result, error) {
+	s.executor.ran++
+	time.Sleep(s.executor.delay)
+	return executor.Result{ExecResult: "3\n"}, nil
+}
+
+func (s fakeSession) Close() error {
+	return nil
+}
+
+func newTestJudgeService() (*JudgeService, *fakeQuestionStore, *fakeResultStore, *fakeExecutor) {
+	questions := &fakeQuestionStore{question: model_question.Question{
+		ID:          "q1",
+		JudgeCase:   `[{"input":"1 2","output":"3"}]`,
+		JudgeConfig: `{"input_mode":"stdin","compare_mode":"line"}`,
+	}}
+	submits := &fakeResultStore{status: constant.WAITING, submit: model_question.QuestionSubmit{
+		ID:         "s1",
+		Language:   constant.Go,
+		Code:       "package main",
+		Status:     constant.WAITING,
+		QuestionId: "q1",
+		UserId:     "u1",
+	}}
+	exec := &fakeExecutor{}
+	service := NewJudgeService(questions, submits, progress.NewMemory())
+	service.newSanBox = func() *sanbox.SanBox {
+		return sanbox.NewSanBoxWithExecutor(exec)
+	}
+	return service, questions, submits, exec
+}
+
+func TestJudgeClaimOnce(t *testing.T) {
+	service, questions, submits, exec := newTestJudgeService()
+
+	if err := service.Judge("s1"); err != nil {
+		t.Fatal(err)
+	}
+	if exec.ran != 1 || len(submits.updates) != 1 || submits.updates[0].Status != constant.SUCCESS || questions.accepts != 1 {
+		t.Fatalf("first delivery should be judged: ran %d, updates %+v, accepts %d", exec.ran, submits.updates, questions.accepts)
+	}
+
+	//重复投递时领取失败，不再运行沙箱，也不覆盖已经保存的结果
+	if err := service.Judge("s1"); err != nil {
+		t.Fatal(err)
+	}
+	if exec.compiled != 1 || exec.ran != 1 || len(submits.updates) != 1 || questions.accepts != 1 {
+		t.Errorf("duplicate delivery should be skipped: compiled %d, ran %d, updates %d, accepts %d", exec.compiled, exec.ran, len(submits.updates), questions.accepts)
+	}
+}
+
+func TestJudgeLosesClaim(t *testing.T) {
+	service, questions, submits, _ := newTestJudgeService()
+	//判题期间恢复任务重置了提交，另一个判题进程已经保存了结果
+	submits.afterClaim = func(s *fakeResultStore) {
+		s.status = constant.SUCCESS
+	}
+
+	if err := service.Judge("s1"); err != nil {
+		t.Fatal(err)
+	}
+	if len(submits.updates) != 0 || questions.accepts != 0 {
+		t.Errorf("result of a lost claim should be dropped: updates %+v, accepts %d", submits.updates, questions.accepts)
+	}
+}
+
+func TestJudgeKeepAlive(t *testing.T) {
+	service, _, submits, exec := newTestJudgeService()
+	service.heartbeat = time.Millisecond
+	exec.delay = 50 * time.Millisecond
+
+	if err := service.Judge("s1"); err != nil {
+		t.Fatal(err)
+	}
+	if submits.touches == 0 || submits.status != constant.SUCCESS {
+		t.Errorf("a long judge should refresh the submit: touches %d, status %d", submits.touches, submits.status)
+	}
+}
+
+func TestFailSkipsFinished(t *testing.T) {
+	service, _, submits, _ := newTestJudgeService()
+	if err := service.Fail("s1"); err != nil {
+		t.Fatal(err)
+	}
+	if submits.status != constant.FAIL || len(submits.updates) != 1 {
+		t.Fatalf("waiting submit should be marked as System Error: status %d, updates %+v", submits.status, submits.updates)
+	}
+
+	//重新发送的投递已经保存了通过的结果
+	service, _, submits, _ = newTestJudgeService()
+	submits.status = constant.SUCCESS
+	if err := service.Fail("s1"); err != nil {
+		t.Fatal(err)
+	}
+	if submits.status != constant.SUCCESS || len(submits.updates) != 0 {
+		t.Errorf("finished submit should not be overwritten: status %d, updates %+v", submits.status, submits.updates)
+	}
+}
@@ -0,0 +1,35 @@
+package judge
+
+import (
+	"context"
+	"github.com/xissg/userManageSystem/entity/model_question"
+	"github.com/xissg/userManageSystem/service/progress"
+	"log"
+)
+
+// submitProgress 将沙箱的进度转换为提交的进度事件
+type submitProgress struct {
+	publisher progress.Publisher
+	submitId  string
+}
+
+func (p submitProgress) Compiling() {
+	p.publish(progress.Event{Stage: progress.StageCompiling})
+}
+
+func (p submitProgress) Running(current int, total int) {
+	p.publish(progress.Event{Stage: progress.StageRunning, Case: current, Total: total})
+}
+
+// finished 判题结果保存后发送最终结果
+func (p submitProgress) finished(update model_question.UpdateQuestionSubmitRequest) {
+	p.publish(progress.Event{Stage: progress.StageFinished, Status: update.Status, JudgeInfo: update.JudgeInfo, Summary: &update.JudgeSummary})
+}
+
+// publish 进度只用于展示，发送失败不影响判题
+func (p submitProgress) publish(event progress.Event) {
+	event.SubmitId = p.submitId
+	if err := p.publisher.Publish(context.Background(), event); err != nil {
+		log.Printf("publish progress of submit %s: %v", p.submitId, err)
+	}
+}
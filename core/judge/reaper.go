@@ -0,0 +1,192 @@
+package judge
+
+import (
+	"context"
+	"github.com/xissg/userManageSystem/common/constant"
+	"github.com/xissg/userManageSystem/entity/model_question"
+	"github.com/xissg/userManageSystem/service/queue"
+	"log"
+	"sync"
+	"time"
+)
+
+// 卡住的提交的处理方式
+const (
+	ReapRequeue = "requeue" //恢复为待判题并重新发送到判题队列
+	ReapFail    = "fail"    //标记为 System Error
+)
+
+// reapBatch 每次最多处理的提交数
+const reapBatch = 100
+
+// ReaperConfig conf/judge.yaml 中的 reaper 配置
+type ReaperConfig struct {
+	Enabled   bool          `mapstructure:"enabled"`
+	Threshold time.Duration `mapstructure:"threshold"`
+	Interval  time.Duration `mapstructure:"interval"`
+	Action    string        `mapstructure:"action"`
+}
+
+var DefaultReaperConfig = ReaperConfig{
+	Enabled:   true,
+	Threshold: 10 * time.Minute,
+	Interval:  time.Minute,
+	Action:    ReapRequeue,
+}
+
+// LoadReaperConfig 读取失败或未配置的项使用默认值
+func LoadReaperConfig() ReaperConfig {
+	config := DefaultReaperConfig
+	if err := loadConfig("reaper", &config); err != nil {
+		log.Printf("read judge config %v, use default reaper config", err)
+		return DefaultReaperConfig
+	}
+	if config.Threshold <= 0 {
+		config.Threshold = DefaultReaperConfig.Threshold
+	}
+	if config.Interval <= 0 {
+		config.Interval = DefaultReaperConfig.Interval
+	}
+	if config.Action != ReapFail {
+		config.Action = ReapRequeue
+	}
+	return config
+}
+
+// Clock 当前时间和定时器，测试时替换
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// SubmitStore 提交的存储，QuestionSubmitService 实现该接口
+type SubmitStore interface {
+	GetStaleSubmits(statuses []int, before time.Time, limit int) ([]model_question.QuestionSubmit, error)
+	UpdateStaleSubmit(request model_question.CommonQuestionSubmitRequest, statuses []int, before time.Time) (bool, error)
+}
+
+// reapStatuses 需要恢复的提交状态，判题中的提交由判题进程定期刷新更新时间，超过 Threshold 没有刷新说明判题进程已经停止
+var reapStatuses = []int{constant.WAITING, constant.JUDGING}
+
+// ReaperStats 累计恢复的提交数
+type ReaperStats struct {
+	Requeued int
+	Failed   int
+}
+
+// Reaper 定期找出长时间停留在待判题、判题中的提交，重新判题或标记为系统错误
+// 判题进程崩溃、消息发送失败或服务重启都可能留下这样的提交
+type Reaper struct {
+	store     SubmitStore
+	publisher queue.Publisher
+	config    ReaperConfig
+	clock     Clock
+
+	mu    sync.Mutex
+	stats ReaperStats
+}
+
+func NewReaper(store SubmitStore, publisher queue.Publisher, config ReaperConfig) *Reaper {
+	return NewReaperWithClock(store, publisher, config, realClock{})
+}
+
+// NewReaperWithClock 使用指定的时钟，用于测试
+func NewReaperWithClock(store SubmitStore, publisher queue.Publisher, config ReaperConfig, clock Clock) *Reaper {
+	return &Reaper{
+		store:     store,
+		publisher: publisher,
+		config:    config,
+		clock:     clock,
+	}
+}
+
+// Run 每隔 Interval 检查一次，直到ctx结束
+func (r *Reaper) Run(ctx context.Context) {
+	for {
+		if n, err := r.Reap(ctx); err != nil {
+			log.Printf("reap stuck submits %v", err)
+		} else if n > 0 {
+			stats := r.Stats()
+			log.Printf("recovered %d stuck submits, %d requeued and %d failed in total", n, stats.Requeued, stats.Failed)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-r.clock.After(r.config.Interval):
+		}
+	}
+}
+
+// Reap 处理一批超过 Threshold 没有变化的提交，返回本次恢复的数量
+func (r *Reaper) Reap(ctx context.Context) (int, error) {
+	before := r.clock.Now().Add(-r.config.Threshold)
+	submits, err := r.store.GetStaleSubmits(reapStatuses, before, reapBatch)
+	if err != nil {
+		return 0, err
+	}
+
+	var recovered int
+	for _, submit := range submits {
+		if ctx.Err() != nil {
+			return recovered, ctx.Err()
+		}
+		ok, err := r.recover(ctx, submit.ID, before)
+		if err != nil {
+			log.Printf("recover submit %s %v", submit.ID, err)
+			continue
+		}
+		if ok {
+			recovered++
+		}
+	}
+	return recovered, nil
+}
+
+// recover 查询之后判题进程可能已经保存结果或重新领取，只有提交仍然卡住时才更新，返回是否恢复
+func (r *Reaper) recover(ctx context.Context, submitId string, before time.Time) (bool, error) {
+	if r.config.Action == ReapFail {
+		ok, err := r.store.UpdateStaleSubmit(model_question.UpdateQSToCommonQS(systemError(submitId)), reapStatuses, before)
+		if err != nil || !ok {
+			return false, err
+		}
+		r.record(func(s *ReaperStats) { s.Failed++ })
+		return true, nil
+	}
+
+	//先恢复为待判题，判题进程只处理待判题的提交，更新时间随之刷新
+	ok, err := r.store.UpdateStaleSubmit(model_question.CommonQuestionSubmitRequest{
+		ID:        submitId,
+		Status:    constant.WAITING,
+		JudgeInfo: "[]",
+	}, reapStatuses, before)
+	if err != nil || !ok {
+		return false, err
+	}
+	if err = r.publisher.Publish(ctx, submitId); err != nil {
+		return false, err
+	}
+	r.record(func(s *ReaperStats) { s.Requeued++ })
+	return true, nil
+}
+
+func (r *Reaper) record(update func(s *ReaperStats)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	update(&r.stats)
+}
+
+func (r *Reaper) Stats() ReaperStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.stats
+}
@@ -0,0 +1,258 @@
+package judge
+
+import (
+	"context"
+	"errors"
+	"github.com/xissg/userManageSystem/common/constant"
+	"github.com/xissg/userManageSystem/entity/model_question"
+	"github.com/xissg/userManageSystem/service/queue"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock 时间只在测试调用 advance 时变化
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []fakeTimer
+}
+
+type fakeTimer struct {
+	at time.Time
+	c  chan time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	c.timers = append(c.timers, fakeTimer{at: c.now.Add(d), c: ch})
+	return ch
+}
+
+// advance 推进时间并触发到期的定时器
+func (c *fakeClock) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	var waiting []fakeTimer
+	for _, t := range c.timers {
+		if t.at.After(c.now) {
+			waiting = append(waiting, t)
+			continue
+		}
+		t.c <- c.now
+	}
+	c.timers = waiting
+}
+
+func (c *fakeClock) pending() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.timers)
+}
+
+// fakeStore 按 fakeClock 的时间记录提交的更新时间
+type fakeStore struct {
+	mu      sync.Mutex
+	clock   *fakeClock
+	submits map[string]*model_question.QuestionSubmit
+	failOn  string
+	//在条件更新之前调用，模拟判题进程在查询之后保存了结果
+	beforeUpdate func(id string)
+}
+
+func newFakeStore(clock *fakeClock) *fakeStore {
+	return &fakeStore{clock: clock, submits: make(map[string]*model_question.QuestionSubmit)}
+}
+
+func (s *fakeStore) add(id string, status int, age time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.submits[id] = &model_question.QuestionSubmit{ID: id, Status: status, UpdateTime: s.clock.Now().Add(-age)}
+}
+
+func (s *fakeStore) status(id string) (int, string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.submits[id].Status, s.submits[id].JudgeInfo
+}
+
+func (s *fakeStore) GetStaleSubmits(statuses []int, before time.Time, limit int) ([]model_question.QuestionSubmit, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var res []model_question.QuestionSubmit
+	for _, submit := range s.submits {
+		for _, status := range statuses {
+			if submit.Status == status && submit.UpdateTime.Before(before) {
+				res = append(res, *submit)
+			}
+		}
+	}
+	return res, nil
+}
+
+func (s *fakeStore) UpdateStaleSubmit(request model_question.CommonQuestionSubmitRequest, statuses []int, before time.Time) (bool, error) {
+	if s.beforeUpdate != nil {
+		s.beforeUpdate(request.ID)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if request.ID == s.failOn {
+		return false, errors.New("database unavailable")
+	}
+	submit := s.submits[request.ID]
+	if !submit.UpdateTime.Before(before) || !containsStatus(statuses, submit.Status) {
+		return false, nil
+	}
+	submit.Status = request.Status
+	submit.JudgeInfo = request.JudgeInfo
+	submit.UpdateTime = s.clock.Now()
+	return true, nil
+}
+
+func containsStatus(statuses []int, status int) bool {
+	for _, s := range statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// finish 判题进程保存结果
+func (s *fakeStore) finish(id string, status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.submits[id].Status = status
+	s.submits[id].JudgeInfo = `[{"message":"Accepted"}]`
+	s.submits[id].UpdateTime = s.clock.Now()
+}
+
+func TestReaperRequeue(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)}
+	store := newFakeStore(clock)
+	store.add("waiting", constant.WAITING, 11*time.Minute)
+	store.add("judging", constant.JUDGING, 20*time.Minute)
+	store.add("recent", constant.WAITING, time.Minute)
+	store.add("done", constant.SUCCESS, time.Hour)
+	store.add("broken", constant.JUDGING, time.Hour)
+	store.failOn = "broken"
+	q := queue.NewMemory()
+	defer q.Close()
+
+	reaper := NewReaperWithClock(store, q, ReaperConfig{Threshold: 10 * time.Minute, Interval: time.Minute, Action: ReapRequeue}, clock)
+	n, err := reaper.Reap(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 || q.Len() != 2 || reaper.Stats().Requeued != 2 {
+		t.Errorf("recovered %d, queued %d, stats %+v, want 2", n, q.Len(), reaper.Stats())
+	}
+	for _, id := range []string{"waiting", "judging"} {
+		if status, _ := store.status(id); status != constant.WAITING {
+			t.Errorf("%s should be reset to waiting, got %d", id, status)
+		}
+	}
+
+	//刚恢复的提交在超过阈值之前不会再被处理
+	clock.advance(5 * time.Minute)
+	if n, _ = reaper.Reap(context.Background()); n != 0 {
+		t.Errorf("no submit should be stale yet, got %d", n)
+	}
+	clock.advance(6 * time.Minute)
+	if n, _ = reaper.Reap(context.Background()); n != 3 {
+		t.Errorf("requeued submits still waiting should be recovered again, got %d", n)
+	}
+	if reaper.Stats().Requeued != 5 {
+		t.Errorf("stats %+v, want 5 requeued", reaper.Stats())
+	}
+}
+
+func TestReaperFail(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)}
+	store := newFakeStore(clock)
+	store.add("judging", constant.JUDGING, time.Hour)
+	q := queue.NewMemory()
+	defer q.Close()
+
+	reaper := NewReaperWithClock(store, q, ReaperConfig{Threshold: 10 * time.Minute, Interval: time.Minute, Action: ReapFail}, clock)
+	if n, err := reaper.Reap(context.Background()); n != 1 || err != nil {
+		t.Fatalf("recovered %d, %v", n, err)
+	}
+	status, judgeInfo := store.status("judging")
+	if status != constant.FAIL || judgeInfo != `[{"message":"System Error","time":0,"memory":0}]` {
+		t.Errorf("submit should fail with system error, got %d %s", status, judgeInfo)
+	}
+	if q.Len() != 0 || reaper.Stats().Failed != 1 {
+		t.Errorf("failed submit should not be queued, queued %d, stats %+v", q.Len(), reaper.Stats())
+	}
+}
+
+func TestReaperSkipsFinished(t *testing.T) {
+	for _, action := range []string{ReapRequeue, ReapFail} {
+		clock := &fakeClock{now: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)}
+		store := newFakeStore(clock)
+		store.add("slow", constant.JUDGING, time.Hour)
+		store.add("stuck", constant.JUDGING, time.Hour)
+		//判题进程在查询和更新之间保存了结果
+		store.beforeUpdate = func(id string) {
+			if id == "slow" {
+				store.finish(id, constant.SUCCESS)
+			}
+		}
+		q := queue.NewMemory()
+
+		reaper := NewReaperWithClock(store, q, ReaperConfig{Threshold: 10 * time.Minute, Interval: time.Minute, Action: action}, clock)
+		n, err := reaper.Reap(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		stats := reaper.Stats()
+		if n != 1 || stats.Requeued+stats.Failed != 1 {
+			t.Errorf("%s: recovered %d, stats %+v, want only the stuck submit", action, n, stats)
+		}
+		if status, judgeInfo := store.status("slow"); status != constant.SUCCESS || judgeInfo != `[{"message":"Accepted"}]` {
+			t.Errorf("%s: finished submit should be kept, got %d %s", action, status, judgeInfo)
+		}
+		if action == ReapRequeue && q.Len() != 1 {
+			t.Errorf("only the stuck submit should be queued, queued %d", q.Len())
+		}
+		q.Close()
+	}
+}
+
+func TestReaperRun(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)}
+	store := newFakeStore(clock)
+	q := queue.NewMemory()
+	defer q.Close()
+	reaper := NewReaperWithClock(store, q, ReaperConfig{Threshold: 10 * time.Minute, Interval: time.Minute, Action: ReapRequeue}, clock)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		reaper.Run(ctx)
+		close(done)
+	}()
+
+	//每个间隔检查一次
+	waitFor(t, func() bool { return clock.pending() == 1 })
+	store.add("a", constant.WAITING, 11*time.Minute)
+	clock.advance(30 * time.Second)
+	if q.Len() != 0 {
+		t.Error("reaper should wait for the interval")
+	}
+	clock.advance(30 * time.Second)
+	waitFor(t, func() bool { return q.Len() == 1 })
+
+	cancel()
+	<-done
+}
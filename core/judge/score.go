@@ -0,0 +1,51 @@
+package judge
+
+import (
+	"github.com/xissg/userManageSystem/common/constant"
+	"github.com/xissg/userManageSystem/entity/model_question"
+)
+
+// score 按用例的分数和子任务计算得分和满分，infos 与运行过的用例一一对应，未运行的用例不得分
+// 不属于子任务的用例单独计分，子任务按 rule 汇总其中用例的得分
+func score(cases []model_question.JudgeCase, infos []model_question.JudgeInfo, rule string) (int, int) {
+	type group struct {
+		earned, full int
+		started      bool
+	}
+	var earned, full int
+	var order []int
+	groups := make(map[int]*group)
+	for i, c := range cases {
+		got := 0
+		if i < len(infos) && infos[i].Message == constant.Accepted {
+			got = c.Score
+		}
+		if c.Subtask == 0 {
+			earned += got
+			full += c.Score
+			continue
+		}
+
+		g, ok := groups[c.Subtask]
+		if !ok {
+			g = &group{}
+			groups[c.Subtask] = g
+			order = append(order, c.Subtask)
+		}
+		switch {
+		case rule == constant.SubtaskMin && !g.started:
+			g.earned, g.full, g.started = got, c.Score, true
+		case rule == constant.SubtaskMin:
+			g.earned = min(g.earned, got)
+			g.full = min(g.full, c.Score)
+		default:
+			g.earned += got
+			g.full += c.Score
+		}
+	}
+	for _, id := range order {
+		earned += groups[id].earned
+		full += groups[id].full
+	}
+	return earned, full
+}
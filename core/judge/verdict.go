@@ -0,0 +1,81 @@
+package judge
+
+import (
+	"github.com/xissg/userManageSystem/common/constant"
+	"github.com/xissg/userManageSystem/core/sanbox"
+	"github.com/xissg/userManageSystem/entity/model_question"
+	"log"
+)
+
+// caseVerdict 单个用例的结果，运行错误返回标准错误，判题程序的提示随结果返回
+// 使用判题程序时只有程序正常结束的用例才会被检查，Check 为 0 时不比较输出
+func caseVerdict(config model_question.JudgeConfig, result sanbox.CaseResult, answer string) model_question.JudgeInfo {
+	info := model_question.JudgeInfo{Time: result.CostTime, Memory: result.Memory}
+	switch {
+	case result.TimedOut:
+		info.Message = constant.TimeLimitExceeded
+	case result.OOMKilled:
+		info.Message = constant.MemoryLimitExceeded
+	case result.OutputLimitExceeded:
+		info.Message = constant.OutputLimitExceeded
+	case result.Check == sanbox.CheckRejected:
+		//交互程序判定错误后关闭管道，用户程序随之异常退出时仍然是答案错误
+		info.Message = constant.WrongAnswer
+		info.Detail = result.CheckMessage
+	case result.ExitCode == -1:
+		info.Message = constant.SystemError
+	case result.ExitCode != 0:
+		info.Message = constant.RuntimeError
+		info.Detail = result.Stderr
+	case result.Check == sanbox.CheckFailed:
+		//判题程序异常不是用户的问题
+		log.Printf("checker failed: %s", result.CheckMessage)
+		info.Message = constant.SystemError
+	case result.Check == 0 && config.CompareMode != constant.CompareChecker && !compareOutput(config, result.ExecResult, answer):
+		info.Message = constant.WrongAnswer
+	default:
+		info.Message = constant.Accepted
+	}
+	return info
+}
+
+// summarize 汇总各用例的结果，total 为题目的用例数
+// 总体结果为第一个未通过用例的结果，System Error 说明判题不可信，优先于其他结果
+func summarize(infos []model_question.JudgeInfo, total int) model_question.JudgeSummary {
+	summary := model_question.JudgeSummary{Verdict: constant.Accepted, Total: total}
+	for _, info := range infos {
+		if info.Time > summary.Time {
+			summary.Time = info.Time
+		}
+		if info.Memory > summary.Memory {
+			summary.Memory = info.Memory
+		}
+		switch {
+		case info.Message == constant.Accepted:
+			summary.Passed++
+		case info.Message == constant.SystemError:
+			summary.Verdict = constant.SystemError
+		case summary.Verdict == constant.Accepted:
+			summary.Verdict = info.Message
+		}
+	}
+	return summary
+}
+
+// submitStatus 所有用例都运行并通过时判题成功
+func submitStatus(summary model_question.JudgeSummary) int {
+	if summary.Verdict == constant.Accepted && summary.Passed == summary.Total {
+		return constant.SUCCESS
+	}
+	return constant.FAIL
+}
+
+// systemError 无法完成判题时保存的结果
+func systemError(submitId string) model_question.UpdateQuestionSubmitRequest {
+	return model_question.UpdateQuestionSubmitRequest{
+		ID:           submitId,
+		Status:       constant.FAIL,
+		JudgeInfo:    []model_question.JudgeInfo{{Message: constant.SystemError}},
+		JudgeSummary: model_question.JudgeSummary{Verdict: constant.SystemError},
+	}
+}
@@ -0,0 +1,109 @@
+package judge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/xissg/userManageSystem/service/queue"
+	"log"
+	"sync"
+)
+
+// Judger 根据提交id判题，JudgeService 实现该接口
+type Judger interface {
+	// Judge 返回错误表示系统错误，可以重试
+	Judge(submitId string) error
+	// Fail 重试次数用完后将提交标记为 System Error
+	Fail(submitId string) error
+}
+
+// Worker 从判题队列中取出提交id并判题，判题结束后确认消息
+// 进程在判题过程中崩溃时消息没有确认，会被重新投递给其他判题进程
+// 判题出现系统错误时按重试策略延迟重试，重试用完后标记为 System Error 并放入死信队列
+type Worker struct {
+	consumer    queue.Consumer
+	judger      Judger
+	concurrency int
+	retry       queue.RetryPolicy
+}
+
+// NewWorker concurrency 为同时判题的提交数，也是未确认消息数的上限
+func NewWorker(consumer queue.Consumer, judger Judger, concurrency int, retry queue.RetryPolicy) *Worker {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	return &Worker{
+		consumer:    consumer,
+		judger:      judger,
+		concurrency: concurrency,
+		retry:       retry,
+	}
+}
+
+// Run 持续消费直到ctx结束或队列关闭，返回前等待正在进行的判题完成
+// ctx 结束时返回nil，队列意外关闭时返回错误
+func (w *Worker) Run(ctx context.Context) error {
+	msgs, err := w.consumer.Consume(ctx, w.concurrency)
+	if err != nil {
+		return err
+	}
+
+	//队列限制了未确认的消息数，每条消息单独启动协程即可限制并发
+	var wg sync.WaitGroup
+	for msg := range msgs {
+		wg.Add(1)
+		go func(msg queue.Message) {
+			defer wg.Done()
+			w.handle(msg)
+		}(msg)
+	}
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		return nil
+	}
+	return errors.New("judge queue closed")
+}
+
+// handle 判题成功时确认消息，失败时重试或放入死信队列
+func (w *Worker) handle(msg queue.Message) {
+	err := w.judge(msg.Body)
+	if err == nil {
+		if err = msg.Ack(); err != nil {
+			log.Printf("ack submit %s: %v", msg.Body, err)
+		}
+		return
+	}
+
+	if msg.Attempt < w.retry.MaxRetries {
+		delay := w.retry.Delay(msg.Attempt)
+		log.Printf("judge submit %s failed (attempt %d), retry in %v: %v", msg.Body, msg.Attempt+1, delay, err)
+		if err = msg.Retry(delay); err == nil {
+			return
+		}
+		//无法写入重试队列时重新入队，避免消息丢失
+		log.Printf("retry submit %s: %v", msg.Body, err)
+		if err = msg.Nack(true); err != nil {
+			log.Printf("nack submit %s: %v", msg.Body, err)
+		}
+		return
+	}
+
+	log.Printf("judge submit %s failed after %d retries: %v", msg.Body, msg.Attempt, err)
+	if err = w.judger.Fail(msg.Body); err != nil {
+		log.Printf("mark submit %s as system error: %v", msg.Body, err)
+	}
+	if err = msg.Nack(false); err != nil {
+		log.Printf("dead letter submit %s: %v", msg.Body, err)
+	}
+}
+
+// judge 判题过程中的 panic 也作为系统错误
+func (w *Worker) judge(submitId string) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return w.judger.Judge(submitId)
+}
@@ -0,0 +1,202 @@
+package judge
+
+import (
+	"context"
+	"errors"
+	"github.com/xissg/userManageSystem/service/queue"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeJudger 记录判题的提交id，block 不为空时判题阻塞到其关闭
+type fakeJudger struct {
+	mu      sync.Mutex
+	judged  []string
+	running int
+	peak    int
+	block   chan struct{}
+	started chan string
+	panics  map[string]bool
+	errs    map[string]int //返回系统错误的次数
+	failed  []string
+}
+
+func (j *fakeJudger) Judge(submitId string) error {
+	j.mu.Lock()
+	j.running++
+	if j.running > j.peak {
+		j.peak = j.running
+	}
+	j.mu.Unlock()
+	defer func() {
+		j.mu.Lock()
+		j.running--
+		j.mu.Unlock()
+	}()
+
+	if j.started != nil {
+		j.started <- submitId
+	}
+	if j.block != nil {
+		<-j.block
+	}
+	if j.panics[submitId] {
+		panic("sandbox crashed")
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.errs[submitId] > 0 {
+		j.errs[submitId]--
+		return errors.New("docker unavailable")
+	}
+	j.judged = append(j.judged, submitId)
+	return nil
+}
+
+func (j *fakeJudger) Fail(submitId string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.failed = append(j.failed, submitId)
+	return nil
+}
+
+func (j *fakeJudger) count() int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return len(j.judged)
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestWorkerConcurrency(t *testing.T) {
+	q := queue.NewMemory()
+	judger := &fakeJudger{block: make(chan struct{}), started: make(chan string, 10)}
+	for _, id := range []string{"a", "b", "c", "d", "e"} {
+		_ = q.Publish(context.Background(), id)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- NewWorker(q, judger, 2, queue.DefaultRetryPolicy).Run(ctx) }()
+
+	<-judger.started
+	<-judger.started
+	//同时判题的数量不超过并发数
+	select {
+	case id := <-judger.started:
+		t.Fatalf("submit %s started beyond the concurrency limit", id)
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(judger.block)
+	waitFor(t, func() bool { return judger.count() == 5 })
+	if judger.peak != 2 {
+		t.Errorf("peak concurrency %d, want 2", judger.peak)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("worker should stop cleanly, got %v", err)
+	}
+}
+
+func TestWorkerGracefulShutdown(t *testing.T) {
+	q := queue.NewMemory()
+	judger := &fakeJudger{block: make(chan struct{}), started: make(chan string, 1)}
+	_ = q.Publish(context.Background(), "a")
+	_ = q.Publish(context.Background(), "b")
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- NewWorker(q, judger, 1, queue.DefaultRetryPolicy).Run(ctx) }()
+
+	<-judger.started
+	cancel()
+	//正在进行的判题完成后才返回
+	select {
+	case <-done:
+		t.Fatal("worker returned before the judgement finished")
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(judger.block)
+	<-done
+	if judger.count() != 1 || q.Len() != 1 {
+		t.Errorf("judged %d, pending %d, want 1 and 1", judger.count(), q.Len())
+	}
+}
+
+func TestWorkerRedeliversAfterCrash(t *testing.T) {
+	q := queue.NewMemory()
+	judger := &fakeJudger{block: make(chan struct{}), started: make(chan string, 2)}
+	_ = q.Publish(context.Background(), "a")
+	go func() { _ = NewWorker(q, judger, 1, queue.DefaultRetryPolicy).Run(context.Background()) }()
+
+	//判题进程在确认前断开，消息交给新的判题进程
+	<-judger.started
+	q.Disconnect()
+	close(judger.block)
+	go func() { _ = NewWorker(q, judger, 1, queue.DefaultRetryPolicy).Run(context.Background()) }()
+	<-judger.started
+	waitFor(t, func() bool { return judger.count() == 2 })
+	if q.Len() != 0 {
+		t.Errorf("%d submissions left in queue", q.Len())
+	}
+	_ = q.Close()
+}
+
+func TestWorkerRetry(t *testing.T) {
+	q := queue.NewMemory()
+	defer q.Close()
+	judger := &fakeJudger{started: make(chan string, 10), errs: map[string]int{"a": 2}}
+	_ = q.Publish(context.Background(), "a")
+	policy := queue.RetryPolicy{MaxRetries: 3, Backoff: 10 * time.Millisecond}
+	go func() { _ = NewWorker(q, judger, 1, policy).Run(context.Background()) }()
+
+	//前两次系统错误后延迟重试，第三次成功
+	start := time.Now()
+	waitFor(t, func() bool { return judger.count() == 1 })
+	if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+		t.Errorf("retries should back off, finished in %v", elapsed)
+	}
+	if len(judger.failed) != 0 {
+		t.Errorf("submission should not be failed: %v", judger.failed)
+	}
+}
+
+func TestWorkerDeadLetter(t *testing.T) {
+	q := queue.NewMemory()
+	defer q.Close()
+	judger := &fakeJudger{started: make(chan string, 10), panics: map[string]bool{"a": true}}
+	_ = q.Publish(context.Background(), "a")
+	policy := queue.RetryPolicy{MaxRetries: 2, Backoff: time.Millisecond}
+	go func() { _ = NewWorker(q, judger, 1, policy).Run(context.Background()) }()
+
+	//第一次判题和两次重试都失败后标记为系统错误，进入死信队列
+	waitFor(t, func() bool {
+		dead, _ := q.Dead(context.Background())
+		return len(dead) == 1
+	})
+	if len(judger.started) != 3 {
+		t.Errorf("judged %d times, want 3", len(judger.started))
+	}
+	judger.mu.Lock()
+	if len(judger.failed) != 1 || judger.failed[0] != "a" {
+		t.Errorf("submission should be marked as system error: %v", judger.failed)
+	}
+	judger.mu.Unlock()
+
+	//人工重新判题
+	judger.panics = nil
+	found, err := q.Requeue(context.Background(), "a")
+	if err != nil || !found {
+		t.Fatalf("requeue: %v %v", found, err)
+	}
+	waitFor(t, func() bool { return judger.count() == 1 })
+}
@@ -0,0 +1,265 @@
+package local
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/xissg/userManageSystem/core/executor"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 本机编译的限制
+const (
+	compileTimeout   = 30 * time.Second
+	compileOutputMax = 64 << 10 //编译输出最多保留 64KB
+	waitDelay        = 5 * time.Second
+)
+
+// Config 本机执行后端的配置
+type Config struct {
+	//在新的 user/pid/net/mount 等命名空间中运行用户程序，需要内核允许非特权用户命名空间
+	Namespaces bool `mapstructure:"namespaces"`
+	//地址空间限制比内存限制多出的部分，单位为kb，go、java 等运行时启动时就会预留较大的地址空间
+	AddressSpaceSlack uint64 `mapstructure:"address_space_slack"`
+}
+
+// DefaultAddressSpaceSlack 未配置 address_space_slack 时的默认值，单位为kb
+const DefaultAddressSpaceSlack = 1 << 20
+
+var (
+	configOnce sync.Once
+	config     Config
+)
+
+// LoadConfig 读取 conf/sandbox.yaml 中的 local 配置，读取失败时不使用命名空间
+func LoadConfig() Config {
+	configOnce.Do(func() {
+		if err := executor.LoadConfig("local", &config); err != nil {
+			log.Printf("read sandbox config %v, use default local config", err)
+			config = Config{}
+		}
+	})
+	return config
+}
+
+// Executor 直接在本机进程中编译和运行用户代码，通过 rlimit 和命名空间限制程序
+// 隔离程度远低于docker，只用于开发和CI，镜像参数被忽略，需要本机安装对应的编译器
+type Executor struct {
+	config Config
+}
+
+func NewExecutor(config Config) *Executor {
+	return &Executor{config: config}
+}
+
+// NewExecutorFromConfig 按 conf/sandbox.yaml 中的 local 配置创建
+func NewExecutorFromConfig() *Executor {
+	return NewExecutor(LoadConfig())
+}
+
+func (e *Executor) Compile(image string, dir string, cmd []string) (string, int, error) {
+	if len(cmd) == 0 {
+		return "", -1, errors.New("empty compile command")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), compileTimeout)
+	defer cancel()
+
+	output := &executor.LimitedBuffer{Limit: compileOutputMax}
+	c := exec.CommandContext(ctx, cmd[0], cmd[1:]...)
+	c.Dir = dir
+	c.Stdout = output
+	c.Stderr = output
+	c.WaitDelay = waitDelay
+	err := c.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return "compilation timed out", -1, nil
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return output.String(), exitErr.ExitCode(), nil
+	}
+	if err != nil {
+		return "", -1, err
+	}
+	return output.String(), 0, nil
+}
+
+func (e *Executor) Prepare(image string, dir string, limits executor.Limits) (executor.Session, error) {
+	return &session{
+		dir:    dir,
+		limits: limits.WithDefaults(),
+		config: e.config,
+	}, nil
+}
+
+// session 每个用例启动一个新的进程
+type session struct {
+	dir    string
+	limits executor.Limits
+	config Config
+}
+
+func (s *session) Run(cmd []string, input string) (executor.Result, error) {
+	p, err := newProcess(s.dir, cmd, s.limits, s.config)
+	if err != nil {
+		return executor.Result{}, err
+	}
+	p.cmd.Stdin = strings.NewReader(input)
+	if err = p.start(); err != nil {
+		return executor.Result{}, err
+	}
+	return p.wait()
+}
+
+func (s *session) Close() error {
+	return nil
+}
+
+// Interact 用两对管道连接用户程序和交互程序，两个进程同时运行，各自按自己的限制结束
+func (e *Executor) Interact(user executor.Process, interactor executor.Process) (executor.Result, executor.Result, error) {
+	u, err := newProcess(user.Dir, user.Cmd, user.Limits.WithDefaults(), e.config)
+	if err != nil {
+		return executor.Result{}, executor.Result{}, err
+	}
+	i, err := newProcess(interactor.Dir, interactor.Cmd, interactor.Limits.WithDefaults(), e.config)
+	if err != nil {
+		return executor.Result{}, executor.Result{}, err
+	}
+
+	//toUser 为交互程序写给用户程序的管道，toInteractor 反之
+	toUserR, toUserW, err := os.Pipe()
+	if err != nil {
+		return executor.Result{}, executor.Result{}, err
+	}
+	toInteractorR, toInteractorW, err := os.Pipe()
+	if err != nil {
+		_ = toUserR.Close()
+		_ = toUserW.Close()
+		return executor.Result{}, executor.Result{}, err
+	}
+	u.cmd.Stdin, u.cmd.Stdout = toUserR, toInteractorW
+	i.cmd.Stdin, i.cmd.Stdout = toInteractorR, toUserW
+
+	err = u.start()
+	if err == nil {
+		err = i.start()
+		if err != nil {
+			u.kill()
+			_, _ = u.wait()
+		}
+	}
+	//子进程已经持有管道，父进程关闭自己的副本，一方退出后另一方才能读到EOF
+	for _, f := range []*os.File{toUserR, toUserW, toInteractorR, toInteractorW} {
+		_ = f.Close()
+	}
+	if err != nil {
+		return executor.Result{}, executor.Result{}, err
+	}
+
+	var interactorResult executor.Result
+	var interactorErr error
+	done := make(chan struct{})
+	go func() {
+		interactorResult, interactorErr = i.wait()
+		close(done)
+	}()
+	userResult, err := u.wait()
+	<-done
+	if err != nil {
+		return executor.Result{}, executor.Result{}, err
+	}
+	if interactorErr != nil {
+		return executor.Result{}, executor.Result{}, interactorErr
+	}
+	return userResult, interactorResult, nil
+}
+
+// process 通过 sh 的 ulimit 设置 rlimit 后执行cmd，超时或输出超限时结束整个进程组
+// 地址空间限制为内存限制加上 AddressSpaceSlack，超过时申请内存失败，程序不会耗尽本机内存
+// 是否内存超限在程序退出后按最大常驻内存判断
+type process struct {
+	cmd      *exec.Cmd
+	limits   executor.Limits
+	stdout   *executor.LimitedBuffer
+	stderr   *executor.LimitedBuffer
+	killOnce sync.Once
+	timedOut atomic.Bool
+	timer    *time.Timer
+	started  time.Time
+}
+
+// newProcess 默认收集标准输出和标准错误，调用方可以在 start 前替换标准输入输出
+func newProcess(dir string, cmd []string, limits executor.Limits, config Config) (*process, error) {
+	if len(cmd) == 0 {
+		return nil, errors.New("empty run command")
+	}
+	p := &process{limits: limits}
+	c := exec.Command("/bin/sh", append([]string{"-c", ulimit(limits, config.addressSpaceSlack()) + `exec "$@"`, "sh"}, cmd...)...)
+	c.Dir = dir
+	c.Env = []string{"PATH=" + os.Getenv("PATH")}
+	c.SysProcAttr = sysProcAttr(config.Namespaces)
+	c.WaitDelay = waitDelay
+	p.stdout = &executor.LimitedBuffer{Limit: int(limits.OutputLimit << 10), OnOverflow: p.kill}
+	p.stderr = &executor.LimitedBuffer{Limit: executor.StderrMax}
+	c.Stdout = p.stdout
+	c.Stderr = p.stderr
+	p.cmd = c
+	return p, nil
+}
+
+func (p *process) start() error {
+	p.started = time.Now()
+	if err := p.cmd.Start(); err != nil {
+		return err
+	}
+	p.timer = time.AfterFunc(time.Duration(p.limits.TimeLimit)*time.Millisecond, func() {
+		p.timedOut.Store(true)
+		p.kill()
+	})
+	return nil
+}
+
+func (p *process) kill() {
+	p.killOnce.Do(func() { killGroup(p.cmd.Process) })
+}
+
+func (p *process) wait() (executor.Result, error) {
+	var result executor.Result
+	//程序留下的子进程占用输出时，最多再等待 waitDelay
+	err := p.cmd.Wait()
+	p.timer.Stop()
+	result.CostTime = time.Since(p.started).Milliseconds()
+	result.TimedOut = p.timedOut.Load()
+
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) && !errors.Is(err, exec.ErrWaitDelay) {
+		return executor.Result{}, err
+	}
+	result.ExitCode = exitCode(p.cmd.ProcessState)
+	result.Memory = maxRSS(p.cmd.ProcessState)
+	result.OOMKilled = result.Memory > p.limits.MemoryLimit
+	result.ExecResult = p.stdout.String()
+	result.Stderr = p.stderr.String()
+	result.OutputLimitExceeded = p.stdout.Truncated
+	return result, nil
+}
+
+func (c Config) addressSpaceSlack() uint64 {
+	if c.AddressSpaceSlack == 0 {
+		return DefaultAddressSpaceSlack
+	}
+	return c.AddressSpaceSlack
+}
+
+// ulimit CPU时间比时间限制多 1 秒作为兜底，地址空间不超过内存限制加 slack，写文件的大小不超过输出限制，不生成core文件
+// -v 的单位为kb，-f 的单位为 512 字节
+func ulimit(limits executor.Limits, slack uint64) string {
+	cpu := (limits.TimeLimit+999)/1000 + 1
+	return fmt.Sprintf("ulimit -t %d; ulimit -v %d; ulimit -f %d; ulimit -c 0; ", cpu, limits.MemoryLimit+slack, limits.OutputLimit*2)
+}
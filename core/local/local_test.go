@@ -0,0 +1,140 @@
+package local
+
+import (
+	"github.com/xissg/userManageSystem/core/executor"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func run(t *testing.T, limits executor.Limits, input string, cmd ...string) executor.Result {
+	t.Helper()
+	session, err := NewExecutor(Config{}).Prepare("", t.TempDir(), limits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Close()
+	res, err := session.Run(cmd, input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+func TestRunSeparatesOutput(t *testing.T) {
+	res := run(t, executor.Limits{}, "1 2\n", "sh", "-c", `read a b; echo $((a+b)); echo debug >&2`)
+	if res.ExitCode != 0 || res.ExecResult != "3\n" || res.Stderr != "debug\n" {
+		t.Errorf("unexpected result %+v", res)
+	}
+	if res.Memory == 0 {
+		t.Error("memory usage should be recorded")
+	}
+}
+
+func TestRunExitCode(t *testing.T) {
+	res := run(t, executor.Limits{}, "", "sh", "-c", "exit 3")
+	if res.ExitCode != 3 || res.TimedOut || res.OOMKilled {
+		t.Errorf("unexpected result %+v", res)
+	}
+}
+
+func TestRunTimeLimit(t *testing.T) {
+	res := run(t, executor.Limits{TimeLimit: 100}, "", "sleep", "5")
+	if !res.TimedOut || res.ExitCode != 137 {
+		t.Errorf("program should be killed after the time limit: %+v", res)
+	}
+	if res.CostTime >= 5000 {
+		t.Errorf("program ran %dms after the time limit", res.CostTime)
+	}
+}
+
+func TestRunOutputLimit(t *testing.T) {
+	res := run(t, executor.Limits{OutputLimit: 1}, "", "yes")
+	if !res.OutputLimitExceeded || len(res.ExecResult) != 1<<10 {
+		t.Errorf("program should be killed after exceeding the output limit: %d bytes", len(res.ExecResult))
+	}
+}
+
+func TestRunMemoryLimit(t *testing.T) {
+	limits := executor.Limits{MemoryLimit: 32 << 10}
+	session, err := NewExecutor(Config{AddressSpaceSlack: 32 << 10}).Prepare("", t.TempDir(), limits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//tail 在读到换行前保存全部输入，内存持续增长直到超过地址空间限制
+	res, err := session.Run([]string{"sh", "-c", "head -c 4000000000 /dev/zero | tail -n 1"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.OOMKilled || res.ExitCode == 0 {
+		t.Errorf("program should be stopped by the memory limit: %+v", res)
+	}
+	if res.Memory > 64<<10 {
+		t.Errorf("memory usage %dkb exceeds the address space limit", res.Memory)
+	}
+}
+
+func TestCompile(t *testing.T) {
+	dir := t.TempDir()
+	e := NewExecutor(Config{})
+	output, exitCode, err := e.Compile("", dir, []string{"sh", "-c", "echo 'main.c:1: error' >&2; exit 1"})
+	if err != nil || exitCode != 1 || output != "main.c:1: error\n" {
+		t.Errorf("got %q, %d, %v", output, exitCode, err)
+	}
+
+	_, exitCode, err = e.Compile("", dir, []string{"sh", "-c", "echo ok > main"})
+	if err != nil || exitCode != 0 {
+		t.Fatalf("got %d, %v", exitCode, err)
+	}
+	if _, err = os.Stat(filepath.Join(dir, "main")); err != nil {
+		t.Error("artifacts should be left in the directory")
+	}
+}
+
+func TestRunNamespaces(t *testing.T) {
+	session, err := NewExecutor(Config{Namespaces: true}).Prepare("", t.TempDir(), executor.Limits{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := session.Run([]string{"sh", "-c", "echo $$"}, "")
+	if err != nil {
+		t.Skip("user namespaces unavailable:", err)
+	}
+	if res.ExecResult != "1\n" {
+		t.Errorf("program should be pid 1 in a new pid namespace, got %q", res.ExecResult)
+	}
+}
+
+func TestInteract(t *testing.T) {
+	e := NewExecutor(Config{})
+	user := executor.Process{Dir: t.TempDir(), Cmd: []string{"sh", "-c", `read n; echo $((n*2))`}}
+	interactor := executor.Process{Dir: t.TempDir(), Cmd: []string{"sh", "-c", `echo 21; read m; [ "$m" = 42 ] || { echo "got $m" >&2; exit 1; }`}}
+	u, i, err := e.Interact(user, interactor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.ExitCode != 0 || i.ExitCode != 0 || u.ExecResult != "" {
+		t.Errorf("interaction should succeed, user %+v, interactor %+v", u, i)
+	}
+
+	//用户程序回答错误，交互程序的标准错误作为提示
+	user.Cmd = []string{"sh", "-c", `read n; echo $n`}
+	_, i, err = e.Interact(user, interactor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i.ExitCode != 1 || i.Stderr != "got 21\n" {
+		t.Errorf("interactor should reject the answer, got %+v", i)
+	}
+
+	//用户程序不输出时双方都在等待，由时间限制结束
+	user.Cmd = []string{"sh", "-c", `read n; sleep 10`}
+	user.Limits.TimeLimit = 200
+	u, _, err = e.Interact(user, interactor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !u.TimedOut {
+		t.Errorf("user program should time out, got %+v", u)
+	}
+}
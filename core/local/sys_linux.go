@@ -0,0 +1,45 @@
+//go:build linux
+
+package local
+
+import (
+	"os"
+	"syscall"
+)
+
+// sysProcAttr 用户程序在独立的进程组中运行，开启命名空间时同时隔离网络、进程、挂载点等
+func sysProcAttr(namespaces bool) *syscall.SysProcAttr {
+	attr := &syscall.SysProcAttr{Setpgid: true}
+	if !namespaces {
+		return attr
+	}
+	attr.Cloneflags = syscall.CLONE_NEWUSER | syscall.CLONE_NEWNS | syscall.CLONE_NEWNET |
+		syscall.CLONE_NEWPID | syscall.CLONE_NEWIPC | syscall.CLONE_NEWUTS
+	attr.UidMappings = []syscall.SysProcIDMap{{ContainerID: os.Getuid(), HostID: os.Getuid(), Size: 1}}
+	attr.GidMappings = []syscall.SysProcIDMap{{ContainerID: os.Getgid(), HostID: os.Getgid(), Size: 1}}
+	return attr
+}
+
+// killGroup 结束程序及其创建的所有子进程
+func killGroup(p *os.Process) {
+	if p == nil {
+		return
+	}
+	_ = syscall.Kill(-p.Pid, syscall.SIGKILL)
+}
+
+// exitCode 被信号结束时与docker一致，返回 128+信号值
+func exitCode(state *os.ProcessState) int {
+	if ws, ok := state.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		return 128 + int(ws.Signal())
+	}
+	return state.ExitCode()
+}
+
+// maxRSS 程序的最大常驻内存，单位为kb
+func maxRSS(state *os.ProcessState) uint64 {
+	if usage, ok := state.SysUsage().(*syscall.Rusage); ok {
+		return uint64(usage.Maxrss)
+	}
+	return 0
+}
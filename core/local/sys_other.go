@@ -0,0 +1,29 @@
+//go:build !linux
+
+package local
+
+import (
+	"os"
+	"syscall"
+)
+
+// sysProcAttr 非linux系统不支持命名空间
+func sysProcAttr(namespaces bool) *syscall.SysProcAttr {
+	return nil
+}
+
+func killGroup(p *os.Process) {
+	if p == nil {
+		return
+	}
+	_ = p.Kill()
+}
+
+func exitCode(state *os.ProcessState) int {
+	return state.ExitCode()
+}
+
+// maxRSS 非linux系统不统计内存
+func maxRSS(state *os.ProcessState) uint64 {
+	return 0
+}
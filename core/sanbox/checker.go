@@ -0,0 +1,122 @@
+package sanbox
+
+import (
+	"fmt"
+	"github.com/xissg/userManageSystem/core/executor"
+	"github.com/xissg/userManageSystem/entity/model_question"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// 判题程序的检查结果
+const (
+	CheckAccepted = iota + 1 //判题程序认为答案正确
+	CheckRejected            //判题程序认为答案错误
+	CheckFailed              //判题程序自身异常
+)
+
+// maxCheckMessage 判题程序提示的最大长度
+const maxCheckMessage = 1024
+
+// CaseResult 一个用例的运行结果，使用判题程序时 Check 不为 0
+type CaseResult struct {
+	executor.Result
+	Check        int    //判题程序的检查结果
+	CheckMessage string //判题程序给出的提示
+}
+
+// finished 程序正常结束才需要检查输出
+func (r CaseResult) finished() bool {
+	return r.ExitCode == 0 && !r.TimedOut && !r.OOMKilled && !r.OutputLimitExceeded
+}
+
+// check 将正常结束的用例交给判题程序检查，判题程序和用例文件放在工作目录下的 checker 目录中
+func (s *SanBox) check(ctx *JudgeContext, results JudgeResult) error {
+	dir := filepath.Join(s.workDir, "checker")
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+
+	//每个用例的输入、用户输出和答案分别写入文件
+	var pending []int
+	for i := range results {
+		if !results[i].finished() {
+			continue
+		}
+		files := map[string]string{
+			caseFile(i, "in"):  ctx.JudgeCase[i].Input,
+			caseFile(i, "out"): results[i].ExecResult,
+			caseFile(i, "ans"): ctx.ExpectedOutput(i),
+		}
+		for name, content := range files {
+			if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+				return err
+			}
+		}
+		pending = append(pending, i)
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	tc, err := s.build("checker", ctx.Checker, dir)
+	if err != nil {
+		return err
+	}
+
+	session, err := s.executor.Prepare(tc.Image, dir, executor.Limits{})
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+	for _, i := range pending {
+		cmd := append(append([]string{}, tc.RunCmd...), caseFile(i, "in"), caseFile(i, "out"), caseFile(i, "ans"))
+		res, err := session.Run(cmd, "")
+		if err != nil {
+			return err
+		}
+		switch {
+		case res.TimedOut || res.OOMKilled:
+			results[i].Check = CheckFailed
+		case res.ExitCode == 0:
+			results[i].Check = CheckAccepted
+		case res.ExitCode == 1:
+			results[i].Check = CheckRejected
+		default:
+			results[i].Check = CheckFailed
+		}
+		message := strings.TrimSpace(res.ExecResult)
+		if message == "" {
+			message = strings.TrimSpace(res.Stderr)
+		}
+		results[i].CheckMessage = truncate(message, maxCheckMessage)
+	}
+	return nil
+}
+
+func caseFile(i int, ext string) string {
+	return fmt.Sprintf("case_%d.%s", i+1, ext)
+}
+
+// build 将题目上传的程序写入dir并编译，返回程序使用的工具链
+func (s *SanBox) build(name string, program *model_question.Checker, dir string) (*Toolchain, error) {
+	tc, err := GetToolchain(program.Language)
+	if err != nil {
+		return nil, err
+	}
+	if err = os.WriteFile(filepath.Join(dir, tc.SourceFile), []byte(program.Code), 0644); err != nil {
+		return nil, err
+	}
+	if len(tc.CompileCmd) == 0 {
+		return tc, nil
+	}
+	output, exitCode, err := s.executor.Compile(tc.Image, dir, tc.CompileCmd)
+	if err != nil {
+		return nil, err
+	}
+	if exitCode != 0 {
+		return nil, fmt.Errorf("%s compile error: %s", name, s.scrubOutput(output))
+	}
+	return tc, nil
+}
@@ -0,0 +1,92 @@
+package sanbox
+
+import (
+	"errors"
+	"github.com/xissg/userManageSystem/core/executor"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	//interactorGrace 交互程序比用户程序多运行的时间，单位为ms，保证用户程序先结束
+	interactorGrace = 1000
+	//brokenPipe 交互程序向已经退出的用户程序写入时被 SIGPIPE 结束的退出码
+	brokenPipe = 128 + 13
+)
+
+// interactorDir 交互程序与工作目录同级，不会被复制到用户程序的容器中
+func (s *SanBox) interactorDir() string {
+	return s.workDir + "-interactor"
+}
+
+// interact 每个用例同时运行用户程序和交互程序，由交互程序的退出码决定结果
+func (s *SanBox) interact(ctx *JudgeContext) (JudgeResult, error) {
+	interactor, ok := s.executor.(executor.Interactor)
+	if !ok {
+		return nil, errors.New("executor does not support interactive judging")
+	}
+	dir := s.interactorDir()
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	//交互程序以 输入文件 答案文件 两个参数运行
+	for i := range ctx.JudgeCase {
+		files := map[string]string{
+			caseFile(i, "in"):  ctx.JudgeCase[i].Input,
+			caseFile(i, "ans"): ctx.ExpectedOutput(i),
+		}
+		for name, content := range files {
+			if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+				return nil, err
+			}
+		}
+	}
+	tc, err := s.build("interactor", ctx.Interactor, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	limits := executor.Limits{
+		TimeLimit:   ctx.JudgeConfig.TimeLimit,
+		MemoryLimit: ctx.JudgeConfig.MemoryLimit,
+		OutputLimit: ctx.JudgeConfig.OutputLimit,
+	}.WithDefaults()
+	user := executor.Process{Image: s.toolchain.Image, Dir: s.workDir, Cmd: s.toolchain.RunCmd, Limits: limits}
+
+	var results JudgeResult
+	for i := range ctx.JudgeCase {
+		s.running(i+1, len(ctx.JudgeCase))
+		process := executor.Process{
+			Image:  tc.Image,
+			Dir:    dir,
+			Cmd:    append(append([]string{}, tc.RunCmd...), caseFile(i, "in"), caseFile(i, "ans")),
+			Limits: executor.Limits{TimeLimit: limits.TimeLimit + interactorGrace},
+		}
+		res, verdict, err := interactor.Interact(user, process)
+		if err != nil {
+			return nil, err
+		}
+		res.Stderr = s.scrubOutput(res.Stderr)
+		result := CaseResult{Result: res}
+		switch {
+		case verdict.TimedOut || verdict.OOMKilled:
+			result.Check = CheckFailed
+		case verdict.ExitCode == 0:
+			result.Check = CheckAccepted
+		case verdict.ExitCode == 1:
+			result.Check = CheckRejected
+		case verdict.ExitCode == brokenPipe:
+			//用户程序没有读完交互程序的输出就退出了
+			result.Check = CheckRejected
+		default:
+			result.Check = CheckFailed
+		}
+		result.CheckMessage = truncate(strings.TrimSpace(verdict.Stderr), maxCheckMessage)
+		results = append(results, result)
+		if s.stopAfter(i, result) {
+			break
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,98 @@
+package sanbox
+
+import (
+	"fmt"
+	"github.com/xissg/userManageSystem/common/constant"
+	"strings"
+	"sync"
+)
+
+// Toolchain 每种编程语言的编译与运行配置
+type Toolchain struct {
+	//语言名称，与 constant 中的 language 字段一致
+	Language string
+	//源文件名，如 Java 要求文件名与公共类名一致
+	SourceFile string
+	//运行代码所用的容器镜像
+	Image string
+	//编译命令，在源文件所在目录中执行，解释型语言为空
+	CompileCmd []string
+	//运行命令，在容器的工作目录中执行
+	RunCmd []string
+}
+
+var (
+	toolchainMu sync.RWMutex
+	toolchains  = make(map[string]*Toolchain)
+)
+
+// RegisterToolchain 注册或覆盖一种语言的工具链
+func RegisterToolchain(tc *Toolchain) {
+	toolchainMu.Lock()
+	defer toolchainMu.Unlock()
+	toolchains[strings.ToLower(tc.Language)] = tc
+}
+
+// GetToolchain 根据语言名称获取工具链，名称不区分大小写
+func GetToolchain(language string) (*Toolchain, error) {
+	toolchainMu.RLock()
+	defer toolchainMu.RUnlock()
+	tc, ok := toolchains[strings.ToLower(language)]
+	if !ok {
+		return nil, fmt.Errorf("unsupported language: %s", language)
+	}
+	return tc, nil
+}
+
+// toolchainImages 已注册的工具链用到的镜像，不重复
+func toolchainImages() []string {
+	toolchainMu.RLock()
+	defer toolchainMu.RUnlock()
+	seen := make(map[string]bool)
+	var images []string
+	for _, tc := range toolchains {
+		if !seen[tc.Image] {
+			seen[tc.Image] = true
+			images = append(images, tc.Image)
+		}
+	}
+	return images
+}
+
+// 内置支持的语言
+func init() {
+	RegisterToolchain(&Toolchain{
+		Language:   constant.Go,
+		SourceFile: "main.go",
+		Image:      "my-golang-image",
+		CompileCmd: []string{"go", "build", "-o", "main", "main.go"},
+		RunCmd:     []string{"./main"},
+	})
+	RegisterToolchain(&Toolchain{
+		Language:   constant.C,
+		SourceFile: "main.c",
+		Image:      "gcc:13",
+		CompileCmd: []string{"gcc", "-O2", "-std=c11", "-o", "main", "main.c", "-lm"},
+		RunCmd:     []string{"./main"},
+	})
+	RegisterToolchain(&Toolchain{
+		Language:   constant.Cpp,
+		SourceFile: "main.cpp",
+		Image:      "gcc:13",
+		CompileCmd: []string{"g++", "-O2", "-std=c++17", "-o", "main", "main.cpp"},
+		RunCmd:     []string{"./main"},
+	})
+	RegisterToolchain(&Toolchain{
+		Language:   constant.Java,
+		SourceFile: "Main.java",
+		Image:      "eclipse-temurin:17",
+		CompileCmd: []string{"javac", "-encoding", "UTF-8", "Main.java"},
+		RunCmd:     []string{"java", "-cp", ".", "Main"},
+	})
+	RegisterToolchain(&Toolchain{
+		Language:   constant.Python,
+		SourceFile: "main.py",
+		Image:      "python:3.12-slim",
+		RunCmd:     []string{"python3", "main.py"},
+	})
+}
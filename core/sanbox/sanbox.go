@@ -6,13 +6,18 @@ import (
 	"errors"
 	"fmt"
 	"github.com/google/uuid"
+	"github.com/xissg/userManageSystem/common/constant"
 	"github.com/xissg/userManageSystem/core/docker"
+	"github.com/xissg/userManageSystem/core/executor"
+	"github.com/xissg/userManageSystem/core/local"
 	"github.com/xissg/userManageSystem/entity/model_question"
+	"io"
 	"log"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync"
+	"unicode/utf8"
 )
 
 type JudgeContext struct {
@@ -24,6 +29,14 @@ type JudgeContext struct {
 	Code string `json:"code" `
 	// "判题用例json数组"
 	JudgeCase []model_question.JudgeCase `json:"judge_case" `
+	// "判题配置json对象"
+	JudgeConfig model_question.JudgeConfig `json:"judge_config"`
+	// "题目答案，与判题用例一一对应"
+	Answer []string `json:"answer"`
+	// "判题程序，compare_mode 为 checker 时使用"
+	Checker *model_question.Checker `json:"checker"`
+	// "交互程序，mode 为 interactive 时使用"
+	Interactor *model_question.Checker `json:"interactor"`
 }
 
 func ToJudgeContext(submit *model_question.QuestionSubmit, question *model_question.Question) *JudgeContext {
@@ -32,25 +45,156 @@ func ToJudgeContext(submit *model_question.QuestionSubmit, question *model_quest
 	if err != nil {
 		return nil
 	}
+	var judgeConfig model_question.JudgeConfig
+	if question.JudgeConfig != "" {
+		err = json.Unmarshal([]byte(question.JudgeConfig), &judgeConfig)
+		if err != nil {
+			return nil
+		}
+	}
+
+	var answer []string
+	if question.Answer != "" {
+		err = json.Unmarshal([]byte(question.Answer), &answer)
+		if err != nil {
+			return nil
+		}
+	}
+	var checker *model_question.Checker
+	if question.Checker != "" {
+		checker = &model_question.Checker{}
+		err = json.Unmarshal([]byte(question.Checker), checker)
+		if err != nil {
+			return nil
+		}
+	}
+	var interactor *model_question.Checker
+	if question.Interactor != "" {
+		interactor = &model_question.Checker{}
+		err = json.Unmarshal([]byte(question.Interactor), interactor)
+		if err != nil {
+			return nil
+		}
+	}
 
 	return &JudgeContext{
-		ID:        question.ID,
-		Language:  submit.Language,
-		Code:      submit.Code,
-		JudgeCase: judgeCase,
+		ID:          question.ID,
+		Language:    submit.Language,
+		Code:        submit.Code,
+		JudgeCase:   judgeCase,
+		JudgeConfig: judgeConfig,
+		Answer:      answer,
+		Checker:     checker,
+		Interactor:  interactor,
 	}
 }
 
-type JudgeResult []docker.Result
+// ExpectedOutput 第i个用例的答案，没有单独配置答案时使用用例的输出
+func (ctx *JudgeContext) ExpectedOutput(i int) string {
+	if i < len(ctx.Answer) {
+		return ctx.Answer[i]
+	}
+	return ctx.JudgeCase[i].Output
+}
+
+type JudgeResult []CaseResult
+
+// maxDiagnosticLen 返回给用户的编译输出和标准错误的最大长度
+const maxDiagnosticLen = 4096
+
+// CompileError 用户代码编译失败，Output 为编译器的输出
+type CompileError struct {
+	Output string
+}
+
+func (e *CompileError) Error() string {
+	return "compile error"
+}
+
+var (
+	executorOnce   sync.Once
+	sharedExecutor executor.Executor
+)
+
+// defaultExecutor 按 conf/sandbox.yaml 的 executor 配置选择执行后端，所有沙箱共用
+func defaultExecutor() executor.Executor {
+	executorOnce.Do(func() {
+		switch backend := executor.LoadBackend(); backend {
+		case executor.Local:
+			sharedExecutor = local.NewExecutorFromConfig()
+		default:
+			if backend != executor.Docker {
+				log.Printf("unknown executor %s, use docker", backend)
+			}
+			sharedExecutor = docker.NewExecutorFromConfig(toolchainImages()...)
+		}
+	})
+	return sharedExecutor
+}
+
+// Close 释放共用执行后端的资源，如容器池中预热的容器，进程退出前调用
+func Close() error {
+	//没有使用过沙箱时不再创建执行后端
+	executorOnce.Do(func() {})
+	if closer, ok := sharedExecutor.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
 
-// 代码逻辑，接收数据，将对象中code字段保存到文件，读取文件并编译，将编译后的文件复制到docker中进行运行，返回运行结果
+// 代码逻辑，接收数据，将对象中code字段保存到文件，在编译容器中编译，将编译产物复制到docker中进行运行，返回运行结果
 type SanBox struct {
-	filePath string
-	codePath string
+	executor  executor.Executor
+	toolchain *Toolchain
+	workDir   string
+	filePath  string
+	progress  Progress
+	stop      StopFunc
+}
+
+// StopFunc 每个用例运行后调用，返回 true 时不再运行之后的用例
+// 使用判题程序时输出尚未检查，只能根据运行状态判断
+type StopFunc func(i int, result CaseResult) bool
+
+// Progress 接收沙箱的判题进度
+type Progress interface {
+	Compiling()
+	//Running 开始运行第 current 个用例，从 1 开始
+	Running(current int, total int)
 }
 
 func NewSanBox() *SanBox {
-	return &SanBox{}
+	return NewSanBoxWithExecutor(defaultExecutor())
+}
+
+// NewSanBoxWithExecutor 使用指定的执行后端创建沙箱
+func NewSanBoxWithExecutor(exec executor.Executor) *SanBox {
+	return &SanBox{
+		executor: exec,
+	}
+}
+
+// WithProgress 判题过程中向 p 报告进度
+func (s *SanBox) WithProgress(p Progress) *SanBox {
+	s.progress = p
+	return s
+}
+
+// StopWhen 用例运行后由 stop 决定是否继续运行之后的用例
+func (s *SanBox) StopWhen(stop StopFunc) *SanBox {
+	s.stop = stop
+	return s
+}
+
+// stopAfter 是否在第i个用例之后停止
+func (s *SanBox) stopAfter(i int, result CaseResult) bool {
+	return s.stop != nil && s.stop(i, result)
+}
+
+func (s *SanBox) running(current int, total int) {
+	if s.progress != nil {
+		s.progress.Running(current, total)
+	}
 }
 
 func (s *SanBox) Start(ctx *JudgeContext) (JudgeResult, error) {
@@ -60,18 +204,43 @@ func (s *SanBox) Start(ctx *JudgeContext) (JudgeResult, error) {
 		return nil, err
 	}
 
+	//删除文件
+	defer func() {
+		err := s.deleteFile()
+		if err != nil {
+			log.Printf("delete file: %v", err)
+		}
+	}()
+
 	//开始编译
 	err = s.compile()
 	if err != nil {
 		return nil, err
 	}
 
+	//交互题由交互程序决定结果
+	if ctx.JudgeConfig.Mode == constant.ModeInteractive {
+		if ctx.Interactor == nil {
+			return nil, errors.New("interactor is not configured")
+		}
+		return s.interact(ctx)
+	}
+
 	//开始运行代码
-	res := s.run(ctx)
+	res, err := s.run(ctx)
+	if err != nil {
+		return nil, err
+	}
 
-	//删除文件
-	err = s.deleteFile()
-	log.Printf("delete file: %v", err)
+	//需要判题程序检查输出
+	if ctx.JudgeConfig.CompareMode == constant.CompareChecker {
+		if ctx.Checker == nil {
+			return nil, errors.New("checker is not configured")
+		}
+		if err = s.check(ctx, res); err != nil {
+			return nil, err
+		}
+	}
 
 	//处理返回结果
 	return res, nil
@@ -85,6 +254,14 @@ func (s *SanBox) preProcess(ctx *JudgeContext) error {
 		log.Println("data validate error:", err)
 		return err
 	}
+
+	//选择语言对应的工具链
+	s.toolchain, err = GetToolchain(ctx.Language)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+
 	//创建文件夹
 	folderPath := s.mkdir(ctx)
 	if folderPath == "" {
@@ -102,48 +279,102 @@ func (s *SanBox) preProcess(ctx *JudgeContext) error {
 	return nil
 }
 
-// 编译并保存可执行文件
+// 编译并保存可执行文件，解释型语言跳过编译
 func (s *SanBox) compile() error {
-	if s.filePath == "" || s.codePath == "" {
+	if s.filePath == "" || s.workDir == "" {
 		return errors.New("invalid file or code path")
 	}
+	if len(s.toolchain.CompileCmd) == 0 {
+		return nil
+	}
+	if s.progress != nil {
+		s.progress.Compiling()
+	}
 
-	//执行编译命令
-	cmd := exec.Command("go", "build", "-o", s.codePath, s.filePath)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	err := cmd.Run()
+	//执行编译命令，编译器输出需要处理后才能返回给用户
+	output, exitCode, err := s.executor.Compile(s.toolchain.Image, s.workDir, s.toolchain.CompileCmd)
 	if err != nil {
 		return err
 	}
+	if exitCode != 0 {
+		return &CompileError{Output: s.scrubOutput(output)}
+	}
 	return nil
 }
 
-func (s *SanBox) run(ctx *JudgeContext) JudgeResult {
+// scrubOutput 去掉编译输出和运行时标准错误中的沙箱路径，并截断到 maxDiagnosticLen
+func (s *SanBox) scrubOutput(output string) string {
+	for _, dir := range []string{s.workDir, docker.WorkDir} {
+		output = strings.ReplaceAll(output, dir+string(filepath.Separator), "")
+		output = strings.ReplaceAll(output, dir, ".")
+	}
 
-	if s.codePath == "" || ctx.JudgeCase == nil {
-		return nil
+	return truncate(output, maxDiagnosticLen)
+}
+
+// truncate 超过n字节时按字符截断，避免截出不完整的utf8编码
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
 	}
-	err := os.Chmod(s.codePath, 0755)
+	cut := n
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut] + "\n... (truncated)"
+}
+
+// run 依次运行每个用例，执行后端出错时返回错误，由调用方重试，返回的结果与用例一一对应，提前停止时结果少于用例
+func (s *SanBox) run(ctx *JudgeContext) (JudgeResult, error) {
+	limits := executor.Limits{
+		TimeLimit:   ctx.JudgeConfig.TimeLimit,
+		MemoryLimit: ctx.JudgeConfig.MemoryLimit,
+		OutputLimit: ctx.JudgeConfig.OutputLimit,
+	}
+	session, err := s.executor.Prepare(s.toolchain.Image, s.workDir, limits)
 	if err != nil {
-		return nil
+		return nil, fmt.Errorf("prepare executor: %w", err)
 	}
+	defer func() {
+		if err := session.Close(); err != nil {
+			log.Println("close executor session:", err)
+		}
+	}()
 
 	var results JudgeResult
-	for _, v := range ctx.JudgeCase {
+	for i, v := range ctx.JudgeCase {
+		s.running(i+1, len(ctx.JudgeCase))
 		//多次执行结果
-		result, err := docker.Docker(s.codePath, v.Input)
+		args, input := caseInput(v, ctx.JudgeConfig.InputMode)
+		cmd := append(append([]string{}, s.toolchain.RunCmd...), args...)
+		result, err := session.Run(cmd, input)
 		if err != nil {
-			return nil
+			return nil, fmt.Errorf("run case: %w", err)
+		}
+		result.Stderr = s.scrubOutput(result.Stderr)
+		results = append(results, CaseResult{Result: result})
+		if s.stopAfter(i, results[i]) {
+			break
 		}
-		results = append(results, result)
 	}
 
-	return results
+	return results, nil
+}
+
+// caseInput 根据题目的输入方式，返回用例的命令行参数和标准输入
+// 旧题目的判题配置中没有输入方式，仍按命令行参数传入，只有显式指定 stdin 时才使用标准输入
+func caseInput(judgeCase model_question.JudgeCase, inputMode string) ([]string, string) {
+	if inputMode == constant.InputStdin {
+		return nil, judgeCase.Input
+	}
+	return strings.Split(judgeCase.Input, " "), ""
 }
 
 // 数据校验
 func (s *SanBox) checkData(ctx *JudgeContext) error {
+	if ctx == nil {
+		return errors.New("invalid judge context")
+	}
 	if ctx.ID == "" {
 		return errors.New("invalid submit id")
 	}
@@ -157,10 +388,14 @@ func (s *SanBox) checkData(ctx *JudgeContext) error {
 }
 
 func (s *SanBox) mkdir(ctx *JudgeContext) string {
-	// 指定文件夹路径
+	// 指定文件夹路径，每次提交使用独立的目录，避免同一题目的并发提交互相覆盖
 	path, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
 	parentDir := filepath.Dir(path)
-	folderPath := filepath.Join(parentDir, "tmp", ctx.ID)
+	id := strings.Split(uuid.NewString(), "-")[0]
+	folderPath := filepath.Join(parentDir, "tmp", ctx.ID, id)
 	_, err = os.Stat(folderPath)
 	if os.IsNotExist(err) {
 		// 创建文件夹
@@ -174,19 +409,21 @@ func (s *SanBox) mkdir(ctx *JudgeContext) string {
 
 // 创建并写入代码
 func (s *SanBox) touchAndWrite(folderPath string, ctx *JudgeContext) error {
-	//生成文件名
-	id := strings.Split(uuid.NewString(), "-")[0]
-	fileName := fmt.Sprintf("%s.%s", id, strings.ToLower(ctx.Language))
-
-	//保存源文件,以及以后生成可执行文件名
-	s.filePath = filepath.Join(folderPath, fileName)
-	s.codePath = filepath.Join(folderPath, id)
+	//保存源文件以及工作目录，文件名由工具链决定
+	s.workDir = folderPath
+	s.filePath = filepath.Join(folderPath, s.toolchain.SourceFile)
 	// 创建文件
 	file, err := os.Create(s.filePath)
 	if err != nil {
 		fmt.Println("Error creating file:", err)
 		return err
 	}
+	defer func(file *os.File) {
+		err := file.Close()
+		if err != nil {
+
+		}
+	}(file)
 
 	//读取并写入文件
 	writer := bufio.NewWriter(file)
@@ -201,19 +438,19 @@ func (s *SanBox) touchAndWrite(folderPath string, ctx *JudgeContext) error {
 		return err
 	}
 
-	defer func(file *os.File) {
-		err := file.Close()
-		if err != nil {
-
-		}
-	}(file)
-
 	return nil
 }
 
 // 删除临时文件
 func (s *SanBox) deleteFile() error {
-	err := os.RemoveAll(filepath.Dir(s.filePath))
+	if s.workDir == "" {
+		return nil
+	}
+	err := os.RemoveAll(s.workDir)
+	if err != nil {
+		return err
+	}
+	err = os.RemoveAll(s.interactorDir())
 	if err != nil {
 		return err
 	}
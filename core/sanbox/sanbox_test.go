@@ -1,9 +1,19 @@
 package sanbox
 
 import (
+	"errors"
 	"fmt"
+	"github.com/xissg/userManageSystem/common/constant"
+	"github.com/xissg/userManageSystem/core/docker"
+	"github.com/xissg/userManageSystem/core/executor"
+	"github.com/xissg/userManageSystem/core/local"
 	"github.com/xissg/userManageSystem/entity/model_question"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
 	"testing"
+	"unicode/utf8"
 )
 
 func TestPreProcess(t *testing.T) {
@@ -12,6 +22,8 @@ func TestPreProcess(t *testing.T) {
 		Language:  "Go",
 		Code:      "package main\n\nimport (\n\t\"fmt\"\n\t\"os\"\n\t\"strconv\"\n)\n\nfunc main() {\n\targs := os.Args[1:]\n\tsum := 0\n\tfor _, arg := range args {\n\t\tnum, _ := strconv.Atoi(arg)\n\t\tsum += num\n\t\t\t}\n\tfmt.Println(sum)\n}\n",
 		JudgeCase: []model_question.JudgeCase{{Input: "1 2", Output: "3"}},
+		//程序从命令行参数读取输入
+		JudgeConfig: model_question.JudgeConfig{InputMode: constant.InputArgs},
 	}
 
 	san := NewSanBox()
@@ -26,3 +38,347 @@ func TestPreProcess(t *testing.T) {
 		fmt.Printf("cost memory: %v\n", test.Memory)
 	}
 }
+
+// fakeExecutor 记录沙箱的调用，不依赖docker
+type fakeExecutor struct {
+	compiled []string
+	ran      [][]string
+	source   string
+	stderr   string
+	prepared int
+	closed   int
+}
+
+func (f *fakeExecutor) Compile(image string, dir string, cmd []string) (string, int, error) {
+	f.compiled = append(f.compiled, image)
+	files, err := os.ReadDir(dir)
+	if err != nil || len(files) != 1 {
+		return "", -1, fmt.Errorf("unexpected source files %v: %v", files, err)
+	}
+	code, err := os.ReadFile(filepath.Join(dir, files[0].Name()))
+	if err != nil {
+		return "", -1, err
+	}
+	f.source = string(code)
+	return "", 0, nil
+}
+
+func (f *fakeExecutor) Prepare(image string, dir string, limits executor.Limits) (executor.Session, error) {
+	f.prepared++
+	return &fakeSession{executor: f, image: image}, nil
+}
+
+type fakeSession struct {
+	executor *fakeExecutor
+	image    string
+}
+
+func (s *fakeSession) Run(cmd []string, input string) (executor.Result, error) {
+	s.executor.ran = append(s.executor.ran, cmd)
+	return executor.Result{ExecResult: s.image + ":" + input, Stderr: s.executor.stderr}, nil
+}
+
+func (s *fakeSession) Close() error {
+	s.executor.closed++
+	return nil
+}
+
+func TestToolchains(t *testing.T) {
+	languages := []string{constant.Go, constant.C, constant.Cpp, constant.Java, constant.Python}
+	for _, lang := range languages {
+		tc, err := GetToolchain(strings.ToUpper(lang))
+		if err != nil {
+			t.Fatalf("%s: %v", lang, err)
+		}
+		if tc.SourceFile == "" || tc.Image == "" || len(tc.RunCmd) == 0 {
+			t.Fatalf("%s: incomplete toolchain %+v", lang, tc)
+		}
+
+		runner := &fakeExecutor{}
+		ctx := &JudgeContext{
+			ID:          "test",
+			Language:    lang,
+			Code:        "code of " + lang,
+			JudgeCase:   []model_question.JudgeCase{{Input: "1 2", Output: "3"}, {Input: "3 4", Output: "7"}},
+			JudgeConfig: model_question.JudgeConfig{InputMode: constant.InputStdin},
+		}
+		results, err := NewSanBoxWithExecutor(runner).Start(ctx)
+		if err != nil {
+			t.Fatalf("%s: %v", lang, err)
+		}
+
+		wantCompile := len(tc.CompileCmd) > 0
+		if (len(runner.compiled) == 1) != wantCompile {
+			t.Errorf("%s: compiled %d times, want compile %v", lang, len(runner.compiled), wantCompile)
+		}
+		if wantCompile && runner.source != ctx.Code {
+			t.Errorf("%s: compiled source %q, want %q", lang, runner.source, ctx.Code)
+		}
+		if len(results) != len(ctx.JudgeCase) {
+			t.Fatalf("%s: got %d results, want %d", lang, len(results), len(ctx.JudgeCase))
+		}
+		if runner.prepared != 1 || runner.closed != 1 {
+			t.Errorf("%s: all cases should share one session, prepared %d closed %d", lang, runner.prepared, runner.closed)
+		}
+		if results[1].ExecResult != tc.Image+":3 4" {
+			t.Errorf("%s: unexpected result %q", lang, results[1].ExecResult)
+		}
+	}
+
+	if _, err := GetToolchain("rust"); err == nil {
+		t.Error("unregistered language should be rejected")
+	}
+}
+
+// recordProgress 记录沙箱报告的进度
+type recordProgress []string
+
+func (p *recordProgress) Compiling() {
+	*p = append(*p, "compiling")
+}
+
+func (p *recordProgress) Running(current int, total int) {
+	*p = append(*p, fmt.Sprintf("running %d/%d", current, total))
+}
+
+func TestProgress(t *testing.T) {
+	ctx := &JudgeContext{
+		ID:        "test",
+		Language:  constant.Go,
+		Code:      "code",
+		JudgeCase: []model_question.JudgeCase{{Input: "1 2", Output: "3"}, {Input: "3 4", Output: "7"}},
+	}
+	var p recordProgress
+	if _, err := NewSanBoxWithExecutor(&fakeExecutor{}).WithProgress(&p).Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(p, ", "); got != "compiling, running 1/2, running 2/2" {
+		t.Errorf("unexpected progress %s", got)
+	}
+}
+
+func TestStopWhen(t *testing.T) {
+	ctx := &JudgeContext{
+		ID:          "test",
+		Language:    constant.Python,
+		Code:        "code",
+		JudgeCase:   []model_question.JudgeCase{{Input: "1"}, {Input: "2"}, {Input: "3"}},
+		JudgeConfig: model_question.JudgeConfig{InputMode: constant.InputStdin},
+	}
+	box := NewSanBoxWithExecutor(&fakeExecutor{}).StopWhen(func(i int, result CaseResult) bool {
+		return strings.HasSuffix(result.ExecResult, ":2")
+	})
+	results, err := box.Start(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Errorf("cases after the failure should not run, got %d results", len(results))
+	}
+}
+
+func TestScrubOutput(t *testing.T) {
+	san := &SanBox{workDir: "/srv/oj/tmp/q1/abcd"}
+	output := "/srv/oj/tmp/q1/abcd/main.go:3:1: syntax error\n/app/main.go:4:2: undefined: x\n"
+	got := san.scrubOutput(output)
+	want := "main.go:3:1: syntax error\nmain.go:4:2: undefined: x\n"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	long := strings.Repeat("错", maxDiagnosticLen)
+	got = san.scrubOutput(long)
+	if !strings.HasSuffix(got, "(truncated)") || len(got) > maxDiagnosticLen+32 || !utf8.ValidString(got) {
+		t.Errorf("unexpected truncation, length %d", len(got))
+	}
+}
+
+func TestScrubStderr(t *testing.T) {
+	ctx := &JudgeContext{
+		ID:        "test",
+		Language:  constant.Go,
+		Code:      "package main",
+		JudgeCase: []model_question.JudgeCase{{Input: "1 2", Output: "3"}},
+	}
+	runner := &fakeExecutor{stderr: "panic: boom\n\t" + docker.WorkDir + "/main.go:4 +0x1d\n" + strings.Repeat("x", maxDiagnosticLen)}
+	results, err := NewSanBoxWithExecutor(runner).Start(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := results[0].Stderr
+	if strings.Contains(got, docker.WorkDir) || !strings.Contains(got, "\tmain.go:4") {
+		t.Errorf("sandbox path should be removed from stderr, got %q", got[:64])
+	}
+	if !strings.HasSuffix(got, "(truncated)") || len(got) > maxDiagnosticLen+32 {
+		t.Errorf("stderr should be truncated, length %d", len(got))
+	}
+}
+
+func TestInputMode(t *testing.T) {
+	judgeCase := []model_question.JudgeCase{{Input: "1 2", Output: "3"}}
+	for _, mode := range []string{"", constant.InputStdin, constant.InputArgs} {
+		runner := &fakeExecutor{}
+		ctx := &JudgeContext{
+			ID:          "test",
+			Language:    constant.Go,
+			Code:        "package main",
+			JudgeCase:   judgeCase,
+			JudgeConfig: model_question.JudgeConfig{InputMode: mode},
+		}
+		results, err := NewSanBoxWithExecutor(runner).Start(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		//没有指定输入方式的旧题目仍按命令行参数传入
+		if mode != constant.InputStdin {
+			if len(runner.ran[0]) != 3 || runner.ran[0][2] != "2" || results[0].ExecResult != "my-golang-image:" {
+				t.Errorf("args mode %q: got cmd %v, stdin %q", mode, runner.ran[0], results[0].ExecResult)
+			}
+			continue
+		}
+		if len(runner.ran[0]) != 1 || results[0].ExecResult != "my-golang-image:1 2" {
+			t.Errorf("stdin mode: got cmd %v, stdin %q", runner.ran[0], results[0].ExecResult)
+		}
+	}
+}
+
+// TestLocalExecutor 使用本机执行后端跑通完整的编译运行流程，本机没有go时跳过
+func TestLocalExecutor(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go toolchain unavailable")
+	}
+	ctx := &JudgeContext{
+		ID:          "test",
+		Language:    constant.Go,
+		Code:        "package main\n\nimport \"fmt\"\n\nfunc main() {\n\tvar a, b int\n\tfmt.Scan(&a, &b)\n\tfmt.Println(a + b)\n}\n",
+		JudgeCase:   []model_question.JudgeCase{{Input: "1 2", Output: "3"}, {Input: "3 4", Output: "7"}},
+		JudgeConfig: model_question.JudgeConfig{InputMode: constant.InputStdin},
+	}
+	results, err := NewSanBoxWithExecutor(local.NewExecutor(local.Config{})).Start(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].ExecResult != "3\n" || results[1].ExecResult != "7\n" {
+		t.Errorf("unexpected results %+v", results)
+	}
+
+	ctx.Code = "package main\n\nfunc main() {\n\tundefined()\n}\n"
+	_, err = NewSanBoxWithExecutor(local.NewExecutor(local.Config{})).Start(ctx)
+	var compileErr *CompileError
+	if !errors.As(err, &compileErr) || !strings.Contains(compileErr.Output, "undefined") {
+		t.Errorf("compile error expected, got %v", err)
+	}
+}
+
+// TestChecker 题目有多个正确答案，由判题程序检查，本机没有python时跳过
+func TestChecker(t *testing.T) {
+	if _, err := exec.LookPath("python3"); err != nil {
+		t.Skip("python3 unavailable")
+	}
+	//输出任意两个和为 n 的正整数
+	checker := "import sys\n" +
+		"n = int(open(sys.argv[1]).read())\n" +
+		"try:\n" +
+		"    a, b = map(int, open(sys.argv[2]).read().split())\n" +
+		"except ValueError:\n" +
+		"    print('expected two integers')\n" +
+		"    sys.exit(1)\n" +
+		"if a <= 0 or b <= 0 or a + b != n:\n" +
+		"    print('%d + %d != %d' % (a, b, n))\n" +
+		"    sys.exit(1)\n"
+	ctx := &JudgeContext{
+		ID:          "test",
+		Language:    constant.Python,
+		Code:        "n = int(input())\nprint(1, n - 2 if n == 5 else n - 1)\n",
+		JudgeCase:   []model_question.JudgeCase{{Input: "3", Output: "1 2"}, {Input: "5", Output: "2 3"}, {Input: "x"}},
+		JudgeConfig: model_question.JudgeConfig{CompareMode: constant.CompareChecker, InputMode: constant.InputStdin},
+		Checker:     &model_question.Checker{Language: constant.Python, Code: checker},
+	}
+	results, err := NewSanBoxWithExecutor(local.NewExecutor(local.Config{})).Start(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results[0].Check != CheckAccepted {
+		t.Errorf("a different valid answer should be accepted: %+v", results[0])
+	}
+	if results[1].Check != CheckRejected || results[1].CheckMessage != "1 + 3 != 5" {
+		t.Errorf("wrong answer should be rejected with a message: %+v", results[1])
+	}
+	if results[2].ExitCode == 0 || results[2].Check != 0 {
+		t.Errorf("crashed program should not be checked: %+v", results[2])
+	}
+
+	ctx.Checker = nil
+	if _, err = NewSanBoxWithExecutor(local.NewExecutor(local.Config{})).Start(ctx); err == nil {
+		t.Error("missing checker should be reported")
+	}
+}
+
+// TestInteractive 用户程序通过交互猜数，由交互程序判断，本机没有python时跳过
+func TestInteractive(t *testing.T) {
+	if _, err := exec.LookPath("python3"); err != nil {
+		t.Skip("python3 unavailable")
+	}
+	//每次回答 < > = ，最多允许猜 10 次
+	interactor := "import sys\n" +
+		"n = int(open(sys.argv[1]).read())\n" +
+		"for _ in range(10):\n" +
+		"    g = int(input())\n" +
+		"    if g == n:\n" +
+		"        print('=', flush=True)\n" +
+		"        sys.exit(0)\n" +
+		"    print('<' if n < g else '>', flush=True)\n" +
+		"sys.stderr.write('too many guesses')\n" +
+		"sys.exit(1)\n"
+	binarySearch := "lo, hi = 1, 1000\n" +
+		"while True:\n" +
+		"    mid = (lo + hi) // 2\n" +
+		"    print(mid, flush=True)\n" +
+		"    r = input()\n" +
+		"    if r == '=':\n" +
+		"        break\n" +
+		"    if r == '<':\n" +
+		"        hi = mid - 1\n" +
+		"    else:\n" +
+		"        lo = mid + 1\n"
+	ctx := &JudgeContext{
+		ID:          "test",
+		Language:    constant.Python,
+		Code:        binarySearch,
+		JudgeCase:   []model_question.JudgeCase{{Input: "1"}, {Input: "777"}},
+		JudgeConfig: model_question.JudgeConfig{Mode: constant.ModeInteractive, TimeLimit: 5000},
+		Interactor:  &model_question.Checker{Language: constant.Python, Code: interactor},
+	}
+	box := NewSanBoxWithExecutor(local.NewExecutor(local.Config{}))
+	results, err := box.Start(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, res := range results {
+		if res.ExitCode != 0 || res.Check != CheckAccepted {
+			t.Errorf("case %d should be accepted: %+v", i, res)
+		}
+	}
+	if _, err = os.Stat(box.interactorDir()); !os.IsNotExist(err) {
+		t.Error("interactor directory should be removed")
+	}
+
+	//逐个猜测超过次数限制
+	ctx.Code = "g = 1\nprint(g, flush=True)\nwhile input() != '=':\n    g += 1\n    print(g, flush=True)\n"
+	results, err = NewSanBoxWithExecutor(local.NewExecutor(local.Config{})).Start(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results[0].Check != CheckAccepted {
+		t.Errorf("first guess should be accepted: %+v", results[0])
+	}
+	if results[1].Check != CheckRejected || results[1].CheckMessage != "too many guesses" {
+		t.Errorf("too many guesses should be rejected with a message: %+v", results[1])
+	}
+
+	ctx.Interactor = nil
+	if _, err = NewSanBoxWithExecutor(local.NewExecutor(local.Config{})).Start(ctx); err == nil {
+		t.Error("missing interactor should be reported")
+	}
+}
@@ -332,6 +332,246 @@ const docTemplate = `{
                 }
             }
         },
+        "/api/role/assign": {
+            "post": {
+                "description": "Assign an additional role to a user, the permissions take effect on the next request",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Role"
+                ],
+                "summary": "Assign role",
+                "parameters": [
+                    {
+                        "description": "User account and role",
+                        "name": "role",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model_user.AssignRoleRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Assign success",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/api_response.ApiResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "object"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Assign fail",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/api_response.ApiResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "object"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
+        "/api/role/list": {
+            "get": {
+                "description": "List every role and its permissions",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Role"
+                ],
+                "summary": "List roles",
+                "responses": {
+                    "200": {
+                        "description": "Query success",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/api_response.ApiResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/model_user.RoleInfo"
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Query fail",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/api_response.ApiResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "object"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
+        "/api/role/revoke": {
+            "post": {
+                "description": "Revoke an additional role from a user",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Role"
+                ],
+                "summary": "Revoke role",
+                "parameters": [
+                    {
+                        "description": "User account and role",
+                        "name": "role",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model_user.AssignRoleRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Revoke success",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/api_response.ApiResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "object"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Revoke fail",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/api_response.ApiResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "object"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
+        "/api/role/user/{account}": {
+            "get": {
+                "description": "Get the base role, assigned roles and resulting permissions of a user",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Role"
+                ],
+                "summary": "Get user roles",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User account",
+                        "name": "account",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Query success",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/api_response.ApiResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/model_user.UserRoles"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Query fail",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/api_response.ApiResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "object"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
         "/api/submit/add": {
             "post": {
                 "description": "Submit",
@@ -344,21 +584,242 @@ const docTemplate = `{
                 "tags": [
                     "QuestionSubmit"
                 ],
-                "summary": "Submit",
+                "summary": "Submit",
+                "parameters": [
+                    {
+                        "description": "Submit code",
+                        "name": "model_question",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model_question.AddQuestionSubmitRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Submit success",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/api_response.ApiResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "string"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Submit failed",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/api_response.ApiResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "object"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
+        "/api/submit/admin/dead": {
+            "get": {
+                "description": "List ids of submissions that failed with System Error after all retries",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "QuestionSubmit"
+                ],
+                "summary": "Get dead letter submits",
+                "responses": {
+                    "200": {
+                        "description": "Query success",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/api_response.ApiResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "array",
+                                            "items": {
+                                                "type": "string"
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Query fail",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/api_response.ApiResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "object"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
+        "/api/submit/admin/requeue/{id}": {
+            "post": {
+                "description": "Reset a dead letter submission to waiting and send it back to the judge queue",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "QuestionSubmit"
+                ],
+                "summary": "Requeue dead letter submit",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Submit id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Requeue success",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/api_response.ApiResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "string"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Requeue fail",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/api_response.ApiResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "object"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
+        "/api/submit/progress/{id}": {
+            "get": {
+                "description": "Server-sent events with the judge progress of a submission, the stream ends after the finished event",
+                "produces": [
+                    "text/event-stream"
+                ],
+                "tags": [
+                    "QuestionSubmit"
+                ],
+                "summary": "Stream judge progress",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Submit id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Progress events",
+                        "schema": {
+                            "$ref": "#/definitions/progress.Event"
+                        }
+                    },
+                    "400": {
+                        "description": "Subscribe fail",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/api_response.ApiResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "object"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
+        "/api/submit/query": {
+            "get": {
+                "description": "Get question submit result",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "QuestionSubmit"
+                ],
+                "summary": "Get question submit result",
                 "parameters": [
                     {
-                        "description": "Submit code",
-                        "name": "model_question",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/model_question.AddQuestionSubmitRequest"
-                        }
+                        "type": "string",
+                        "description": "Query id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Submit success",
+                        "description": "Query  success",
                         "schema": {
                             "allOf": [
                                 {
@@ -368,7 +829,7 @@ const docTemplate = `{
                                     "type": "object",
                                     "properties": {
                                         "data": {
-                                            "type": "string"
+                                            "$ref": "#/definitions/model_question.ReturnQS"
                                         }
                                     }
                                 }
@@ -376,7 +837,7 @@ const docTemplate = `{
                         }
                     },
                     "400": {
-                        "description": "Submit failed",
+                        "description": "Query fail",
                         "schema": {
                             "allOf": [
                                 {
@@ -394,11 +855,9 @@ const docTemplate = `{
                         }
                     }
                 }
-            }
-        },
-        "/api/submit/query": {
-            "get": {
-                "description": "Get question submit result",
+            },
+            "post": {
+                "description": "Get question submit list",
                 "consumes": [
                     "application/json"
                 ],
@@ -408,14 +867,16 @@ const docTemplate = `{
                 "tags": [
                     "QuestionSubmit"
                 ],
-                "summary": "Get question submit result",
+                "summary": "Get question submit list",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "Query id",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
+                        "description": "Query condition",
+                        "name": "query",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model_question.QueryQuestionSubmitRequest"
+                        }
                     }
                 ],
                 "responses": {
@@ -430,7 +891,10 @@ const docTemplate = `{
                                     "type": "object",
                                     "properties": {
                                         "data": {
-                                            "$ref": "#/definitions/model_question.ReturnQS"
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/model_question.ReturnQS"
+                                            }
                                         }
                                     }
                                 }
@@ -456,9 +920,11 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
+            }
+        },
+        "/api/user/admin/ban": {
             "post": {
-                "description": "Get question submit list",
+                "description": "Ban a user or lift the ban, an unbanned user becomes a common user",
                 "consumes": [
                     "application/json"
                 ],
@@ -466,23 +932,23 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "QuestionSubmit"
+                    "User"
                 ],
-                "summary": "Get question submit list",
+                "summary": "Ban user",
                 "parameters": [
                     {
-                        "description": "Query condition",
-                        "name": "query",
+                        "description": "User account",
+                        "name": "user",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/model_question.QueryQuestionSubmitRequest"
+                            "$ref": "#/definitions/model_user.BanUserRequest"
                         }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Query  success",
+                        "description": "Ban user success",
                         "schema": {
                             "allOf": [
                                 {
@@ -492,10 +958,7 @@ const docTemplate = `{
                                     "type": "object",
                                     "properties": {
                                         "data": {
-                                            "type": "array",
-                                            "items": {
-                                                "$ref": "#/definitions/model_question.ReturnQS"
-                                            }
+                                            "type": "object"
                                         }
                                     }
                                 }
@@ -503,7 +966,7 @@ const docTemplate = `{
                         }
                     },
                     "400": {
-                        "description": "Query fail",
+                        "description": "Ban user fail",
                         "schema": {
                             "allOf": [
                                 {
@@ -652,6 +1115,70 @@ const docTemplate = `{
                 }
             }
         },
+        "/api/user/admin/unlock": {
+            "post": {
+                "description": "Clear the failed login attempts of an account and lift its lockout, lockouts of client IPs are kept",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "User"
+                ],
+                "summary": "Unlock user",
+                "parameters": [
+                    {
+                        "description": "User account",
+                        "name": "user",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model_user.UnlockUserRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Unlock user success",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/api_response.ApiResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "object"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Unlock user fail",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/api_response.ApiResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "object"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
         "/api/user/admin/update": {
             "post": {
                 "description": "Admin edit user information",
@@ -696,7 +1223,139 @@ const docTemplate = `{
                         }
                     },
                     "400": {
-                        "description": "Edit user request fail",
+                        "description": "Edit user request fail",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/api_response.ApiResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "object"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
+        "/api/user/login": {
+            "post": {
+                "description": "User login",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "User"
+                ],
+                "summary": "Login",
+                "parameters": [
+                    {
+                        "description": "User information",
+                        "name": "user",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model_user.LoginUserRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Login success",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/api_response.ApiResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/model_user.ReturnUser"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Login fail",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/api_response.ApiResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "object"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "429": {
+                        "description": "Too many failed attempts",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/api_response.ApiResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "object"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
+        "/api/user/logout": {
+            "get": {
+                "description": "User logout",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "User"
+                ],
+                "summary": "Logout",
+                "responses": {
+                    "200": {
+                        "description": "Logout success",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/api_response.ApiResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "object"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Logout fail",
                         "schema": {
                             "allOf": [
                                 {
@@ -716,9 +1375,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/user/login": {
+        "/api/user/query": {
             "post": {
-                "description": "User login",
+                "description": "Query user list",
                 "consumes": [
                     "application/json"
                 ],
@@ -728,21 +1387,21 @@ const docTemplate = `{
                 "tags": [
                     "User"
                 ],
-                "summary": "Login",
+                "summary": "Query user",
                 "parameters": [
                     {
-                        "description": "User information",
+                        "description": "Query",
                         "name": "user",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/model_user.LoginUserRequest"
+                            "$ref": "#/definitions/model_user.UserQueryRequest"
                         }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Login success",
+                        "description": "Query success",
                         "schema": {
                             "allOf": [
                                 {
@@ -752,7 +1411,10 @@ const docTemplate = `{
                                     "type": "object",
                                     "properties": {
                                         "data": {
-                                            "$ref": "#/definitions/model_user.ReturnUser"
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/model_user.ReturnUser"
+                                            }
                                         }
                                     }
                                 }
@@ -760,7 +1422,7 @@ const docTemplate = `{
                         }
                     },
                     "400": {
-                        "description": "Login fail",
+                        "description": "Query fail",
                         "schema": {
                             "allOf": [
                                 {
@@ -780,19 +1442,33 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/user/logout": {
-            "get": {
-                "description": "User logout",
+        "/api/user/register": {
+            "post": {
+                "description": "Register a new user",
+                "consumes": [
+                    "application/json"
+                ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
                     "User"
                 ],
-                "summary": "Logout",
+                "summary": "Register",
+                "parameters": [
+                    {
+                        "description": "User information",
+                        "name": "user",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model_user.AddUserRequest"
+                        }
+                    }
+                ],
                 "responses": {
                     "200": {
-                        "description": "Logout success",
+                        "description": "Register success",
                         "schema": {
                             "allOf": [
                                 {
@@ -810,7 +1486,7 @@ const docTemplate = `{
                         }
                     },
                     "400": {
-                        "description": "Logout fail",
+                        "description": "Register fail",
                         "schema": {
                             "allOf": [
                                 {
@@ -830,9 +1506,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/user/query": {
+        "/api/user/token/refresh": {
             "post": {
-                "description": "Query user list",
+                "description": "Exchange a refresh token for a new access and refresh token pair, the old refresh token can not be used again",
                 "consumes": [
                     "application/json"
                 ],
@@ -842,21 +1518,21 @@ const docTemplate = `{
                 "tags": [
                     "User"
                 ],
-                "summary": "Query user",
+                "summary": "Refresh token",
                 "parameters": [
                     {
-                        "description": "Query",
-                        "name": "user",
+                        "description": "Refresh token",
+                        "name": "token",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/model_user.UserQueryRequest"
+                            "$ref": "#/definitions/model_user.RefreshTokenRequest"
                         }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Query success",
+                        "description": "Refresh success",
                         "schema": {
                             "allOf": [
                                 {
@@ -866,10 +1542,7 @@ const docTemplate = `{
                                     "type": "object",
                                     "properties": {
                                         "data": {
-                                            "type": "array",
-                                            "items": {
-                                                "$ref": "#/definitions/model_user.ReturnUser"
-                                            }
+                                            "$ref": "#/definitions/model_user.TokenPair"
                                         }
                                     }
                                 }
@@ -877,7 +1550,7 @@ const docTemplate = `{
                         }
                     },
                     "400": {
-                        "description": "Query fail",
+                        "description": "Refresh fail",
                         "schema": {
                             "allOf": [
                                 {
@@ -897,9 +1570,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/user/register": {
+        "/api/user/token/revoke": {
             "post": {
-                "description": "Register a new user",
+                "description": "Revoke an access or refresh token before it expires",
                 "consumes": [
                     "application/json"
                 ],
@@ -909,21 +1582,21 @@ const docTemplate = `{
                 "tags": [
                     "User"
                 ],
-                "summary": "Register",
+                "summary": "Revoke token",
                 "parameters": [
                     {
-                        "description": "User information",
-                        "name": "user",
+                        "description": "Token",
+                        "name": "token",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/model_user.AddUserRequest"
+                            "$ref": "#/definitions/model_user.RevokeTokenRequest"
                         }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Register success",
+                        "description": "Revoke success",
                         "schema": {
                             "allOf": [
                                 {
@@ -941,7 +1614,7 @@ const docTemplate = `{
                         }
                     },
                     "400": {
-                        "description": "Register fail",
+                        "description": "Revoke fail",
                         "schema": {
                             "allOf": [
                                 {
@@ -1049,10 +1722,26 @@ const docTemplate = `{
                         "type": "string"
                     }
                 },
+                "checker": {
+                    "description": "\"判题程序，compare_mode 为 checker 时必填\"",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/model_question.Checker"
+                        }
+                    ]
+                },
                 "content": {
                     "description": "\"内容\"",
                     "type": "string"
                 },
+                "interactor": {
+                    "description": "\"交互程序，mode 为 interactive 时必填\"",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/model_question.Checker"
+                        }
+                    ]
+                },
                 "judge_case": {
                     "description": "\"判题用例json数组\"",
                     "type": "array",
@@ -1099,6 +1788,17 @@ const docTemplate = `{
                 }
             }
         },
+        "model_question.Checker": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string"
+                },
+                "language": {
+                    "type": "string"
+                }
+            }
+        },
         "model_question.JudgeCase": {
             "type": "object",
             "properties": {
@@ -1107,16 +1807,54 @@ const docTemplate = `{
                 },
                 "output": {
                     "type": "string"
+                },
+                "score": {
+                    "description": "用例的分数，所有用例都为 0 时不计分",
+                    "type": "integer"
+                },
+                "subtask": {
+                    "description": "所属的子任务，为 0 时不属于任何子任务，单独计分",
+                    "type": "integer"
                 }
             }
         },
         "model_question.JudgeConfig": {
             "type": "object",
             "properties": {
+                "compare_mode": {
+                    "description": "输出比较方式 exact、line、whitespace、float 或 checker，为空时按 exact 完全一致比较，与旧题目一致",
+                    "type": "string"
+                },
+                "epsilon": {
+                    "description": "float 方式允许的绝对或相对误差，为空时使用 1e-6",
+                    "type": "number"
+                },
+                "input_mode": {
+                    "description": "用例输入方式，stdin 或 args，为空时按 args 处理以兼容旧题目",
+                    "type": "string"
+                },
                 "memory_limit": {
+                    "description": "单位为kb，作为容器的内存上限",
+                    "type": "integer"
+                },
+                "mode": {
+                    "description": "判题方式，standard 或 interactive，为空时使用 standard",
+                    "type": "string"
+                },
+                "output_limit": {
+                    "description": "单位为kb，标准输出超过该大小的程序会被强制结束",
                     "type": "integer"
                 },
+                "stop_on_failure": {
+                    "description": "遇到第一个未通过的用例后不再运行之后的用例",
+                    "type": "boolean"
+                },
+                "subtask_rule": {
+                    "description": "子任务的计分方式，sum 或 min，为空时使用 sum",
+                    "type": "string"
+                },
                 "time_limit": {
+                    "description": "单位为ms，超时的程序会被强制结束",
                     "type": "integer"
                 }
             }
@@ -1124,6 +1862,10 @@ const docTemplate = `{
         "model_question.JudgeInfo": {
             "type": "object",
             "properties": {
+                "detail": {
+                    "description": "编译错误、运行错误时的标准错误等详细信息",
+                    "type": "string"
+                },
                 "memory": {
                     "description": "单位为kb",
                     "type": "integer"
@@ -1138,6 +1880,39 @@ const docTemplate = `{
                 }
             }
         },
+        "model_question.JudgeSummary": {
+            "type": "object",
+            "properties": {
+                "full_score": {
+                    "description": "题目的满分，题目不计分时为 0",
+                    "type": "integer"
+                },
+                "memory": {
+                    "description": "各用例内存的最大值，单位为kb",
+                    "type": "integer"
+                },
+                "passed": {
+                    "description": "通过的用例数",
+                    "type": "integer"
+                },
+                "score": {
+                    "description": "得分，未运行的用例不得分",
+                    "type": "integer"
+                },
+                "time": {
+                    "description": "各用例运行时间的最大值，单位为ms",
+                    "type": "integer"
+                },
+                "total": {
+                    "description": "题目的用例数，提前停止时包括未运行的用例",
+                    "type": "integer"
+                },
+                "verdict": {
+                    "description": "所有用例通过时为 Accepted，否则为第一个未通过用例的结果，任一用例出现 System Error 时为 System Error",
+                    "type": "string"
+                }
+            }
+        },
         "model_question.QueryQuestionRequest": {
             "type": "object",
             "properties": {
@@ -1209,6 +1984,10 @@ const docTemplate = `{
                         "type": "string"
                     }
                 },
+                "full_score": {
+                    "description": "题目的满分，题目不计分时为 0",
+                    "type": "integer"
+                },
                 "judge_info": {
                     "description": "\"判题信息json对象(包含上面的枚举值)",
                     "type": "array",
@@ -1220,13 +1999,37 @@ const docTemplate = `{
                     "description": "\"编程语言\"",
                     "type": "string"
                 },
+                "memory": {
+                    "description": "各用例内存的最大值，单位为kb",
+                    "type": "integer"
+                },
+                "passed": {
+                    "description": "通过的用例数",
+                    "type": "integer"
+                },
                 "question_id": {
                     "description": "题目id",
                     "type": "string"
                 },
+                "score": {
+                    "description": "得分，未运行的用例不得分",
+                    "type": "integer"
+                },
                 "status": {
                     "description": "\"判题状态（0-待判题,1-判题中,2-成功,3-失败)\",",
                     "type": "integer"
+                },
+                "time": {
+                    "description": "各用例运行时间的最大值，单位为ms",
+                    "type": "integer"
+                },
+                "total": {
+                    "description": "题目的用例数，提前停止时包括未运行的用例",
+                    "type": "integer"
+                },
+                "verdict": {
+                    "description": "所有用例通过时为 Accepted，否则为第一个未通过用例的结果，任一用例出现 System Error 时为 System Error",
+                    "type": "string"
                 }
             }
         },
@@ -1237,6 +2040,10 @@ const docTemplate = `{
                     "description": "\"题目通过数\"",
                     "type": "integer"
                 },
+                "accept_user_num": {
+                    "description": "\"题目通过人数\"",
+                    "type": "integer"
+                },
                 "answer": {
                     "description": "\"题目答案\"",
                     "type": "array",
@@ -1291,6 +2098,14 @@ const docTemplate = `{
                         "type": "string"
                     }
                 },
+                "checker": {
+                    "description": "\"判题程序，为空时不修改\"",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/model_question.Checker"
+                        }
+                    ]
+                },
                 "content": {
                     "description": "\"内容\"",
                     "type": "string"
@@ -1298,6 +2113,14 @@ const docTemplate = `{
                 "id": {
                     "type": "string"
                 },
+                "interactor": {
+                    "description": "\"交互程序，为空时不修改\"",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/model_question.Checker"
+                        }
+                    ]
+                },
                 "judge_case": {
                     "description": "\"判题用例json数组\"",
                     "type": "array",
@@ -1383,6 +2206,28 @@ const docTemplate = `{
                 }
             }
         },
+        "model_user.AssignRoleRequest": {
+            "type": "object",
+            "properties": {
+                "role": {
+                    "type": "string"
+                },
+                "user_account": {
+                    "type": "string"
+                }
+            }
+        },
+        "model_user.BanUserRequest": {
+            "type": "object",
+            "properties": {
+                "ban": {
+                    "type": "boolean"
+                },
+                "user_account": {
+                    "type": "string"
+                }
+            }
+        },
         "model_user.EditUserRequest": {
             "type": "object",
             "properties": {
@@ -1406,6 +2251,10 @@ const docTemplate = `{
         "model_user.LoginUserRequest": {
             "type": "object",
             "properties": {
+                "issue_token": {
+                    "description": "为 true 时签发访问令牌和刷新令牌，不创建session，用于非浏览器客户端",
+                    "type": "boolean"
+                },
                 "user_account": {
                     "type": "string"
                 },
@@ -1414,6 +2263,14 @@ const docTemplate = `{
                 }
             }
         },
+        "model_user.RefreshTokenRequest": {
+            "type": "object",
+            "properties": {
+                "refresh_token": {
+                    "type": "string"
+                }
+            }
+        },
         "model_user.ReturnAdminUser": {
             "type": "object",
             "properties": {
@@ -1457,6 +2314,54 @@ const docTemplate = `{
                 }
             }
         },
+        "model_user.RevokeTokenRequest": {
+            "type": "object",
+            "properties": {
+                "token": {
+                    "type": "string"
+                }
+            }
+        },
+        "model_user.RoleInfo": {
+            "type": "object",
+            "properties": {
+                "description": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "permissions": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "model_user.TokenPair": {
+            "type": "object",
+            "properties": {
+                "access_token": {
+                    "type": "string"
+                },
+                "expires_in": {
+                    "description": "访问令牌的有效期，单位为秒",
+                    "type": "integer"
+                },
+                "refresh_token": {
+                    "type": "string"
+                }
+            }
+        },
+        "model_user.UnlockUserRequest": {
+            "type": "object",
+            "properties": {
+                "user_account": {
+                    "type": "string"
+                }
+            }
+        },
         "model_user.UpdateUserRequest": {
             "type": "object",
             "properties": {
@@ -1495,6 +2400,58 @@ const docTemplate = `{
                     "type": "string"
                 }
             }
+        },
+        "model_user.UserRoles": {
+            "type": "object",
+            "properties": {
+                "permissions": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "roles": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "user_account": {
+                    "type": "string"
+                },
+                "user_role": {
+                    "type": "string"
+                }
+            }
+        },
+        "progress.Event": {
+            "type": "object",
+            "properties": {
+                "case": {
+                    "type": "integer"
+                },
+                "judge_info": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model_question.JudgeInfo"
+                    }
+                },
+                "stage": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "integer"
+                },
+                "submit_id": {
+                    "type": "string"
+                },
+                "summary": {
+                    "$ref": "#/definitions/model_question.JudgeSummary"
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
         }
     }
 }`
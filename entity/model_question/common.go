@@ -1,17 +1,46 @@
 package model_question
 
 type JudgeCase struct {
-	Input  string `json:"input"`
-	Output string `json:"output"`
+	Input   string `json:"input"`
+	Output  string `json:"output"`
+	Score   int    `json:"score"`   //用例的分数，所有用例都为 0 时不计分
+	Subtask int    `json:"subtask"` //所属的子任务，为 0 时不属于任何子任务，单独计分
 }
 
 type JudgeConfig struct {
-	TimeLimit   int64  `json:"time_limit"`
-	MemoryLimit uint64 `json:"memory_limit"`
+	TimeLimit     int64   `json:"time_limit"`      //单位为ms，超时的程序会被强制结束
+	MemoryLimit   uint64  `json:"memory_limit"`    //单位为kb，作为容器的内存上限
+	InputMode     string  `json:"input_mode"`      //用例输入方式，stdin 或 args，为空时按 args 处理以兼容旧题目
+	OutputLimit   uint64  `json:"output_limit"`    //单位为kb，标准输出超过该大小的程序会被强制结束
+	CompareMode   string  `json:"compare_mode"`    //输出比较方式 exact、line、whitespace、float 或 checker，为空时按 exact 完全一致比较，与旧题目一致
+	Epsilon       float64 `json:"epsilon"`         //float 方式允许的绝对或相对误差，为空时使用 1e-6
+	Mode          string  `json:"mode"`            //判题方式，standard 或 interactive，为空时使用 standard
+	StopOnFailure bool    `json:"stop_on_failure"` //遇到第一个未通过的用例后不再运行之后的用例
+	SubtaskRule   string  `json:"subtask_rule"`    //子任务的计分方式，sum 或 min，为空时使用 sum
+}
+
+// Checker 题目的判题程序，在沙箱中以 输入文件 用户输出文件 答案文件 三个参数运行
+// 退出码为 0 表示通过，1 表示答案错误，其他表示判题程序异常，标准输出作为给用户的提示
+// 交互题的交互程序使用同样的结构，以 输入文件 答案文件 两个参数运行，标准输入输出与用户程序相连，标准错误作为提示
+type Checker struct {
+	Language string `json:"language"`
+	Code     string `json:"code"`
 }
 
 type JudgeInfo struct {
-	Message string `json:"message"` //值为以上枚举值
-	Time    int64  `json:"time"`    //单位为ms
-	Memory  uint64 `json:"memory"`  //单位为kb
+	Message string `json:"message"`          //值为以上枚举值
+	Time    int64  `json:"time"`             //单位为ms
+	Memory  uint64 `json:"memory"`           //单位为kb
+	Detail  string `json:"detail,omitempty"` //编译错误、运行错误时的标准错误等详细信息
+}
+
+// JudgeSummary 提交的总体判题结果
+type JudgeSummary struct {
+	Verdict   string `json:"verdict" gorm:"column:verdict; type: varchar(64)"` //所有用例通过时为 Accepted，否则为第一个未通过用例的结果，任一用例出现 System Error 时为 System Error
+	Time      int64  `json:"time" gorm:"column:max_time"`                      //各用例运行时间的最大值，单位为ms
+	Memory    uint64 `json:"memory" gorm:"column:max_memory"`                  //各用例内存的最大值，单位为kb
+	Passed    int    `json:"passed" gorm:"column:passed_count"`                //通过的用例数
+	Total     int    `json:"total" gorm:"column:total_count"`                  //题目的用例数，提前停止时包括未运行的用例
+	Score     int    `json:"score" gorm:"column:score"`                        //得分，未运行的用例不得分
+	FullScore int    `json:"full_score" gorm:"column:full_score"`              //题目的满分，题目不计分时为 0
 }
@@ -21,10 +21,16 @@ type Question struct {
 	SubmitNum int `json:"submit_num" gorm:"column submit_num; type int; not null;default: 0"`
 	// "题目通过数"
 	AcceptNum int `json:"accept_num" gorm:"column accept_num; type int; not null;default: 0"`
+	// "题目通过人数，每个用户只统计第一次通过"
+	AcceptUserNum int `json:"accept_user_num" gorm:"column accept_user_num; type int; not null;default: 0"`
 	// "判题用例json数组"
 	JudgeCase string `json:"judge_case" gorm:"column judge_case; type text"`
 	// "判题配置json对象"
 	JudgeConfig string `json:"judge_config" gorm:"column judge_config; type text"`
+	// "判题程序json对象，不返回给用户"
+	Checker string `json:"-" gorm:"column checker; type mediumtext"`
+	// "交互程序json对象，不返回给用户"
+	Interactor string `json:"-" gorm:"column interactor; type mediumtext"`
 	// "点赞数"
 	ThumNum int `json:"thum_num" gorm:"column thum_num; type int; not null;default: 0"`
 	// "创建用户id"
@@ -54,13 +60,17 @@ type AddQuestionRequest struct {
 	JudgeCase []JudgeCase `json:"judge_case" `
 	// "判题配置json对象"
 	JudgeConfig JudgeConfig `json:"judge_config" `
+	// "判题程序，compare_mode 为 checker 时必填"
+	Checker *Checker `json:"checker"`
+	// "交互程序，mode 为 interactive 时必填"
+	Interactor *Checker `json:"interactor"`
 	// "创建用户id"
 	UserId string `json:"user_id"`
 }
 
 func AddQuestionToQuestion(addQuestion AddQuestionRequest) Question {
 	var question Question
-	var judgeCase, judgeConfig, answer string
+	var judgeCase, judgeConfig, answer, checker, interactor string
 	var errs error
 	if addQuestion.JudgeCase != nil {
 		res, err := json.Marshal(addQuestion.JudgeCase)
@@ -77,6 +87,16 @@ func AddQuestionToQuestion(addQuestion AddQuestionRequest) Question {
 		errs = err
 		answer = string(res)
 	}
+	if addQuestion.Checker != nil {
+		res, err := json.Marshal(addQuestion.Checker)
+		errs = err
+		checker = string(res)
+	}
+	if addQuestion.Interactor != nil {
+		res, err := json.Marshal(addQuestion.Interactor)
+		errs = err
+		interactor = string(res)
+	}
 	if errs != nil {
 		return Question{}
 	}
@@ -85,11 +105,14 @@ func AddQuestionToQuestion(addQuestion AddQuestionRequest) Question {
 	question.Title = addQuestion.Title
 	question.Content = addQuestion.Content
 	question.AcceptNum = 0
+	question.AcceptUserNum = 0
 	question.SubmitNum = 0
 	question.Tag = addQuestion.Tag
 	question.Answer = answer
 	question.JudgeCase = judgeCase
 	question.JudgeConfig = judgeConfig
+	question.Checker = checker
+	question.Interactor = interactor
 	question.UserId = addQuestion.UserId
 	question.ThumNum = 0
 	question.CreateTime = time.Now()
@@ -113,6 +136,10 @@ type UpdateQuestionRequest struct {
 	JudgeCase []JudgeCase `json:"judge_case" `
 	// "判题配置json对象"
 	JudgeConfig JudgeConfig `json:"judge_config"`
+	// "判题程序，为空时不修改"
+	Checker *Checker `json:"checker"`
+	// "交互程序，为空时不修改"
+	Interactor *Checker `json:"interactor"`
 }
 
 func UpdateQuestionToQuestion(old Question, updateQuestion UpdateQuestionRequest) Question {
@@ -144,6 +171,16 @@ func UpdateQuestionToQuestion(old Question, updateQuestion UpdateQuestionRequest
 		errs = err
 		old.JudgeConfig = string(judgeConfig)
 	}
+	if updateQuestion.Checker != nil {
+		checker, err := json.Marshal(updateQuestion.Checker)
+		errs = err
+		old.Checker = string(checker)
+	}
+	if updateQuestion.Interactor != nil {
+		interactor, err := json.Marshal(updateQuestion.Interactor)
+		errs = err
+		old.Interactor = string(interactor)
+	}
 
 	if errs != nil {
 		return Question{}
@@ -217,6 +254,8 @@ type ReturnQuestion struct {
 	SubmitNum int `json:"submit_num" `
 	// "题目通过数"
 	AcceptNum int `json:"accept_num"`
+	// "题目通过人数"
+	AcceptUserNum int `json:"accept_user_num"`
 	// "判题配置json对象"
 	JudgeConfig JudgeConfig `json:"judge_config" `
 	// "点赞数"
@@ -242,16 +281,17 @@ func QuestionToReturnQuestion(question Question) ReturnQuestion {
 		return ReturnQuestion{}
 	}
 	return ReturnQuestion{
-		ID:          question.ID,
-		Title:       question.Title,
-		Content:     question.Content,
-		Answer:      answer,
-		Tag:         question.Tag,
-		SubmitNum:   question.SubmitNum,
-		AcceptNum:   question.AcceptNum,
-		JudgeConfig: judgeConfig,
-		ThumNum:     question.ThumNum,
-		UserId:      question.UserId,
+		ID:            question.ID,
+		Title:         question.Title,
+		Content:       question.Content,
+		Answer:        answer,
+		Tag:           question.Tag,
+		SubmitNum:     question.SubmitNum,
+		AcceptNum:     question.AcceptNum,
+		AcceptUserNum: question.AcceptUserNum,
+		JudgeConfig:   judgeConfig,
+		ThumNum:       question.ThumNum,
+		UserId:        question.UserId,
 	}
 }
 
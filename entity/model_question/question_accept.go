@@ -0,0 +1,17 @@
+package model_question
+
+import "time"
+
+// QuestionAccept 用户第一次通过题目的记录，用于统计题目的通过人数
+type QuestionAccept struct {
+	//"题目id"
+	QuestionId string `json:"question_id" gorm:"primaryKey; column:question_id; type: varchar(256)"`
+	//"通过的用户id"
+	UserId string `json:"user_id" gorm:"primaryKey; column:user_id; type: varchar(256)"`
+	//"第一次通过的时间"
+	CreateTime time.Time `json:"create_time" gorm:"column:create_time; type: datetime; not null"`
+}
+
+func (qa *QuestionAccept) TableName() string {
+	return "question_accept"
+}
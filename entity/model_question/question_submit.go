@@ -17,6 +17,8 @@ type QuestionSubmit struct {
 	JudgeInfo string `json:"judge_info" gorm:"column judge_info; type: text;"`
 	//"判题状态（0-待判题,1-判题中,2-成功,3-失败)",
 	Status int `json:"status" gorm:"column status; type: int; default: 0; not null"`
+	//总体判题结果
+	JudgeSummary `gorm:"embedded"`
 	//"判题id"
 	QuestionId string `json:"question_id" gorm:"index; column question_id; type: varchar(256); not null"`
 	//"创建用户id"
@@ -101,6 +103,8 @@ type UpdateQuestionSubmitRequest struct {
 	JudgeInfo []JudgeInfo `json:"judge_info"`
 	//"判题状态（0-待判题,1-判题中,2-成功,3-失败)",
 	Status int `json:"status"`
+	//总体判题结果
+	JudgeSummary
 }
 
 type CommonQuestionSubmitRequest struct {
@@ -109,6 +113,10 @@ type CommonQuestionSubmitRequest struct {
 	JudgeInfo string `json:"judge_info"`
 	//"判题状态（0-待判题,1-判题中,2-成功,3-失败)",
 	Status int `json:"status"`
+	//总体判题结果，与判题信息一起保存
+	JudgeSummary `gorm:"embedded"`
+	//"更新时间"，保存时自动设置
+	UpdateTime time.Time `json:"update_time"`
 }
 
 func UpdateQSToCommonQS(request UpdateQuestionSubmitRequest) CommonQuestionSubmitRequest {
@@ -125,10 +133,27 @@ func UpdateQSToCommonQS(request UpdateQuestionSubmitRequest) CommonQuestionSubmi
 	questionSubmit.ID = request.ID
 	questionSubmit.Status = request.Status
 	questionSubmit.JudgeInfo = judgeInfo
+	questionSubmit.JudgeSummary = request.JudgeSummary
 
 	return questionSubmit
 }
 
+// HideJudgeDetail 去掉判题信息中的编译错误等详细信息
+func HideJudgeDetail(judgeInfo string) string {
+	var infos []JudgeInfo
+	if judgeInfo == "" || json.Unmarshal([]byte(judgeInfo), &infos) != nil {
+		return judgeInfo
+	}
+	for i := range infos {
+		infos[i].Detail = ""
+	}
+	res, err := json.Marshal(infos)
+	if err != nil {
+		return judgeInfo
+	}
+	return string(res)
+}
+
 type ReturnQS struct {
 	//题目id
 	QuestionId string `json:"question_id"`
@@ -138,6 +163,8 @@ type ReturnQS struct {
 	JudgeInfo []JudgeInfo `json:"judge_info" `
 	//"判题状态（0-待判题,1-判题中,2-成功,3-失败)",
 	Status int `json:"status"`
+	//总体判题结果
+	JudgeSummary
 	//"答案"
 	Answer []string `json:"answer"`
 }
@@ -163,6 +190,7 @@ func QSToReturnQS(questionSubmit QuestionSubmit, Answer string) ReturnQS {
 	qsReturn.Language = questionSubmit.Language
 	qsReturn.Status = questionSubmit.Status
 	qsReturn.JudgeInfo = judgeInfo
+	qsReturn.JudgeSummary = questionSubmit.JudgeSummary
 	qsReturn.Answer = answer
 
 	return qsReturn
@@ -1,7 +1,6 @@
 package model_user
 
 import (
-	"github.com/xissg/userManageSystem/utils"
 	"time"
 )
 
@@ -14,6 +13,7 @@ type EditUserRequest struct {
 	UserRole     string `json:"user_role"`
 }
 
+// EditUserToUser 密码为明文，保存前需要替换为哈希
 func EditUserToUser(oldInfo User, editUser EditUserRequest) User {
 	if editUser.UserName != "" {
 		oldInfo.UserName = editUser.UserName
@@ -25,7 +25,7 @@ func EditUserToUser(oldInfo User, editUser EditUserRequest) User {
 		oldInfo.UserRole = editUser.UserRole
 	}
 	if editUser.UserPassword != "" {
-		oldInfo.UserPassword = utils.MD5Crypt(editUser.UserPassword)
+		oldInfo.UserPassword = editUser.UserPassword
 	}
 
 	return oldInfo
@@ -89,3 +89,8 @@ func UsersToAdminReturnUsers(users []User) []ReturnAdminUser {
 	}
 	return adminReturnUsers
 }
+
+// UnlockUserRequest 解除登录失败导致的账号锁定
+type UnlockUserRequest struct {
+	UserAccount string `json:"user_account"`
+}
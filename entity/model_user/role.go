@@ -0,0 +1,70 @@
+package model_user
+
+import "time"
+
+// Role 角色，用户的 user_role 字段为基础角色，还可以通过 RoleAssignment 分配多个附加角色
+type Role struct {
+	//"角色名"
+	Name string `json:"name" gorm:"primaryKey; column:name; type: varchar(64)"`
+	//"描述"
+	Description string `json:"description" gorm:"column:description; type: varchar(256)"`
+	//"创建时间"
+	CreateTime time.Time `json:"create_time" gorm:"column:create_time; type: datetime; not null"`
+}
+
+func (r *Role) TableName() string {
+	return "role"
+}
+
+// RolePermission 角色拥有的权限
+type RolePermission struct {
+	//"角色名"
+	RoleName string `json:"role_name" gorm:"primaryKey; column:role_name; type: varchar(64)"`
+	//"权限，如 question:write"
+	Permission string `json:"permission" gorm:"primaryKey; column:permission; type: varchar(64)"`
+}
+
+func (rp *RolePermission) TableName() string {
+	return "role_permission"
+}
+
+// RoleAssignment 分配给用户的附加角色
+type RoleAssignment struct {
+	//"用户id"
+	UserId string `json:"user_id" gorm:"primaryKey; column:user_id; type: varchar(256)"`
+	//"角色名"
+	RoleName string `json:"role_name" gorm:"primaryKey; column:role_name; type: varchar(64)"`
+	//"分配时间"
+	CreateTime time.Time `json:"create_time" gorm:"column:create_time; type: datetime; not null"`
+}
+
+func (ra *RoleAssignment) TableName() string {
+	return "user_role"
+}
+
+// RoleInfo 角色及其权限
+type RoleInfo struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+// UserRoles 用户的基础角色、附加角色和由此得到的权限
+type UserRoles struct {
+	UserAccount string   `json:"user_account"`
+	UserRole    string   `json:"user_role"`
+	Roles       []string `json:"roles"`
+	Permissions []string `json:"permissions"`
+}
+
+// AssignRoleRequest 为用户分配或撤销附加角色
+type AssignRoleRequest struct {
+	UserAccount string `json:"user_account"`
+	Role        string `json:"role"`
+}
+
+// BanUserRequest 禁用或解禁用户，解禁后恢复为普通用户
+type BanUserRequest struct {
+	UserAccount string `json:"user_account"`
+	Ban         bool   `json:"ban"`
+}
@@ -0,0 +1,25 @@
+package model_user
+
+// TokenPair 登录或刷新时签发的访问令牌和刷新令牌
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	//访问令牌的有效期，单位为秒
+	ExpiresIn int64 `json:"expires_in"`
+}
+
+// TokenLoginResponse 登录时请求签发令牌的返回结果
+type TokenLoginResponse struct {
+	User *ReturnUser `json:"user"`
+	TokenPair
+}
+
+// RefreshTokenRequest 使用刷新令牌换取新的令牌
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// RevokeTokenRequest 撤销访问令牌或刷新令牌
+type RevokeTokenRequest struct {
+	Token string `json:"token"`
+}
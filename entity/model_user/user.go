@@ -39,13 +39,13 @@ type AddUserRequest struct {
 	UserPassword string `json:"user_password" validate:"required,min=7,max=32"`
 }
 
-// AddUserToUser 为接收的用户补充字段
-func AddUserToUser(addUser AddUserRequest) (user User) {
+// AddUserToUser 为接收的用户补充字段，passwordHash 为 utils.HashPassword 生成的密码哈希
+func AddUserToUser(addUser AddUserRequest, passwordHash string) (user User) {
 	user.ID = utils.NewUuid()
 	user.UserName = addUser.UserName
 	user.UserAccount = addUser.UserAccount
 	user.AvatarUrl = addUser.AvatarUrl
-	user.UserPassword = utils.MD5Crypt(addUser.UserPassword)
+	user.UserPassword = passwordHash
 	user.CreateTime = time.Now().UTC()
 	user.UpdateTime = time.Now().UTC()
 	user.UserRole = constant.Common
@@ -57,12 +57,8 @@ func AddUserToUser(addUser AddUserRequest) (user User) {
 type LoginUserRequest struct {
 	UserAccount  string `json:"user_account"`
 	UserPassword string `json:"user_password"`
-}
-
-func LoginUserToUser(loginUser LoginUserRequest) (user User) {
-	user.UserAccount = loginUser.UserAccount
-	user.UserPassword = utils.MD5Crypt(loginUser.UserPassword)
-	return user
+	//为 true 时签发访问令牌和刷新令牌，不创建session，用于非浏览器客户端
+	IssueToken bool `json:"issue_token"`
 }
 
 // 用户更新信息的请求
@@ -72,6 +68,7 @@ type UpdateUserRequest struct {
 	UserPassword string `json:"user_password"`
 }
 
+// UpdateUserToUser 密码为明文，保存前需要替换为哈希
 func UpdateUserToUser(oldInfo User, updateUser UpdateUserRequest) User {
 	if updateUser.UserName != "" {
 		oldInfo.UserName = updateUser.UserName
@@ -80,7 +77,7 @@ func UpdateUserToUser(oldInfo User, updateUser UpdateUserRequest) User {
 		oldInfo.AvatarUrl = updateUser.AvatarUrl
 	}
 	if updateUser.UserPassword != "" {
-		oldInfo.UserPassword = utils.MD5Crypt(updateUser.UserPassword)
+		oldInfo.UserPassword = updateUser.UserPassword
 	}
 
 	return oldInfo
@@ -0,0 +1,7 @@
+create table if not exists question_accept
+(
+    question_id varchar(256)                       not null comment "题目id",
+    user_id     varchar(256)                       not null comment "通过的用户id",
+    create_time datetime default CURRENT_TIMESTAMP not null comment "第一次通过的时间",
+    primary key (question_id, user_id)
+) comment "用户第一次通过题目的记录" collate = utf8mb4_unicode_ci;
\ No newline at end of file
@@ -1,15 +1,22 @@
 create table if not exists question_submit
 (
-    id          varchar(256)                                                   comment "id" primary key,
-    language    varchar(128)                                                   not null comment "编程语言",
-    code        text                                                           not null comment "用户代码",
-    judge_info  text                                                           null comment "判题信息json对象",
-    status      int      default 0                                             not null comment "判题状态（0-待判题,1-判题中,2-成功,3-失败)",
-    question_id varchar(256)                                                   not null comment "判题id",
-    user_id     varchar(256)                                                   not null comment "创建用户id",
-    create_time datetime default CURRENT_TIMESTAMP                             not null comment "创建时间",
-    update_time datetime default CURRENT_TIMESTAMP on update CURRENT_TIMESTAMP not null comment "更新时间",
-    is_delete   tinyint  default 0                                             not null comment "是否删除",
+    id           varchar(256)                                                   comment "id" primary key,
+    language     varchar(128)                                                   not null comment "编程语言",
+    code         text                                                           not null comment "用户代码",
+    judge_info   text                                                           null comment "判题信息json对象",
+    status       int      default 0                                             not null comment "判题状态（0-待判题,1-判题中,2-成功,3-失败)",
+    verdict      varchar(64)                                                    null comment "总体判题结果",
+    max_time     bigint   default 0                                             not null comment "各用例运行时间的最大值，单位为ms",
+    max_memory   bigint unsigned default 0                                      not null comment "各用例内存的最大值，单位为kb",
+    passed_count bigint   default 0                                             not null comment "通过的用例数",
+    total_count  bigint   default 0                                             not null comment "题目的用例数",
+    score        bigint   default 0                                             not null comment "得分",
+    full_score   bigint   default 0                                             not null comment "题目的满分，题目不计分时为 0",
+    question_id  varchar(256)                                                   not null comment "判题id",
+    user_id      varchar(256)                                                   not null comment "创建用户id",
+    create_time  datetime default CURRENT_TIMESTAMP                             not null comment "创建时间",
+    update_time  datetime default CURRENT_TIMESTAMP on update CURRENT_TIMESTAMP not null comment "更新时间",
+    is_delete    tinyint  default 0                                             not null comment "是否删除",
     index idx_question_id (question_id),
     index idx_user_id (user_id)
 ) comment "题目提交";
\ No newline at end of file
@@ -0,0 +1,21 @@
+create table if not exists role
+(
+    name        varchar(64)                        comment "角色名" primary key,
+    description varchar(256)                       null comment "描述",
+    create_time datetime default CURRENT_TIMESTAMP not null comment "创建时间"
+) comment "角色" collate = utf8mb4_unicode_ci;
+
+create table if not exists role_permission
+(
+    role_name  varchar(64) not null comment "角色名",
+    permission varchar(64) not null comment "权限，如 question:write",
+    primary key (role_name, permission)
+) comment "角色拥有的权限" collate = utf8mb4_unicode_ci;
+
+create table if not exists user_role
+(
+    user_id     varchar(256)                       not null comment "用户id",
+    role_name   varchar(64)                        not null comment "角色名",
+    create_time datetime default CURRENT_TIMESTAMP not null comment "分配时间",
+    primary key (user_id, role_name)
+) comment "分配给用户的附加角色" collate = utf8mb4_unicode_ci;
\ No newline at end of file
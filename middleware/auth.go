@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/xissg/userManageSystem/common/api_response"
+	"github.com/xissg/userManageSystem/common/constant"
+	"github.com/xissg/userManageSystem/entity/model_user"
+	"github.com/xissg/userManageSystem/service/rbac"
+	"github.com/xissg/userManageSystem/service/redis"
+	"github.com/xissg/userManageSystem/service/token"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// BearerToken 返回 Authorization 请求头中的 Bearer 令牌，没有时返回空字符串
+func BearerToken(c *gin.Context) string {
+	header := c.GetHeader("Authorization")
+	if len(header) > 7 && strings.EqualFold(header[:7], "Bearer ") {
+		return strings.TrimSpace(header[7:])
+	}
+	return ""
+}
+
+// Authenticate 解析当前请求的用户并保存到 gin.Context，之后的中间件和控制器通过 CurrentUser 获取
+// 请求带有 Bearer 令牌时校验访问令牌，令牌无效、过期或已撤销时返回 401，客户端应使用刷新令牌换取新的令牌
+// 没有令牌的请求使用session cookie中的用户信息，都没有时为匿名用户，由路由上的 RequireLogin 等决定是否放行
+func Authenticate(tokens *token.Service, sessions *redis.SessionService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		bearer := BearerToken(c)
+		if bearer == "" {
+			if user, err := sessions.GetSession(c); err == nil {
+				c.Set(constant.ContextUser, user)
+			}
+			c.Next()
+			return
+		}
+		user, err := tokens.Verify(c.Request.Context(), bearer)
+		if err != nil {
+			log.Printf("verify token %v", err)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, api_response.NewResponse(nil, "invalid or expired token").Response(api_response.AUTHERR))
+			return
+		}
+		c.Set(constant.ContextUser, user)
+		c.Next()
+	}
+}
+
+// CurrentUser 返回 Authenticate 解析出的用户，未登录时返回角色为 constant.Anonymous 的空用户
+func CurrentUser(c *gin.Context) model_user.UserSession {
+	if user, ok := c.Get(constant.ContextUser); ok {
+		return user.(model_user.UserSession)
+	}
+	return model_user.UserSession{}
+}
+
+// RequireLogin 只允许已登录且未被禁用的用户访问
+func RequireLogin(c *gin.Context) {
+	switch CurrentUser(c).UserRole {
+	case constant.Anonymous:
+		log.Printf("you must login first")
+		c.AbortWithStatusJSON(http.StatusBadRequest, api_response.NewResponse(nil, "you must login first").Response(api_response.AUTHERR))
+	case constant.Ban:
+		log.Printf("the user has been banned")
+		c.AbortWithStatusJSON(http.StatusBadRequest, api_response.NewResponse(nil, "the user has been banned").Response(api_response.AUTHERR))
+	default:
+		c.Next()
+	}
+}
+
+// RequirePermission 只允许拥有 permission 权限的用户访问，未登录的用户按 RequireLogin 处理
+func RequirePermission(authz *rbac.Authorizer, permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := CurrentUser(c)
+		if user.UserRole == constant.Anonymous || user.UserRole == constant.Ban {
+			RequireLogin(c)
+			return
+		}
+		if !authz.Can(user, permission) {
+			log.Printf("user %s lacks permission %s", user.UserAccount, permission)
+			c.AbortWithStatusJSON(http.StatusBadRequest, api_response.NewResponse(nil, "permission denied").Response(api_response.AUTHERR))
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/xissg/userManageSystem/common/constant"
+	"github.com/xissg/userManageSystem/entity/model_user"
+	"github.com/xissg/userManageSystem/service/rbac"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type roleStore struct{}
+
+func (roleStore) RolePermissions() (map[string][]string, error) {
+	return map[string][]string{constant.Admin: {constant.PermUserEdit}}, nil
+}
+
+func (roleStore) UserRoles(userId string) ([]string, error) {
+	return nil, nil
+}
+
+func TestRequirePermission(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	authz := rbac.NewAuthorizer(roleStore{})
+	if err := authz.Load(); err != nil {
+		t.Fatal(err)
+	}
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		if role := c.GetHeader("X-Role"); role != "" {
+			c.Set(constant.ContextUser, model_user.UserSession{ID: "1", UserRole: role})
+		}
+	})
+	login := r.Group("", RequireLogin)
+	login.GET("/user", func(c *gin.Context) { c.String(http.StatusOK, CurrentUser(c).ID) })
+	login.GET("/admin", RequirePermission(authz, constant.PermUserEdit), func(c *gin.Context) { c.String(http.StatusOK, "admin") })
+
+	cases := []struct {
+		path, role string
+		allowed    bool
+	}{
+		{"/user", constant.Anonymous, false},
+		{"/user", constant.Ban, false},
+		{"/user", constant.Common, true},
+		{"/user", constant.Admin, true},
+		{"/admin", constant.Anonymous, false},
+		{"/admin", constant.Common, false},
+		{"/admin", constant.Admin, true},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+		req.Header.Set("X-Role", tc.role)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if allowed := w.Code == http.StatusOK; allowed != tc.allowed {
+			t.Errorf("%s as %q: status %d, body %s", tc.path, tc.role, w.Code, w.Body)
+		}
+	}
+}
@@ -1,17 +1,25 @@
 package router
 
 import (
+	"context"
 	"encoding/gob"
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
+	"github.com/xissg/userManageSystem/common/constant"
 	"github.com/xissg/userManageSystem/controller"
+	"github.com/xissg/userManageSystem/core/judge"
 	_ "github.com/xissg/userManageSystem/docs"
 	"github.com/xissg/userManageSystem/entity/model_user"
 	"github.com/xissg/userManageSystem/middleware"
+	"github.com/xissg/userManageSystem/service/lockout"
 	mysql2 "github.com/xissg/userManageSystem/service/mysql"
+	"github.com/xissg/userManageSystem/service/rabbitmq"
+	"github.com/xissg/userManageSystem/service/rbac"
 	redis2 "github.com/xissg/userManageSystem/service/redis"
+	"github.com/xissg/userManageSystem/service/token"
+	"log"
 )
 
 // NewServer 开启服务器
@@ -20,8 +28,12 @@ func NewServer() {
 	gob.Register(model_user.User{})
 	gob.Register(model_user.UserSession{})
 
-	// 初始化gin引擎
-	r := gin.New()
+	// 初始化gin引擎，登录失败按客户端IP锁定，只信任配置的反向代理传来的 X-Forwarded-For
+	lockoutConfig := lockout.LoadConfig()
+	r, err := newEngine(lockoutConfig.TrustedProxies)
+	if err != nil {
+		panic(err)
+	}
 	//cors中间件
 	r.Use(middleware.CORS)
 
@@ -32,18 +44,67 @@ func NewServer() {
 	//注入依赖
 	sessionService := redis2.NewSessionService()
 	mysqlService := mysql2.NewUserService()
-	userController := controller.NewUserController(*mysqlService, *sessionService)
+
+	//非浏览器客户端可以使用 Bearer 令牌代替session cookie
+	tokenConfig, err := token.LoadConfig()
+	if err != nil {
+		panic(err)
+	}
+	tokenService := token.NewService(tokenConfig, redis2.NewTokenDenylist())
+
+	//角色和权限，启动时写入内置角色并缓存角色拥有的权限
+	roleService := mysql2.NewRoleService()
+	if err = roleService.SeedRoles(rbac.DefaultRoles); err != nil {
+		panic(err)
+	}
+	authz := rbac.NewAuthorizer(roleService)
+	if err = authz.Load(); err != nil {
+		panic(err)
+	}
+	//连续登录失败的账号和IP锁定一段时间
+	loginGuard := lockout.NewGuard(lockoutConfig, redis2.NewLoginCounter())
+	userController := controller.NewUserController(*mysqlService, *sessionService, tokenService, authz, loginGuard)
+	roleController := controller.NewRoleController(roleService, mysqlService, authz)
+
+	//每个请求解析一次当前用户，路由分组上的 RequireLogin、RequirePermission 据此检查权限
+	r.Use(middleware.Authenticate(tokenService, sessionService))
 
 	//题目相关依赖
 	questionMysqlService := mysql2.NewQuestionMysqlService()
-	questionController := controller.NewQuestionController(questionMysqlService, sessionService)
+	questionController := controller.NewQuestionController(questionMysqlService, authz)
+
+	//判题队列，提交后由判题进程异步判题
+	judgeQueue, err := rabbitmq.NewJudgeQueue()
+	if err != nil {
+		panic(err)
+	}
+
+	//判题进度通过redis分发，判题进程单独部署时也能推送给客户端
+	progressBroker := redis2.NewProgressBroker()
 
 	//题目提交相关依赖
 	qsMysqlService := mysql2.NewQuestionSubmitMysqlService()
 	qsService := mysql2.NewQuestionMysqlService()
-	qsController := controller.NewQuestionSubmitController(qsMysqlService, qsService, sessionService)
+	qsController := controller.NewQuestionSubmitController(qsMysqlService, qsService, judgeQueue, progressBroker, authz)
+
+	//未单独部署 judge-worker 时在服务进程内消费判题队列
+	if rabbitmq.EmbeddedWorker() {
+		if config := judge.LoadReaperConfig(); config.Enabled {
+			go judge.NewReaper(qsMysqlService, judgeQueue, config).Run(context.Background())
+		}
+		judgeService := judge.NewJudgeService(qsService, qsMysqlService, progressBroker)
+		go func() {
+			err := judge.NewWorker(judgeQueue, judgeService, rabbitmq.Prefetch(), rabbitmq.LoadRetryPolicy()).Run(context.Background())
+			if err != nil {
+				log.Printf("judge worker stopped: %v", err)
+			}
+		}()
+	}
 
-	//映射路由
+	//映射路由，后台操作按权限检查
+	can := func(permission string) gin.HandlerFunc {
+		return middleware.RequirePermission(authz, permission)
+	}
 	v1 := r.Group("api")
 	{
 		userGroup := v1.Group("user")
@@ -52,29 +113,50 @@ func NewServer() {
 			userGroup.GET("/logout", userController.Logout)
 
 			userGroup.POST("/register", userController.Register)
-			userGroup.POST("/query", userController.GetUserList)
-			userGroup.POST("/update", userController.UpdateUser)
+			userGroup.POST("/token/refresh", userController.RefreshToken)
+			userGroup.POST("/token/revoke", userController.RevokeToken)
+
+			loginGroup := userGroup.Group("", middleware.RequireLogin)
+			loginGroup.POST("/query", userController.GetUserList)
+			loginGroup.POST("/update", userController.UpdateUser)
 
 			//后台操作
-			userGroup.POST("/admin/query", userController.AdminGetUserList)
-			userGroup.POST("/admin/update", userController.EditUser)
-			userGroup.GET("/admin/delete/:account", userController.DeleteUser)
+			userGroup.POST("/admin/query", can(constant.PermUserView), userController.AdminGetUserList)
+			userGroup.POST("/admin/update", can(constant.PermUserEdit), userController.EditUser)
+			userGroup.GET("/admin/delete/:account", can(constant.PermUserEdit), userController.DeleteUser)
+			userGroup.POST("/admin/ban", can(constant.PermUserBan), userController.BanUser)
+			userGroup.POST("/admin/unlock", can(constant.PermUserBan), userController.UnlockUser)
 		}
 		questionGroup := v1.Group("question")
 		{
-			questionGroup.GET("/query/:id", questionController.GetQuestion)
-			questionGroup.POST("/query", questionController.GetQuestionList)
+			questionGroup.GET("/query/:id", can(constant.PermQuestionView), questionController.GetQuestion)
+			questionGroup.POST("/query", can(constant.PermQuestionView), questionController.GetQuestionList)
 
-			questionGroup.POST("/admin/add", questionController.AddQuestion)
-			questionGroup.GET("/admin/delete/:id", questionController.DeleteQuestion)
-			questionGroup.POST("/admin/update", questionController.UpdateQuestion)
+			adminGroup := questionGroup.Group("/admin", can(constant.PermQuestionWrite))
+			adminGroup.POST("/add", questionController.AddQuestion)
+			adminGroup.GET("/delete/:id", questionController.DeleteQuestion)
+			adminGroup.POST("/update", questionController.UpdateQuestion)
 		}
 
-		questionSubmitGroup := v1.Group("submit")
+		questionSubmitGroup := v1.Group("submit", middleware.RequireLogin)
 		{
-			questionSubmitGroup.POST("/add", qsController.Submit)
+			questionSubmitGroup.POST("/add", can(constant.PermSubmitCreate), qsController.Submit)
 			questionSubmitGroup.GET("/query/:id", qsController.GetQuestionSubmit)
-			questionSubmitGroup.POST("/query", qsController.GetQuestionSubmitList)
+			questionSubmitGroup.GET("/progress/:id", qsController.SubmitProgress)
+			questionSubmitGroup.POST("/query", can(constant.PermSubmitViewAll), qsController.GetQuestionSubmitList)
+
+			//后台操作
+			adminGroup := questionSubmitGroup.Group("/admin", can(constant.PermSubmitRequeue))
+			adminGroup.GET("/dead", qsController.GetDeadSubmits)
+			adminGroup.POST("/requeue/:id", qsController.RequeueSubmit)
+		}
+
+		roleGroup := v1.Group("role", can(constant.PermRoleManage))
+		{
+			roleGroup.GET("/list", roleController.ListRoles)
+			roleGroup.GET("/user/:account", roleController.GetUserRoles)
+			roleGroup.POST("/assign", roleController.AssignRole)
+			roleGroup.POST("/revoke", roleController.RevokeRole)
 		}
 	}
 
@@ -84,3 +166,15 @@ func NewServer() {
 	//开启服务器
 	r.Run(":8082")
 }
+
+// newEngine 创建gin引擎，trustedProxies 为空时不信任任何代理，ClientIP 返回连接的地址
+func newEngine(trustedProxies []string) (*gin.Engine, error) {
+	r := gin.New()
+	if len(trustedProxies) == 0 {
+		trustedProxies = nil
+	}
+	if err := r.SetTrustedProxies(trustedProxies); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
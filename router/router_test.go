@@ -0,0 +1,38 @@
+package router
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestClientIP 登录锁定使用 ClientIP 作为IP的键，客户端伪造的 X-Forwarded-For 不能改变它
+func TestClientIP(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		trustedProxies []string
+		want           string
+	}{
+		{nil, "10.0.0.1"},
+		{[]string{}, "10.0.0.1"},
+		{[]string{"10.0.0.0/8"}, "203.0.113.7"},
+	}
+	for _, tt := range tests {
+		r, err := newEngine(tt.trustedProxies)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.GET("/ip", func(c *gin.Context) {
+			c.String(http.StatusOK, c.ClientIP())
+		})
+		req := httptest.NewRequest(http.MethodGet, "/ip", nil)
+		req.RemoteAddr = "10.0.0.1:52000"
+		req.Header.Set("X-Forwarded-For", "203.0.113.7")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if got := w.Body.String(); got != tt.want {
+			t.Errorf("trusted proxies %v: client ip %q, want %q", tt.trustedProxies, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package lockout
+
+import (
+	"github.com/spf13/viper"
+	"log"
+	"time"
+)
+
+// Config 登录失败锁定的阈值和时长
+type Config struct {
+	AccountThreshold int           `mapstructure:"account_threshold"`
+	IPThreshold      int           `mapstructure:"ip_threshold"`
+	BaseLock         time.Duration `mapstructure:"base_lock"`
+	MaxLock          time.Duration `mapstructure:"max_lock"`
+	Window           time.Duration `mapstructure:"window"`
+	//反向代理的地址或网段，只有来自这些地址的请求才读取 X-Forwarded-For，为空时直接使用连接的地址
+	TrustedProxies []string `mapstructure:"trusted_proxies"`
+}
+
+var DefaultConfig = Config{
+	AccountThreshold: 5,
+	IPThreshold:      20,
+	BaseLock:         time.Minute,
+	MaxLock:          time.Hour,
+	Window:           15 * time.Minute,
+}
+
+// LoadConfig 读取 conf/lockout.yaml，读取失败或未配置的项使用默认值
+func LoadConfig() Config {
+	v := viper.New()
+	v.AddConfigPath("./conf")
+	v.SetConfigName("lockout")
+	v.SetConfigType("yaml")
+	config := DefaultConfig
+	if err := v.ReadInConfig(); err != nil {
+		log.Printf("read lockout config %v, use default config", err)
+		return DefaultConfig
+	}
+	if err := v.Unmarshal(&config); err != nil {
+		log.Printf("read lockout config %v, use default config", err)
+		return DefaultConfig
+	}
+	if config.AccountThreshold <= 0 {
+		config.AccountThreshold = DefaultConfig.AccountThreshold
+	}
+	if config.IPThreshold <= 0 {
+		config.IPThreshold = DefaultConfig.IPThreshold
+	}
+	if config.BaseLock <= 0 {
+		config.BaseLock = DefaultConfig.BaseLock
+	}
+	if config.MaxLock < config.BaseLock {
+		config.MaxLock = config.BaseLock
+	}
+	if config.Window <= 0 {
+		config.Window = DefaultConfig.Window
+	}
+	return config
+}
@@ -0,0 +1,96 @@
+package lockout
+
+import (
+	"context"
+	"time"
+)
+
+// Counter 失败次数和锁定状态的存储，redis.LoginCounter 实现该接口
+type Counter interface {
+	// Fail 记录一次失败并返回 window 内的失败次数，每次失败重新开始计算 window
+	Fail(ctx context.Context, key string, window time.Duration) (int, error)
+	// Lock 锁定 d，失败次数保留到锁定结束后再经过 window，使锁定期间之后的失败继续翻倍
+	Lock(ctx context.Context, key string, d, window time.Duration) error
+	// Locked 返回剩余的锁定时长，未锁定时为 0
+	Locked(ctx context.Context, key string) (time.Duration, error)
+	Reset(ctx context.Context, key string) error
+}
+
+// Guard 按账号和 IP 统计登录失败次数，达到阈值后锁定，之后每次失败锁定时长翻倍
+// 账号不存在时同样计数，锁定与否不会暴露账号是否存在
+type Guard struct {
+	config  Config
+	counter Counter
+}
+
+func NewGuard(config Config, counter Counter) *Guard {
+	return &Guard{config: config, counter: counter}
+}
+
+func accountKey(account string) string {
+	return "account:" + account
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+// Check 返回账号和 IP 中较长的剩余锁定时长，为 0 时可以尝试登录
+func (g *Guard) Check(ctx context.Context, account, ip string) (time.Duration, error) {
+	byAccount, err := g.counter.Locked(ctx, accountKey(account))
+	if err != nil {
+		return 0, err
+	}
+	byIP, err := g.counter.Locked(ctx, ipKey(ip))
+	if err != nil {
+		return 0, err
+	}
+	if byIP > byAccount {
+		return byIP, nil
+	}
+	return byAccount, nil
+}
+
+// Failure 记录一次登录失败，失败次数达到阈值时锁定账号或 IP
+func (g *Guard) Failure(ctx context.Context, account, ip string) error {
+	if err := g.fail(ctx, accountKey(account), g.config.AccountThreshold); err != nil {
+		return err
+	}
+	return g.fail(ctx, ipKey(ip), g.config.IPThreshold)
+}
+
+func (g *Guard) fail(ctx context.Context, key string, threshold int) error {
+	failures, err := g.counter.Fail(ctx, key, g.config.Window)
+	if err != nil {
+		return err
+	}
+	if failures < threshold {
+		return nil
+	}
+	return g.counter.Lock(ctx, key, lockDuration(failures-threshold, g.config.BaseLock, g.config.MaxLock), g.config.Window)
+}
+
+// Success 登录成功后清除账号的失败次数，IP 的失败次数不清除，避免用自己的账号登录来重置计数
+func (g *Guard) Success(ctx context.Context, account string) error {
+	return g.counter.Reset(ctx, accountKey(account))
+}
+
+// Unlock 解除账号的锁定并清除失败次数
+func (g *Guard) Unlock(ctx context.Context, account string) error {
+	return g.counter.Reset(ctx, accountKey(account))
+}
+
+// lockDuration 达到阈值后第 extra 次额外失败的锁定时长，为 base * 2^extra，不超过 max
+func lockDuration(extra int, base, max time.Duration) time.Duration {
+	d := base
+	for i := 0; i < extra; i++ {
+		d *= 2
+		if d >= max {
+			return max
+		}
+	}
+	if d > max {
+		return max
+	}
+	return d
+}
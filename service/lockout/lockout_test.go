@@ -0,0 +1,95 @@
+package lockout
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// fakeCounter 测试使用的计数，不处理过期
+type fakeCounter struct {
+	failures map[string]int
+	locks    map[string]time.Duration
+}
+
+func newFakeCounter() *fakeCounter {
+	return &fakeCounter{failures: make(map[string]int), locks: make(map[string]time.Duration)}
+}
+
+func (f *fakeCounter) Fail(ctx context.Context, key string, window time.Duration) (int, error) {
+	f.failures[key]++
+	return f.failures[key], nil
+}
+
+func (f *fakeCounter) Lock(ctx context.Context, key string, d, window time.Duration) error {
+	f.locks[key] = d
+	return nil
+}
+
+func (f *fakeCounter) Locked(ctx context.Context, key string) (time.Duration, error) {
+	return f.locks[key], nil
+}
+
+func (f *fakeCounter) Reset(ctx context.Context, key string) error {
+	delete(f.failures, key)
+	delete(f.locks, key)
+	return nil
+}
+
+func TestLockDuration(t *testing.T) {
+	cases := []struct {
+		extra int
+		want  time.Duration
+	}{
+		{0, time.Minute},
+		{1, 2 * time.Minute},
+		{3, 8 * time.Minute},
+		{6, time.Hour},
+		{100, time.Hour},
+	}
+	for _, tc := range cases {
+		if got := lockDuration(tc.extra, time.Minute, time.Hour); got != tc.want {
+			t.Errorf("lockDuration(%d) = %v, want %v", tc.extra, got, tc.want)
+		}
+	}
+}
+
+func TestGuard(t *testing.T) {
+	ctx := context.Background()
+	counter := newFakeCounter()
+	g := NewGuard(Config{AccountThreshold: 3, IPThreshold: 5, BaseLock: time.Minute, MaxLock: time.Hour, Window: time.Minute}, counter)
+
+	check := func(account, ip string, want time.Duration) {
+		t.Helper()
+		got, err := g.Check(ctx, account, ip)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("Check(%s, %s) = %v, want %v", account, ip, got, want)
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		_ = g.Failure(ctx, "alice", "1.1.1.1")
+	}
+	check("alice", "1.1.1.1", 0)
+	_ = g.Failure(ctx, "alice", "1.1.1.1")
+	check("alice", "2.2.2.2", time.Minute)
+	_ = g.Failure(ctx, "alice", "1.1.1.1")
+	check("alice", "2.2.2.2", 2*time.Minute)
+
+	//IP 的失败次数按所有账号累计，登录成功不清除
+	_ = g.Failure(ctx, "bob", "1.1.1.1")
+	check("bob", "1.1.1.1", time.Minute)
+	_ = g.Success(ctx, "bob")
+	check("bob", "1.1.1.1", time.Minute)
+	check("bob", "2.2.2.2", 0)
+
+	if err := g.Unlock(ctx, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	check("alice", "2.2.2.2", 0)
+	_ = g.Failure(ctx, "alice", "2.2.2.2")
+	check("alice", "2.2.2.2", 0)
+}
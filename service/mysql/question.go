@@ -4,6 +4,8 @@ import (
 	"github.com/xissg/userManageSystem/common/constant"
 	"github.com/xissg/userManageSystem/entity/model_question"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
 )
 
 type QuestionService struct {
@@ -52,7 +54,9 @@ func (qds *QuestionService) UpdateQuestion(q model_question.Question) error {
 	}
 
 	tx := qds.db.Begin()
-	res := tx.Table("question").Where("id = ? AND is_delete = ?", q.ID, constant.ALIVE).Updates(q)
+	//计数由提交和判题原子地更新，不使用读取时的旧值覆盖
+	res := tx.Table("question").Where("id = ? AND is_delete = ?", q.ID, constant.ALIVE).
+		Omit("submit_num", "accept_num", "accept_user_num").Updates(q)
 	if res.Error != nil {
 		tx.Rollback()
 
@@ -139,3 +143,79 @@ func (qds *QuestionService) GetQuestionList(questionList model_question.CommonQu
 
 	return res, nil
 }
+
+/**
+ * @Description: 题目提交数加一
+ * @param questionId string
+ * @return error
+ */
+func (qds *QuestionService) IncrSubmitNum(questionId string) error {
+	err := qds.db.AutoMigrate(&model_question.Question{})
+	if err != nil {
+		return err
+	}
+
+	return qds.db.Table("question").Where("id = ?", questionId).
+		UpdateColumn("submit_num", gorm.Expr("submit_num + ?", 1)).Error
+}
+
+/**
+ * @Description: 记录一次通过，题目通过数加一，用户第一次通过时通过人数也加一
+ * @param questionId string
+ * @param userId string
+ * @return error
+ */
+func (qds *QuestionService) RecordAccept(questionId, userId string) error {
+	err := qds.db.AutoMigrate(&model_question.Question{}, &model_question.QuestionAccept{})
+	if err != nil {
+		return err
+	}
+
+	return qds.db.Transaction(func(tx *gorm.DB) error {
+		//主键冲突说明用户之前已经通过
+		res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&model_question.QuestionAccept{
+			QuestionId: questionId,
+			UserId:     userId,
+			CreateTime: time.Now(),
+		})
+		if res.Error != nil {
+			return res.Error
+		}
+		counters := map[string]interface{}{"accept_num": gorm.Expr("accept_num + ?", 1)}
+		if res.RowsAffected == 1 {
+			counters["accept_user_num"] = gorm.Expr("accept_user_num + ?", 1)
+		}
+
+		return tx.Table("question").Where("id = ?", questionId).UpdateColumns(counters).Error
+	})
+}
+
+/**
+ * @Description: 根据未删除的提交重新计算所有题目的提交数、通过数和通过人数
+ * @return error
+ */
+func (qds *QuestionService) RecomputeCounters() error {
+	err := qds.db.AutoMigrate(&model_question.Question{}, &model_question.QuestionSubmit{}, &model_question.QuestionAccept{})
+	if err != nil {
+		return err
+	}
+
+	return qds.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Exec("DELETE FROM question_accept").Error
+		if err != nil {
+			return err
+		}
+		err = tx.Exec(`INSERT INTO question_accept (question_id, user_id, create_time)
+			SELECT question_id, user_id, MIN(create_time) FROM question_submit
+			WHERE status = ? AND is_delete = ? GROUP BY question_id, user_id`, constant.SUCCESS, constant.ALIVE).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Exec(`UPDATE question q SET
+			submit_num = (SELECT COUNT(*) FROM question_submit s WHERE s.question_id = q.id AND s.is_delete = ?),
+			accept_num = (SELECT COUNT(*) FROM question_submit s WHERE s.question_id = q.id AND s.is_delete = ? AND s.status = ?),
+			accept_user_num = (SELECT COUNT(*) FROM question_accept a WHERE a.question_id = q.id)`,
+			constant.ALIVE, constant.ALIVE, constant.SUCCESS).Error
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"github.com/xissg/userManageSystem/common/constant"
 	"github.com/xissg/userManageSystem/entity/model_question"
 	"gorm.io/gorm"
+	"time"
 )
 
 type QuestionSubmitService struct {
@@ -90,8 +91,10 @@ func (qsds *QuestionSubmitService) UpdateSubmitQuestion(request model_question.C
 	if err != nil {
 		return err
 	}
+	request.UpdateTime = time.Now()
 	tx := qsds.db.Begin()
-	err = tx.Table("question_submit").Where("id = ?", request.ID).Updates(request).Error
+	//判题结果的各字段一起保存，通过的用例数等为零时也需要覆盖之前的结果
+	err = tx.Table("question_submit").Where("id = ?", request.ID).Select("*").Updates(request).Error
 	if err != nil {
 		tx.Rollback()
 		return err
@@ -100,3 +103,111 @@ func (qsds *QuestionSubmitService) UpdateSubmitQuestion(request model_question.C
 	tx.Commit()
 	return nil
 }
+
+/**
+ * @Description: 查询状态为 statuses 且更新时间早于 before 的提交，按更新时间排序
+ * @param statuses []int
+ * @param before time.Time
+ * @param limit int
+ * @return []model_question.QuestionSubmit
+ * @return error
+ */
+func (qsds *QuestionSubmitService) GetStaleSubmits(statuses []int, before time.Time, limit int) ([]model_question.QuestionSubmit, error) {
+	err := qsds.db.AutoMigrate(&model_question.QuestionSubmit{})
+	if err != nil {
+		return nil, err
+	}
+
+	var res []model_question.QuestionSubmit
+	err = qsds.db.Table("question_submit").
+		Where("status IN ? AND update_time < ? AND is_delete = ?", statuses, before, constant.ALIVE).
+		Order("update_time").Limit(limit).Find(&res).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+/**
+ * @Description: 将提交从 from 状态原子地改为 to 状态，状态已被其他判题进程修改时返回 false
+ * @param submitId string
+ * @param from int
+ * @param to int
+ * @return bool
+ * @return error
+ */
+func (qsds *QuestionSubmitService) TransitSubmitStatus(submitId string, from, to int) (bool, error) {
+	err := qsds.db.AutoMigrate(&model_question.QuestionSubmit{})
+	if err != nil {
+		return false, err
+	}
+
+	return compareAndSet(qsds.db.Table("question_submit").
+		Where("id = ? AND status = ? AND is_delete = ?", submitId, from, constant.ALIVE),
+		map[string]interface{}{"status": to, "update_time": time.Now()})
+}
+
+/**
+ * @Description: 提交仍处于 statuses 之一且更新时间早于 before 时保存 request，判题进程已经保存结果时返回 false
+ * @param request model_question.CommonQuestionSubmitRequest
+ * @param statuses []int
+ * @param before time.Time
+ * @return bool
+ * @return error
+ */
+func (qsds *QuestionSubmitService) UpdateStaleSubmit(request model_question.CommonQuestionSubmitRequest, statuses []int, before time.Time) (bool, error) {
+	err := qsds.db.AutoMigrate(&model_question.QuestionSubmit{})
+	if err != nil {
+		return false, err
+	}
+	request.UpdateTime = time.Now()
+
+	return compareAndSet(qsds.db.Table("question_submit").
+		Where("id = ? AND status IN ? AND update_time < ? AND is_delete = ?", request.ID, statuses, before, constant.ALIVE).
+		Select("*"), request)
+}
+
+/**
+ * @Description: 提交仍处于 statuses 之一时保存 request，状态已被其他判题进程或恢复任务修改时返回 false
+ * @param request model_question.CommonQuestionSubmitRequest
+ * @param statuses []int
+ * @return bool
+ * @return error
+ */
+func (qsds *QuestionSubmitService) UpdateSubmitInStatus(request model_question.CommonQuestionSubmitRequest, statuses []int) (bool, error) {
+	err := qsds.db.AutoMigrate(&model_question.QuestionSubmit{})
+	if err != nil {
+		return false, err
+	}
+	request.UpdateTime = time.Now()
+
+	return compareAndSet(qsds.db.Table("question_submit").
+		Where("id = ? AND status IN ? AND is_delete = ?", request.ID, statuses, constant.ALIVE).
+		Select("*"), request)
+}
+
+// compareAndSet 按 query 中的条件更新，只有条件仍然成立时才会更新到一行
+func compareAndSet(query *gorm.DB, values interface{}) (bool, error) {
+	tx := query.Updates(values)
+	if tx.Error != nil {
+		return false, tx.Error
+	}
+
+	return tx.RowsAffected == 1, nil
+}
+
+// TouchSubmit 刷新判题中的提交的更新时间，提交已经不在判题中时返回 false
+func (qsds *QuestionSubmitService) TouchSubmit(submitId string) (bool, error) {
+	return qsds.TransitSubmitStatus(submitId, constant.JUDGING, constant.JUDGING)
+}
+
+// ClaimSubmit 将等待中的提交标记为判题中，同一提交只有一个判题进程能够领取成功
+func (qsds *QuestionSubmitService) ClaimSubmit(submitId string) (bool, error) {
+	return qsds.TransitSubmitStatus(submitId, constant.WAITING, constant.JUDGING)
+}
+
+// ReleaseSubmit 判题出现系统错误时将提交放回等待状态，以便重试时重新领取
+func (qsds *QuestionSubmitService) ReleaseSubmit(submitId string) (bool, error) {
+	return qsds.TransitSubmitStatus(submitId, constant.JUDGING, constant.WAITING)
+}
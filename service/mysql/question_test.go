@@ -0,0 +1,106 @@
+package mysql
+
+import (
+	"fmt"
+	"github.com/xissg/userManageSystem/common/constant"
+	"github.com/xissg/userManageSystem/entity/model_question"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"os"
+	"testing"
+	"time"
+)
+
+// testDSNEnv 测试使用的数据库，重新统计会清空 question_accept，只能指向测试专用的库
+const testDSNEnv = "MYSQL_TEST_DSN"
+
+// newTestQuestionService 没有配置测试数据库时跳过
+func newTestQuestionService(t *testing.T) *QuestionService {
+	dsn := os.Getenv(testDSNEnv)
+	if dsn == "" {
+		t.Skip(testDSNEnv + " is not set")
+	}
+	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
+	if err != nil {
+		t.Skip("mysql unavailable:", err)
+	}
+	return &QuestionService{db: db}
+}
+
+func addTestQuestion(t *testing.T, qds *QuestionService) string {
+	id := fmt.Sprintf("test-%d", time.Now().UnixNano())
+	err := qds.AddQuestion(model_question.Question{ID: id, UserId: "admin", SubmitNum: 9, AcceptNum: 9, AcceptUserNum: 9, CreateTime: time.Now(), UpdateTime: time.Now(), IsDelete: constant.ALIVE})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		qds.db.Exec("DELETE FROM question WHERE id = ?", id)
+		qds.db.Exec("DELETE FROM question_submit WHERE question_id = ?", id)
+		qds.db.Exec("DELETE FROM question_accept WHERE question_id = ?", id)
+	})
+	return id
+}
+
+func counters(t *testing.T, qds *QuestionService, id string) [3]int {
+	question, err := qds.GetQuestion(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return [3]int{question.SubmitNum, question.AcceptNum, question.AcceptUserNum}
+}
+
+func TestRecordAccept(t *testing.T) {
+	qds := newTestQuestionService(t)
+	id := addTestQuestion(t, qds)
+	before := counters(t, qds, id)
+
+	//同一用户通过两次，通过人数只增加一
+	for _, userId := range []string{"u1", "u1", "u2"} {
+		if err := qds.RecordAccept(id, userId); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got := counters(t, qds, id)
+	if got[1]-before[1] != 3 || got[2]-before[2] != 2 {
+		t.Errorf("accept_num +%d, accept_user_num +%d, want +3 and +2", got[1]-before[1], got[2]-before[2])
+	}
+}
+
+func TestRecomputeCounters(t *testing.T) {
+	qds := newTestQuestionService(t)
+	id := addTestQuestion(t, qds)
+
+	submits := []struct {
+		userId   string
+		status   int
+		isDelete int8
+	}{
+		{"u1", constant.SUCCESS, constant.ALIVE},
+		{"u1", constant.SUCCESS, constant.ALIVE},
+		{"u1", constant.FAIL, constant.ALIVE},
+		{"u2", constant.SUCCESS, constant.ALIVE},
+		{"u3", constant.SUCCESS, constant.DELETE},
+	}
+	for i, s := range submits {
+		err := qds.db.Table("question_submit").Create(&model_question.QuestionSubmit{
+			ID:         fmt.Sprintf("%s-%d", id, i),
+			Code:       "code",
+			Status:     s.status,
+			QuestionId: id,
+			UserId:     s.userId,
+			CreateTime: time.Now(),
+			UpdateTime: time.Now(),
+			IsDelete:   s.isDelete,
+		}).Error
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := qds.RecomputeCounters(); err != nil {
+		t.Fatal(err)
+	}
+	if got := counters(t, qds, id); got != [3]int{4, 3, 2} {
+		t.Errorf("submit_num, accept_num, accept_user_num = %v, want [4 3 2]", got)
+	}
+}
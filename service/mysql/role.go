@@ -0,0 +1,178 @@
+package mysql
+
+import (
+	"github.com/xissg/userManageSystem/entity/model_user"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+type RoleService struct {
+	db *gorm.DB
+}
+
+func NewRoleService() *RoleService {
+	db := initDB()
+	return &RoleService{
+		db: db,
+	}
+}
+
+func (rs *RoleService) migrate() error {
+	return rs.db.AutoMigrate(&model_user.Role{}, &model_user.RolePermission{}, &model_user.RoleAssignment{})
+}
+
+/**
+ * @Description: 写入内置角色和权限，已存在的记录保持不变
+ * @param roles []model_user.RoleInfo
+ * @return error
+ */
+func (rs *RoleService) SeedRoles(roles []model_user.RoleInfo) error {
+	err := rs.migrate()
+	if err != nil {
+		return err
+	}
+
+	return rs.db.Transaction(func(tx *gorm.DB) error {
+		for _, role := range roles {
+			err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&model_user.Role{
+				Name:        role.Name,
+				Description: role.Description,
+				CreateTime:  time.Now(),
+			}).Error
+			if err != nil {
+				return err
+			}
+			for _, permission := range role.Permissions {
+				err = tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&model_user.RolePermission{
+					RoleName:   role.Name,
+					Permission: permission,
+				}).Error
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+/**
+ * @Description: 查询所有角色及其权限
+ * @return []model_user.RoleInfo
+ * @return error
+ */
+func (rs *RoleService) GetRoles() ([]model_user.RoleInfo, error) {
+	err := rs.migrate()
+	if err != nil {
+		return nil, err
+	}
+
+	var roles []model_user.Role
+	err = rs.db.Table("role").Order("name").Find(&roles).Error
+	if err != nil {
+		return nil, err
+	}
+	permissions, err := rs.RolePermissions()
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]model_user.RoleInfo, 0, len(roles))
+	for _, role := range roles {
+		list := permissions[role.Name]
+		if list == nil {
+			list = []string{}
+		}
+		res = append(res, model_user.RoleInfo{Name: role.Name, Description: role.Description, Permissions: list})
+	}
+	return res, nil
+}
+
+/**
+ * @Description: 查询每个角色拥有的权限
+ * @return map[string][]string
+ * @return error
+ */
+func (rs *RoleService) RolePermissions() (map[string][]string, error) {
+	err := rs.migrate()
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []model_user.RolePermission
+	err = rs.db.Table("role_permission").Order("role_name, permission").Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[string][]string)
+	for _, row := range rows {
+		res[row.RoleName] = append(res[row.RoleName], row.Permission)
+	}
+	return res, nil
+}
+
+/**
+ * @Description: 查询分配给用户的附加角色
+ * @param userId string
+ * @return []string
+ * @return error
+ */
+func (rs *RoleService) UserRoles(userId string) ([]string, error) {
+	err := rs.migrate()
+	if err != nil {
+		return nil, err
+	}
+
+	roles := []string{}
+	err = rs.db.Table("user_role").Where("user_id = ?", userId).Order("role_name").Pluck("role_name", &roles).Error
+	if err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+
+/**
+ * @Description: 为用户分配附加角色，角色不存在时返回 gorm.ErrRecordNotFound，已分配时不报错
+ * @param userId string
+ * @param role string
+ * @return error
+ */
+func (rs *RoleService) AssignRole(userId, role string) error {
+	err := rs.migrate()
+	if err != nil {
+		return err
+	}
+
+	return rs.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Table("role").Where("name = ?", role).First(&model_user.Role{}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&model_user.RoleAssignment{
+			UserId:     userId,
+			RoleName:   role,
+			CreateTime: time.Now(),
+		}).Error
+	})
+}
+
+/**
+ * @Description: 撤销用户的附加角色，返回是否撤销了已分配的角色
+ * @param userId string
+ * @param role string
+ * @return bool
+ * @return error
+ */
+func (rs *RoleService) RevokeRole(userId, role string) (bool, error) {
+	err := rs.migrate()
+	if err != nil {
+		return false, err
+	}
+
+	tx := rs.db.Where("user_id = ? AND role_name = ?", userId, role).Delete(&model_user.RoleAssignment{})
+	if tx.Error != nil {
+		return false, tx.Error
+	}
+	return tx.RowsAffected == 1, nil
+}
@@ -104,6 +104,24 @@ func (us *UserService) UpdateUser(user model_user.User) error {
 	return nil
 }
 
+/**
+ * @Description: 将密码哈希从 oldHash 替换为 newHash，哈希已被修改时不更新
+ * @param accountName string
+ * @param oldHash string
+ * @param newHash string
+ * @return error
+ */
+func (us *UserService) UpdatePassword(accountName, oldHash, newHash string) error {
+	err := us.db.AutoMigrate(&model_user.User{})
+	if err != nil {
+		return err
+	}
+
+	return us.db.Table("user").
+		Where("user_account = ? AND user_password = ? AND is_delete = ?", accountName, oldHash, constant.ALIVE).
+		Update("user_password", newHash).Error
+}
+
 /**
  * @Description: 删除用户
  * @param accountName string
@@ -0,0 +1,48 @@
+package progress
+
+import (
+	"context"
+	"sync"
+)
+
+// Memory 进程内的 Broker，用于测试以及判题进程和服务在同一进程中运行的场景
+type Memory struct {
+	mu          sync.Mutex
+	subscribers map[string]map[chan Event]struct{}
+}
+
+func NewMemory() *Memory {
+	return &Memory{subscribers: make(map[string]map[chan Event]struct{})}
+}
+
+func (m *Memory) Publish(ctx context.Context, event Event) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for ch := range m.subscribers[event.SubmitId] {
+		Offer(ch, event)
+	}
+	return nil
+}
+
+func (m *Memory) Subscribe(ctx context.Context, submitId string) (<-chan Event, error) {
+	ch := NewSubscription()
+	m.mu.Lock()
+	if m.subscribers[submitId] == nil {
+		m.subscribers[submitId] = make(map[chan Event]struct{})
+	}
+	m.subscribers[submitId][ch] = struct{}{}
+	m.mu.Unlock()
+
+	//取消订阅后关闭通道，发布方持有锁时不会再向该通道发送
+	go func() {
+		<-ctx.Done()
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		delete(m.subscribers[submitId], ch)
+		if len(m.subscribers[submitId]) == 0 {
+			delete(m.subscribers, submitId)
+		}
+		close(ch)
+	}()
+	return ch, nil
+}
@@ -0,0 +1,63 @@
+package progress
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryFanOut(t *testing.T) {
+	m := NewMemory()
+	ctx, cancel := context.WithCancel(context.Background())
+	a, _ := m.Subscribe(ctx, "1")
+	b, _ := m.Subscribe(context.Background(), "1")
+	other, _ := m.Subscribe(context.Background(), "2")
+
+	_ = m.Publish(context.Background(), Event{SubmitId: "1", Stage: StageRunning, Case: 1, Total: 2})
+	for _, ch := range []<-chan Event{a, b} {
+		select {
+		case e := <-ch:
+			if e.Stage != StageRunning || e.Case != 1 {
+				t.Errorf("unexpected event %+v", e)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("event not delivered")
+		}
+	}
+	select {
+	case e := <-other:
+		t.Errorf("event of another submit delivered %+v", e)
+	default:
+	}
+
+	//取消订阅后关闭通道
+	cancel()
+	select {
+	case _, ok := <-a:
+		if ok {
+			t.Error("unexpected event after unsubscribe")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("subscription not closed")
+	}
+	_ = m.Publish(context.Background(), Event{SubmitId: "1", Stage: StageFinished})
+	if e := <-b; e.Stage != StageFinished {
+		t.Errorf("remaining subscriber should still receive events, got %+v", e)
+	}
+}
+
+func TestOfferKeepsLatest(t *testing.T) {
+	ch := NewSubscription()
+	for i := 1; i <= subscriberBuffer*2; i++ {
+		Offer(ch, Event{Stage: StageRunning, Case: i})
+	}
+	Offer(ch, Event{Stage: StageFinished})
+
+	var last Event
+	for len(ch) > 0 {
+		last = <-ch
+	}
+	if last.Stage != StageFinished {
+		t.Errorf("final event dropped, last %+v", last)
+	}
+}
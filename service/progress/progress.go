@@ -0,0 +1,66 @@
+package progress
+
+import (
+	"context"
+	"github.com/xissg/userManageSystem/entity/model_question"
+)
+
+// 判题进度的阶段
+const (
+	StageQueued    = "queued"    //等待判题，判题出现系统错误等待重试时也会再次发送
+	StageJudging   = "judging"   //判题进程已领取提交
+	StageCompiling = "compiling" //编译用户代码
+	StageRunning   = "running"   //运行第 Case 个用例，共 Total 个
+	StageFinished  = "finished"  //判题结束，Status、JudgeInfo 和 Summary 为最终结果
+)
+
+// subscriberBuffer 每个订阅者缓存的事件数，订阅者跟不上时丢弃最早的事件
+const subscriberBuffer = 16
+
+// Event 一次提交的判题进度
+type Event struct {
+	SubmitId  string                       `json:"submit_id"`
+	Stage     string                       `json:"stage"`
+	Case      int                          `json:"case,omitempty"`
+	Total     int                          `json:"total,omitempty"`
+	Status    int                          `json:"status,omitempty"`
+	JudgeInfo []model_question.JudgeInfo   `json:"judge_info,omitempty"`
+	Summary   *model_question.JudgeSummary `json:"summary,omitempty"`
+}
+
+// Publisher 发布判题进度，没有订阅者时事件被丢弃
+type Publisher interface {
+	Publish(ctx context.Context, event Event) error
+}
+
+// Subscriber 订阅一个提交的判题进度，返回时已经订阅成功，ctx 结束后关闭返回的通道
+type Subscriber interface {
+	Subscribe(ctx context.Context, submitId string) (<-chan Event, error)
+}
+
+// Broker 将判题进程发布的进度分发给订阅者
+type Broker interface {
+	Publisher
+	Subscriber
+}
+
+// NewSubscription 创建订阅者使用的通道
+func NewSubscription() chan Event {
+	return make(chan Event, subscriberBuffer)
+}
+
+// Offer 向订阅者发送事件，不阻塞发布方，通道已满时丢弃最早的事件，保证最终结果能送达
+// 同一通道只能有一个发送方
+func Offer(ch chan Event, event Event) {
+	for {
+		select {
+		case ch <- event:
+			return
+		default:
+		}
+		select {
+		case <-ch:
+		default:
+		}
+	}
+}
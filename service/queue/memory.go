@@ -0,0 +1,222 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// errUnknownMessage 消息已经确认过，或者在连接断开后被重新投递
+var errUnknownMessage = errors.New("unknown message")
+
+type entry struct {
+	id          uint64
+	body        string
+	redelivered bool
+	attempt     int
+}
+
+// Memory 进程内的队列，语义与判题使用的rabbitmq队列一致，用于测试和单机开发
+type Memory struct {
+	mu       sync.Mutex
+	nextID   uint64
+	pending  []entry
+	inflight map[uint64]entry
+	dead     []entry
+	changed  chan struct{} //状态变化时关闭并替换，唤醒等待的消费者
+	conn     uint64        //连接断开时加一，之前的消费者全部结束
+	closed   bool
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		inflight: make(map[uint64]entry),
+		changed:  make(chan struct{}),
+	}
+}
+
+func (q *Memory) Publish(ctx context.Context, body string) error {
+	return q.push(entry{body: body})
+}
+
+func (q *Memory) push(e entry) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return ErrClosed
+	}
+	q.nextID++
+	e.id = q.nextID
+	q.pending = append(q.pending, e)
+	q.broadcast()
+	return nil
+}
+
+func (q *Memory) Consume(ctx context.Context, prefetch int) (<-chan Message, error) {
+	if prefetch <= 0 {
+		prefetch = 1
+	}
+	q.mu.Lock()
+	closed, conn := q.closed, q.conn
+	q.mu.Unlock()
+	if closed {
+		return nil, ErrClosed
+	}
+
+	out := make(chan Message)
+	go func() {
+		defer close(out)
+		var unacked int //本消费者未确认的消息数
+		for {
+			q.mu.Lock()
+			for !q.closed && q.conn == conn && ctx.Err() == nil && (len(q.pending) == 0 || unacked >= prefetch) {
+				changed := q.changed
+				q.mu.Unlock()
+				select {
+				case <-changed:
+				case <-ctx.Done():
+				}
+				q.mu.Lock()
+			}
+			if q.closed || q.conn != conn || ctx.Err() != nil {
+				q.mu.Unlock()
+				return
+			}
+			e := q.pending[0]
+			q.pending = q.pending[1:]
+			q.inflight[e.id] = e
+			unacked++
+			q.mu.Unlock()
+
+			ack := &memoryAck{q: q, e: e, release: func() { unacked-- }}
+			select {
+			case out <- NewMessage(e.body, e.redelivered, e.attempt, ack):
+			case <-ctx.Done():
+				_ = ack.Nack(true)
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// memoryAck 每条消息只能确认一次
+type memoryAck struct {
+	q       *Memory
+	e       entry
+	release func() //调用时持有锁
+	once    sync.Once
+}
+
+// settle 移出处理中的消息，then 在持有锁时处理该消息，连接断开后的消息返回错误
+func (a *memoryAck) settle(then func(e entry)) error {
+	err := errUnknownMessage
+	a.once.Do(func() {
+		q := a.q
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		a.release()
+		q.broadcast()
+		if _, ok := q.inflight[a.e.id]; !ok {
+			return
+		}
+		err = nil
+		delete(q.inflight, a.e.id)
+		then(a.e)
+	})
+	return err
+}
+
+func (a *memoryAck) Ack() error {
+	return a.settle(func(entry) {})
+}
+
+func (a *memoryAck) Nack(requeue bool) error {
+	q := a.q
+	return a.settle(func(e entry) {
+		if requeue {
+			e.redelivered = true
+			q.pending = append([]entry{e}, q.pending...)
+			return
+		}
+		q.dead = append(q.dead, e)
+	})
+}
+
+func (a *memoryAck) Retry(delay time.Duration) error {
+	q := a.q
+	return a.settle(func(e entry) {
+		time.AfterFunc(delay, func() {
+			_ = q.push(entry{body: e.body, attempt: e.attempt + 1})
+		})
+	})
+}
+
+func (q *Memory) Dead(ctx context.Context) ([]string, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var res []string
+	for _, e := range q.dead {
+		res = append(res, e.body)
+	}
+	return res, nil
+}
+
+func (q *Memory) Requeue(ctx context.Context, body string) (bool, error) {
+	q.mu.Lock()
+	index := -1
+	for i, e := range q.dead {
+		if e.body == body {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		q.mu.Unlock()
+		return false, nil
+	}
+	q.dead = append(q.dead[:index], q.dead[index+1:]...)
+	q.mu.Unlock()
+	return true, q.push(entry{body: body})
+}
+
+// Disconnect 模拟消费者的连接断开，已有的消费者全部结束，未确认的消息按原来的顺序重新进入队列
+func (q *Memory) Disconnect() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var requeued []entry
+	for id, e := range q.inflight {
+		e.redelivered = true
+		requeued = append(requeued, e)
+		delete(q.inflight, id)
+	}
+	sort.Slice(requeued, func(i, j int) bool { return requeued[i].id < requeued[j].id })
+	q.pending = append(requeued, q.pending...)
+	q.conn++
+	q.broadcast()
+}
+
+// Len 等待投递的消息数
+func (q *Memory) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.pending)
+}
+
+func (q *Memory) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if !q.closed {
+		q.closed = true
+		q.broadcast()
+	}
+	return nil
+}
+
+// broadcast 调用时需要持有锁
+func (q *Memory) broadcast() {
+	close(q.changed)
+	q.changed = make(chan struct{})
+}
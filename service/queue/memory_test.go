@@ -0,0 +1,159 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func receive(t *testing.T, msgs <-chan Message) Message {
+	t.Helper()
+	select {
+	case msg, ok := <-msgs:
+		if !ok {
+			t.Fatal("consumer closed")
+		}
+		return msg
+	case <-time.After(time.Second):
+		t.Fatal("no message delivered")
+	}
+	return Message{}
+}
+
+func expectNone(t *testing.T, msgs <-chan Message) {
+	t.Helper()
+	select {
+	case msg, ok := <-msgs:
+		if ok {
+			t.Fatalf("unexpected message %q", msg.Body)
+		}
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestMemoryPrefetch(t *testing.T) {
+	q := NewMemory()
+	defer q.Close()
+	for _, body := range []string{"1", "2", "3"} {
+		if err := q.Publish(context.Background(), body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	msgs, err := q.Consume(context.Background(), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, second := receive(t, msgs), receive(t, msgs)
+	if first.Body != "1" || second.Body != "2" {
+		t.Errorf("messages should be delivered in order, got %q %q", first.Body, second.Body)
+	}
+	//未确认的消息达到上限后不再投递
+	expectNone(t, msgs)
+	if err = first.Ack(); err != nil {
+		t.Fatal(err)
+	}
+	if msg := receive(t, msgs); msg.Body != "3" {
+		t.Errorf("got %q, want 3", msg.Body)
+	}
+	if err = first.Ack(); err == nil {
+		t.Error("acking twice should fail")
+	}
+}
+
+func TestMemoryRedelivery(t *testing.T) {
+	q := NewMemory()
+	defer q.Close()
+	_ = q.Publish(context.Background(), "1")
+	msgs, _ := q.Consume(context.Background(), 1)
+
+	//拒绝并重新入队
+	msg := receive(t, msgs)
+	if err := msg.Nack(true); err != nil {
+		t.Fatal(err)
+	}
+	msg = receive(t, msgs)
+	if msg.Body != "1" || !msg.Redelivered {
+		t.Errorf("requeued message should be redelivered: %+v", msg)
+	}
+
+	//连接断开时消费者结束，未确认的消息交给新的消费者
+	q.Disconnect()
+	if _, ok := <-msgs; ok {
+		t.Error("consumer should be closed after disconnect")
+	}
+	if err := msg.Ack(); err == nil {
+		t.Error("message from a lost connection should not be acked")
+	}
+	msgs, _ = q.Consume(context.Background(), 1)
+	msg = receive(t, msgs)
+	if msg.Body != "1" || !msg.Redelivered {
+		t.Errorf("unacked message should be redelivered: %+v", msg)
+	}
+	if err := msg.Nack(false); err != nil {
+		t.Fatal(err)
+	}
+	expectNone(t, msgs)
+	if q.Len() != 0 {
+		t.Errorf("rejected message should not be requeued, %d pending", q.Len())
+	}
+}
+
+func TestMemoryCancel(t *testing.T) {
+	q := NewMemory()
+	_ = q.Publish(context.Background(), "1")
+	ctx, cancel := context.WithCancel(context.Background())
+	msgs, _ := q.Consume(ctx, 1)
+	msg := receive(t, msgs)
+	cancel()
+	if _, ok := <-msgs; ok {
+		t.Error("consumer should be closed after cancel")
+	}
+	//停止消费后仍然可以确认处理中的消息
+	if err := msg.Ack(); err != nil {
+		t.Error(err)
+	}
+
+	_ = q.Close()
+	if err := q.Publish(context.Background(), "2"); err != ErrClosed {
+		t.Errorf("publish to a closed queue should fail, got %v", err)
+	}
+}
+
+func TestMemoryRetryAndDeadLetter(t *testing.T) {
+	q := NewMemory()
+	defer q.Close()
+	_ = q.Publish(context.Background(), "1")
+	msgs, _ := q.Consume(context.Background(), 1)
+
+	msg := receive(t, msgs)
+	if err := msg.Retry(10 * time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if q.Len() != 0 {
+		t.Error("retried message should wait for the delay")
+	}
+	msg = receive(t, msgs)
+	if msg.Body != "1" || msg.Attempt != 1 {
+		t.Errorf("retried message should carry the attempt: %+v", msg)
+	}
+
+	//拒绝后进入死信队列，重新入队后重试次数归零
+	_ = msg.Nack(false)
+	dead, _ := q.Dead(context.Background())
+	if len(dead) != 1 || dead[0] != "1" {
+		t.Errorf("rejected message should be dead lettered, got %v", dead)
+	}
+	if found, _ := q.Requeue(context.Background(), "2"); found {
+		t.Error("unknown message should not be requeued")
+	}
+	if found, err := q.Requeue(context.Background(), "1"); !found || err != nil {
+		t.Fatalf("requeue: %v %v", found, err)
+	}
+	msg = receive(t, msgs)
+	if msg.Attempt != 0 {
+		t.Errorf("requeued message should start over: %+v", msg)
+	}
+	if dead, _ = q.Dead(context.Background()); len(dead) != 0 {
+		t.Errorf("dead letter queue should be empty, got %v", dead)
+	}
+}
@@ -0,0 +1,83 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrClosed 队列已经关闭
+var ErrClosed = errors.New("queue closed")
+
+// Acknowledger 由队列的实现提供，确认消息的处理结果
+type Acknowledger interface {
+	Ack() error
+	Nack(requeue bool) error
+	Retry(delay time.Duration) error
+}
+
+// Message 队列中的一条消息，处理完成后 Ack，处理失败时 Retry 或 Nack
+// 没有确认的消息在消费者断开后会被重新投递，Redelivered 为 true
+type Message struct {
+	Body        string
+	Redelivered bool
+	Attempt     int //已经失败并重试的次数
+
+	acknowledger Acknowledger
+}
+
+func NewMessage(body string, redelivered bool, attempt int, acknowledger Acknowledger) Message {
+	return Message{Body: body, Redelivered: redelivered, Attempt: attempt, acknowledger: acknowledger}
+}
+
+func (m Message) Ack() error {
+	return m.acknowledger.Ack()
+}
+
+// Nack requeue 为 true 时消息重新进入队列，否则进入死信队列
+func (m Message) Nack(requeue bool) error {
+	return m.acknowledger.Nack(requeue)
+}
+
+// Retry 延迟delay后重新投递，Attempt 加一
+func (m Message) Retry(delay time.Duration) error {
+	return m.acknowledger.Retry(delay)
+}
+
+// Publisher 发送消息，返回nil时消息已经被队列持久化
+type Publisher interface {
+	Publish(ctx context.Context, body string) error
+}
+
+// Consumer 消费消息，同时最多有 prefetch 条未确认的消息
+// ctx 结束后停止投递并关闭返回的通道，已经收到的消息仍然可以确认
+type Consumer interface {
+	Consume(ctx context.Context, prefetch int) (<-chan Message, error)
+}
+
+// DeadLetters 死信队列，保存被拒绝的消息，等待人工处理
+type DeadLetters interface {
+	Dead(ctx context.Context) ([]string, error)
+	// Requeue 将死信队列中内容为body的消息重新发送到队列，重试次数归零，返回是否找到该消息
+	Requeue(ctx context.Context, body string) (bool, error)
+}
+
+type Queue interface {
+	Publisher
+	Consumer
+	DeadLetters
+	Close() error
+}
+
+// RetryPolicy 处理失败的消息最多重试 MaxRetries 次，第n次重试前等待 Backoff*2^n
+type RetryPolicy struct {
+	MaxRetries int
+	Backoff    time.Duration
+}
+
+var DefaultRetryPolicy = RetryPolicy{MaxRetries: 3, Backoff: 5 * time.Second}
+
+// Delay 已经重试 attempt 次后，下一次重试前的等待时间
+func (p RetryPolicy) Delay(attempt int) time.Duration {
+	return p.Backoff << attempt
+}
@@ -0,0 +1,328 @@
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/xissg/userManageSystem/service/queue"
+	"sync"
+	"time"
+)
+
+const (
+	defaultJudgeQueue = "judge_submit"
+	defaultPrefetch   = 2
+	attemptHeader     = "x-attempt" //消息已经重试的次数
+)
+
+// JudgeQueue 判题队列，队列和消息都持久化，发送时等待服务端确认，消费时手动确认
+// 消费者崩溃或断开时未确认的提交由rabbitmq重新投递
+//
+// 被拒绝的消息经过死信交换机 <name>.dlx 进入 <name>.dead 队列
+// 重试的消息进入 <name>.retry.<毫秒> 延迟队列，过期后经默认交换机回到判题队列
+type JudgeQueue struct {
+	conn *amqp.Connection
+	name string
+
+	mu        sync.Mutex
+	pub       *amqp.Channel
+	consumers []*amqp.Channel
+	delays    map[time.Duration]string //已经声明的延迟队列
+}
+
+// NewJudgeQueue 按 conf/rabbitmq.yaml 连接rabbitmq并声明判题队列
+func NewJudgeQueue() (*JudgeQueue, error) {
+	config := readConfig("rabbitmq")
+	name := config.JudgeQueue
+	if name == "" {
+		name = defaultJudgeQueue
+	}
+	conn, err := initRabbitMQ()
+	if err != nil {
+		return nil, err
+	}
+	q := &JudgeQueue{conn: conn, name: name, delays: make(map[time.Duration]string)}
+	if err = q.declare(); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return q, nil
+}
+
+// Prefetch 每个判题进程同时处理的提交数
+func Prefetch() int {
+	if prefetch := readConfig("rabbitmq").Prefetch; prefetch > 0 {
+		return prefetch
+	}
+	return defaultPrefetch
+}
+
+// EmbeddedWorker API 服务进程内是否同时判题
+func EmbeddedWorker() bool {
+	return readConfig("rabbitmq").EmbeddedWorker
+}
+
+// LoadRetryPolicy 判题的系统错误的重试策略，未配置时使用默认值
+func LoadRetryPolicy() queue.RetryPolicy {
+	config := readConfig("rabbitmq")
+	policy := queue.DefaultRetryPolicy
+	if config.MaxRetries > 0 {
+		policy.MaxRetries = config.MaxRetries
+	}
+	if config.RetryBackoff > 0 {
+		policy.Backoff = config.RetryBackoff
+	}
+	return policy
+}
+
+func (q *JudgeQueue) dlx() string {
+	return q.name + ".dlx"
+}
+
+func (q *JudgeQueue) deadQueue() string {
+	return q.name + ".dead"
+}
+
+func (q *JudgeQueue) declare() error {
+	ch, err := q.conn.Channel()
+	if err != nil {
+		return err
+	}
+	err = q.declareTopology(ch)
+	if err == nil {
+		//开启发送确认
+		err = ch.Confirm(false)
+	}
+	if err != nil {
+		_ = ch.Close()
+		return err
+	}
+	q.pub = ch
+	return nil
+}
+
+// declareTopology 声明死信交换机、死信队列和判题队列，都持久化
+func (q *JudgeQueue) declareTopology(ch *amqp.Channel) error {
+	err := ch.ExchangeDeclare(
+		q.dlx(),  // 交换机名称
+		"direct", // 交换机类型
+		true,     // 持久化
+		false,    // 不自动删除
+		false,    // 不是内部使用
+		false,    // 等待服务器响应
+		nil,
+	)
+	if err != nil {
+		return err
+	}
+	if _, err = ch.QueueDeclare(q.deadQueue(), true, false, false, false, nil); err != nil {
+		return err
+	}
+	if err = ch.QueueBind(q.deadQueue(), q.name, q.dlx(), false, nil); err != nil {
+		return err
+	}
+	_, err = ch.QueueDeclare(
+		q.name, // 队列名称
+		true,   // 持久化，rabbitmq重启后队列仍然存在
+		false,  // 不自动删除
+		false,  // 不排他
+		false,  // 等待服务器响应
+		amqp.Table{
+			"x-dead-letter-exchange":    q.dlx(),
+			"x-dead-letter-routing-key": q.name,
+		},
+	)
+	return err
+}
+
+// delayQueue 声明延迟为delay的重试队列，消息过期后回到判题队列
+func (q *JudgeQueue) delayQueue(delay time.Duration) (string, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if name, ok := q.delays[delay]; ok {
+		return name, nil
+	}
+	name := fmt.Sprintf("%s.retry.%d", q.name, delay.Milliseconds())
+	_, err := q.pub.QueueDeclare(name, true, false, false, false, amqp.Table{
+		"x-message-ttl":             delay.Milliseconds(),
+		"x-dead-letter-exchange":    "",
+		"x-dead-letter-routing-key": q.name,
+	})
+	if err != nil {
+		return "", err
+	}
+	q.delays[delay] = name
+	return name, nil
+}
+
+// Publish 发送持久化的消息，服务端确认写入后返回
+func (q *JudgeQueue) Publish(ctx context.Context, body string) error {
+	return q.publish(ctx, q.name, body, 0)
+}
+
+func (q *JudgeQueue) publish(ctx context.Context, key string, body string, attempt int) error {
+	q.mu.Lock()
+	confirm, err := q.pub.PublishWithDeferredConfirmWithContext(ctx,
+		"",    // 默认交换机，按队列名称路由
+		key,   // routing key
+		false, // mandatory
+		false, // immediate
+		amqp.Publishing{
+			ContentType:  "text/plain",
+			DeliveryMode: amqp.Persistent,
+			Headers:      amqp.Table{attemptHeader: int32(attempt)},
+			Body:         []byte(body),
+		},
+	)
+	q.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	acked, err := confirm.WaitContext(ctx)
+	if err != nil {
+		return err
+	}
+	if !acked {
+		return errors.New("message rejected by rabbitmq")
+	}
+	return nil
+}
+
+// Consume 每个消费者使用独立的channel，通过 Qos 限制未确认的消息数
+func (q *JudgeQueue) Consume(ctx context.Context, prefetch int) (<-chan queue.Message, error) {
+	ch, err := q.conn.Channel()
+	if err != nil {
+		return nil, err
+	}
+	if err = ch.Qos(prefetch, 0, false); err != nil {
+		_ = ch.Close()
+		return nil, err
+	}
+	deliveries, err := ch.ConsumeWithContext(ctx,
+		q.name, // 队列名称
+		"",     // 由客户端生成消费者标识
+		false,  // 手动确认
+		false,  // exclusive
+		false,  // no-local
+		false,  // no-wait
+		nil,
+	)
+	if err != nil {
+		_ = ch.Close()
+		return nil, err
+	}
+	q.mu.Lock()
+	q.consumers = append(q.consumers, ch)
+	q.mu.Unlock()
+
+	//ctx 结束后消费者被取消，deliveries 随之关闭，channel 保留到 Close 以便确认处理中的消息
+	out := make(chan queue.Message)
+	go func() {
+		defer close(out)
+		for d := range deliveries {
+			attempt := attemptOf(d)
+			out <- queue.NewMessage(string(d.Body), d.Redelivered, attempt, &delivery{q: q, d: d, attempt: attempt})
+		}
+	}()
+	return out, nil
+}
+
+// attemptOf 读取消息头中的重试次数，rabbitmq返回的整数类型与发送时可能不同
+func attemptOf(d amqp.Delivery) int {
+	switch v := d.Headers[attemptHeader].(type) {
+	case int32:
+		return int(v)
+	case int64:
+		return int(v)
+	case int:
+		return v
+	}
+	return 0
+}
+
+type delivery struct {
+	q       *JudgeQueue
+	d       amqp.Delivery
+	attempt int
+}
+
+func (d *delivery) Ack() error {
+	return d.d.Ack(false)
+}
+
+// Nack 不重新入队时消息经死信交换机进入死信队列
+func (d *delivery) Nack(requeue bool) error {
+	return d.d.Nack(false, requeue)
+}
+
+// Retry 先将消息写入延迟队列，再确认原消息，写入失败时原消息保持未确认
+func (d *delivery) Retry(delay time.Duration) error {
+	name, err := d.q.delayQueue(delay)
+	if err != nil {
+		return err
+	}
+	if err = d.q.publish(context.Background(), name, string(d.d.Body), d.attempt+1); err != nil {
+		return err
+	}
+	return d.d.Ack(false)
+}
+
+// Dead 逐条取出死信队列中的消息但不确认，关闭channel后消息全部回到死信队列
+func (q *JudgeQueue) Dead(ctx context.Context) ([]string, error) {
+	ch, err := q.conn.Channel()
+	if err != nil {
+		return nil, err
+	}
+	defer ch.Close()
+
+	var res []string
+	for ctx.Err() == nil {
+		d, ok, err := ch.Get(q.deadQueue(), false)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+		res = append(res, string(d.Body))
+	}
+	return res, ctx.Err()
+}
+
+// Requeue 找到死信队列中的消息后重新发送到判题队列，再从死信队列中确认删除
+func (q *JudgeQueue) Requeue(ctx context.Context, body string) (bool, error) {
+	ch, err := q.conn.Channel()
+	if err != nil {
+		return false, err
+	}
+	defer ch.Close()
+
+	for ctx.Err() == nil {
+		d, ok, err := ch.Get(q.deadQueue(), false)
+		if err != nil {
+			return false, err
+		}
+		if !ok {
+			return false, nil
+		}
+		if string(d.Body) != body {
+			continue
+		}
+		if err = q.Publish(ctx, body); err != nil {
+			return false, err
+		}
+		return true, d.Ack(false)
+	}
+	return false, ctx.Err()
+}
+
+func (q *JudgeQueue) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, ch := range q.consumers {
+		_ = ch.Close()
+	}
+	q.consumers = nil
+	return q.conn.Close()
+}
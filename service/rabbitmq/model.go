@@ -1,12 +1,25 @@
 package rabbitmq
 
-import "github.com/rabbitmq/amqp091-go"
+import (
+	"github.com/rabbitmq/amqp091-go"
+	"time"
+)
 
 type Config struct {
 	User     string `yaml:"user"`
 	Password string `yaml:"password"`
 	Host     string `yaml:"host"`
 	Port     int    `yaml:"port"`
+	//判题队列的名称
+	JudgeQueue string `yaml:"judge_queue" mapstructure:"judge_queue"`
+	//每个判题进程同时处理的提交数
+	Prefetch int `yaml:"prefetch"`
+	//API 服务进程内是否同时判题，单独部署 judge-worker 时设为 false
+	EmbeddedWorker bool `yaml:"embedded_worker" mapstructure:"embedded_worker"`
+	//判题出现系统错误时的最大重试次数
+	MaxRetries int `yaml:"max_retries" mapstructure:"max_retries"`
+	//第一次重试前的等待时间，之后每次翻倍
+	RetryBackoff time.Duration `yaml:"retry_backoff" mapstructure:"retry_backoff"`
 }
 
 type Context struct {
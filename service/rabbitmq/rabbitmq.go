@@ -106,7 +106,7 @@ func (r *RabbitMQService) Publish(publish Publish) error {
 	err := r.ch.Publish(
 		publish.exchange,
 		publish.key,
-		publish.immediate,
+		publish.mandatory,
 		publish.immediate,
 		publish.msg,
 	)
@@ -132,79 +132,3 @@ func (r *RabbitMQService) Consume(consume Consume) ([]string, error) {
 	}
 	return res, nil
 }
-
-func NewDefaultMQPublisher(data string) {
-	service := NewRabbitQMService()
-	err := service.DeclareChannel()
-	if err != nil {
-		log.Fatal(err)
-		return
-	}
-	que := Queue{
-		"default", // name
-		false,     // durable
-		false,     // delete when unused
-		false,     // exclusive
-		false,     // no-wait
-		nil,
-	}
-	err = service.DeclareQueue(que)
-	if err != nil {
-		log.Fatal(err)
-		return
-	}
-	publish := Publish{
-		"",             // exchange
-		service.q.Name, // routing key
-		false,          // mandatory
-		false,          // immediate
-		amqp.Publishing{
-			ContentType: "text/plain",
-			Body:        []byte(data),
-		},
-	}
-	err = service.Publish(publish)
-	if err != nil {
-		log.Fatal(err)
-		return
-	}
-}
-
-func NewDefaultMQConsumer() []string {
-	service := NewRabbitQMService()
-	err := service.DeclareChannel()
-	if err != nil {
-		log.Fatal(err)
-		return nil
-	}
-
-	que := Queue{
-		"default", // name
-		false,     // durable
-		false,     // delete when unused
-		false,     // exclusive
-		false,     // no-wait
-		nil,
-	}
-	err = service.DeclareQueue(que)
-	if err != nil {
-		log.Fatal(err)
-		return nil
-	}
-
-	consume := Consume{
-		service.q.Name, // queue
-		"",             // consumer
-		true,           // auto-ack
-		false,          // exclusive
-		false,          // no-local
-		false,          // no-wait
-		nil,            // args
-	}
-	msgs, err := service.Consume(consume)
-	if err != nil {
-		log.Fatal(err)
-		return nil
-	}
-	return msgs
-}
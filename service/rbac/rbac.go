@@ -0,0 +1,136 @@
+package rbac
+
+import (
+	"github.com/xissg/userManageSystem/common/constant"
+	"github.com/xissg/userManageSystem/entity/model_user"
+	"log"
+	"sort"
+	"sync"
+)
+
+// DefaultRoles 内置角色，启动时写入数据库，已存在的角色和权限不会被覆盖
+// common、admin 对应用户的 user_role 字段，其余角色通过 user_role 表分配
+var DefaultRoles = []model_user.RoleInfo{
+	{
+		Name:        constant.Common,
+		Description: "registered user",
+		Permissions: []string{constant.PermQuestionView, constant.PermSubmitCreate},
+	},
+	{
+		Name:        constant.Admin,
+		Description: "administrator with every permission",
+		Permissions: constant.Permissions,
+	},
+	{
+		Name:        constant.ProblemSetter,
+		Description: "writes questions and their judge cases",
+		Permissions: []string{constant.PermQuestionView, constant.PermQuestionWrite, constant.PermQuestionViewAnswer},
+	},
+	{
+		Name:        constant.Moderator,
+		Description: "bans and unbans users",
+		Permissions: []string{constant.PermUserView, constant.PermUserBan},
+	},
+	{
+		Name:        constant.JudgeOperator,
+		Description: "inspects submissions and requeues failed judges",
+		Permissions: []string{constant.PermSubmitViewAll, constant.PermSubmitRequeue},
+	},
+	{
+		Name:        constant.Auditor,
+		Description: "read-only access to users, submissions and answers",
+		Permissions: []string{constant.PermUserView, constant.PermSubmitViewAll, constant.PermQuestionViewAnswer},
+	},
+}
+
+// Store 角色和权限的存储，mysql.RoleService 实现该接口
+type Store interface {
+	RolePermissions() (map[string][]string, error)
+	UserRoles(userId string) ([]string, error)
+}
+
+// Authorizer 根据用户的基础角色和附加角色判断权限
+// 角色拥有的权限在 Load 时缓存，用户的附加角色每次检查时读取，分配或撤销后立即生效
+type Authorizer struct {
+	store Store
+
+	mu    sync.RWMutex
+	roles map[string]map[string]bool
+}
+
+func NewAuthorizer(store Store) *Authorizer {
+	return &Authorizer{store: store}
+}
+
+// Load 重新读取角色拥有的权限
+func (a *Authorizer) Load() error {
+	permissions, err := a.store.RolePermissions()
+	if err != nil {
+		return err
+	}
+	roles := make(map[string]map[string]bool, len(permissions))
+	for role, list := range permissions {
+		roles[role] = make(map[string]bool, len(list))
+		for _, p := range list {
+			roles[role][p] = true
+		}
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.roles = roles
+	return nil
+}
+
+// Can 判断用户是否拥有权限，未登录和被禁用的用户没有任何权限，读取角色失败时按没有权限处理
+func (a *Authorizer) Can(user model_user.UserSession, permission string) bool {
+	if user.UserRole == constant.Anonymous || user.UserRole == constant.Ban {
+		return false
+	}
+	if a.allowed(user.UserRole, permission) {
+		return true
+	}
+	roles, err := a.store.UserRoles(user.ID)
+	if err != nil {
+		log.Printf("query roles of user %s: %v", user.ID, err)
+		return false
+	}
+	for _, role := range roles {
+		if a.allowed(role, permission) {
+			return true
+		}
+	}
+	return false
+}
+
+// Permissions 用户拥有的全部权限，按名称排序
+func (a *Authorizer) Permissions(user model_user.UserSession) ([]string, error) {
+	if user.UserRole == constant.Anonymous || user.UserRole == constant.Ban {
+		return []string{}, nil
+	}
+	roles, err := a.store.UserRoles(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	set := make(map[string]bool)
+	for _, role := range append(roles, user.UserRole) {
+		for p := range a.roles[role] {
+			set[p] = true
+		}
+	}
+	permissions := make([]string, 0, len(set))
+	for p := range set {
+		permissions = append(permissions, p)
+	}
+	sort.Strings(permissions)
+	return permissions, nil
+}
+
+func (a *Authorizer) allowed(role, permission string) bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.roles[role][permission]
+}
@@ -0,0 +1,83 @@
+package rbac
+
+import (
+	"errors"
+	"github.com/xissg/userManageSystem/common/constant"
+	"github.com/xissg/userManageSystem/entity/model_user"
+	"reflect"
+	"testing"
+)
+
+type fakeStore struct {
+	assigned map[string][]string
+	err      error
+}
+
+func (s *fakeStore) RolePermissions() (map[string][]string, error) {
+	res := make(map[string][]string)
+	for _, role := range DefaultRoles {
+		res[role.Name] = role.Permissions
+	}
+	return res, nil
+}
+
+func (s *fakeStore) UserRoles(userId string) ([]string, error) {
+	return s.assigned[userId], s.err
+}
+
+func TestCan(t *testing.T) {
+	store := &fakeStore{assigned: map[string][]string{
+		"setter": {constant.ProblemSetter},
+		"banned": {constant.Moderator},
+		"staff":  {constant.JudgeOperator, constant.Moderator},
+	}}
+	a := NewAuthorizer(store)
+	if err := a.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		user       model_user.UserSession
+		permission string
+		want       bool
+	}{
+		{model_user.UserSession{}, constant.PermQuestionView, false},
+		{model_user.UserSession{ID: "u", UserRole: constant.Common}, constant.PermQuestionView, true},
+		{model_user.UserSession{ID: "u", UserRole: constant.Common}, constant.PermQuestionWrite, false},
+		{model_user.UserSession{ID: "setter", UserRole: constant.Common}, constant.PermQuestionWrite, true},
+		{model_user.UserSession{ID: "setter", UserRole: constant.Common}, constant.PermUserBan, false},
+		{model_user.UserSession{ID: "staff", UserRole: constant.Common}, constant.PermUserBan, true},
+		{model_user.UserSession{ID: "staff", UserRole: constant.Common}, constant.PermSubmitRequeue, true},
+		{model_user.UserSession{ID: "banned", UserRole: constant.Ban}, constant.PermUserBan, false},
+		{model_user.UserSession{ID: "a", UserRole: constant.Admin}, constant.PermRoleManage, true},
+	}
+	for _, tc := range cases {
+		if got := a.Can(tc.user, tc.permission); got != tc.want {
+			t.Errorf("Can(%s as %q, %s) = %v", tc.user.ID, tc.user.UserRole, tc.permission, got)
+		}
+	}
+
+	//读取附加角色失败时只使用基础角色
+	store.err = errors.New("connection refused")
+	if a.Can(model_user.UserSession{ID: "setter", UserRole: constant.Common}, constant.PermQuestionWrite) {
+		t.Error("store error should deny")
+	}
+	if !a.Can(model_user.UserSession{ID: "a", UserRole: constant.Admin}, constant.PermQuestionWrite) {
+		t.Error("base role should not need the store")
+	}
+}
+
+func TestPermissions(t *testing.T) {
+	a := NewAuthorizer(&fakeStore{assigned: map[string][]string{"u": {constant.Auditor}}})
+	if err := a.Load(); err != nil {
+		t.Fatal(err)
+	}
+	got, err := a.Permissions(model_user.UserSession{ID: "u", UserRole: constant.Common})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{constant.PermQuestionView, constant.PermQuestionViewAnswer, constant.PermSubmitCreate, constant.PermSubmitViewAll, constant.PermUserView}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("permissions = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,47 @@
+package redis
+
+import (
+	"context"
+	redigo "github.com/gomodule/redigo/redis"
+	"time"
+)
+
+// denylistKey 撤销令牌记录的键前缀
+const denylistKey = "token:deny:"
+
+// TokenDenylist 在redis中记录撤销的令牌id，记录随令牌一起过期
+type TokenDenylist struct {
+	pool *redigo.Pool
+}
+
+func NewTokenDenylist() *TokenDenylist {
+	return &TokenDenylist{pool: newPool()}
+}
+
+func (d *TokenDenylist) Deny(ctx context.Context, id string, ttl time.Duration) (bool, error) {
+	conn, err := d.pool.GetContext(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	//SET NX 保证同一令牌只有一次撤销成功
+	reply, err := conn.Do("SET", denylistKey+id, 1, "PX", ttl.Milliseconds(), "NX")
+	if err == redigo.ErrNil || (err == nil && reply == nil) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (d *TokenDenylist) Denied(ctx context.Context, id string) (bool, error) {
+	conn, err := d.pool.GetContext(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	return redigo.Bool(conn.Do("EXISTS", denylistKey+id))
+}
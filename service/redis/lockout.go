@@ -0,0 +1,80 @@
+package redis
+
+import (
+	"context"
+	redigo "github.com/gomodule/redigo/redis"
+	"time"
+)
+
+// 登录失败次数和锁定记录的键前缀
+const (
+	loginFailKey = "login:fail:"
+	loginLockKey = "login:lock:"
+)
+
+// LoginCounter 在redis中记录登录失败次数和锁定状态，记录到期后自动删除
+type LoginCounter struct {
+	pool *redigo.Pool
+}
+
+func NewLoginCounter() *LoginCounter {
+	return &LoginCounter{pool: newPool()}
+}
+
+func (lc *LoginCounter) Fail(ctx context.Context, key string, window time.Duration) (int, error) {
+	conn, err := lc.pool.GetContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	//锁定期间延长的过期时间不会被缩短
+	return redigo.Int(conn.Do("EVAL", `
+		local n = redis.call("INCR", KEYS[1])
+		if redis.call("PTTL", KEYS[1]) < tonumber(ARGV[1]) then
+			redis.call("PEXPIRE", KEYS[1], ARGV[1])
+		end
+		return n`, 1, loginFailKey+key, window.Milliseconds()))
+}
+
+func (lc *LoginCounter) Lock(ctx context.Context, key string, d, window time.Duration) error {
+	conn, err := lc.pool.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if err = conn.Send("MULTI"); err != nil {
+		return err
+	}
+	_ = conn.Send("SET", loginLockKey+key, 1, "PX", d.Milliseconds())
+	_ = conn.Send("PEXPIRE", loginFailKey+key, (d + window).Milliseconds())
+	_, err = conn.Do("EXEC")
+	return err
+}
+
+func (lc *LoginCounter) Locked(ctx context.Context, key string) (time.Duration, error) {
+	conn, err := lc.pool.GetContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	//键不存在时 PTTL 返回负数
+	ttl, err := redigo.Int64(conn.Do("PTTL", loginLockKey+key))
+	if err != nil || ttl <= 0 {
+		return 0, err
+	}
+	return time.Duration(ttl) * time.Millisecond, nil
+}
+
+func (lc *LoginCounter) Reset(ctx context.Context, key string) error {
+	conn, err := lc.pool.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.Do("DEL", loginFailKey+key, loginLockKey+key)
+	return err
+}
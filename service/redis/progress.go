@@ -0,0 +1,99 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	redigo "github.com/gomodule/redigo/redis"
+	"github.com/xissg/userManageSystem/service/progress"
+	"log"
+	"sync"
+)
+
+// progressChannel 判题进度使用的 pub/sub 频道前缀
+const progressChannel = "judge:progress:"
+
+// ProgressBroker 通过 redis pub/sub 分发判题进度，判题进程单独部署时也能送达服务进程中的订阅者
+type ProgressBroker struct {
+	pool *redigo.Pool
+}
+
+func NewProgressBroker() *ProgressBroker {
+	return &ProgressBroker{pool: newPool()}
+}
+
+func (b *ProgressBroker) Publish(ctx context.Context, event progress.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	conn, err := b.pool.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.Do("PUBLISH", progressChannel+event.SubmitId, data)
+	return err
+}
+
+// Subscribe 每个订阅者使用单独的连接，ctx 结束后取消订阅并归还连接
+func (b *ProgressBroker) Subscribe(ctx context.Context, submitId string) (<-chan progress.Event, error) {
+	conn, err := b.pool.GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	psc := redigo.PubSubConn{Conn: conn}
+	if err = psc.Subscribe(progressChannel + submitId); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	//等待订阅确认，之后发布的事件都能收到
+	if reply, ok := psc.Receive().(error); ok {
+		conn.Close()
+		return nil, reply
+	}
+
+	ch := progress.NewSubscription()
+	//Receive 与 Send 可以并发调用，关闭连接需要与取消订阅互斥
+	var mu sync.Mutex
+	closed := false
+	go func() {
+		<-ctx.Done()
+		mu.Lock()
+		defer mu.Unlock()
+		if !closed {
+			//Receive 收到取消订阅的确认后结束
+			_ = psc.Unsubscribe()
+		}
+	}()
+	go func() {
+		defer close(ch)
+		defer func() {
+			mu.Lock()
+			defer mu.Unlock()
+			closed = true
+			psc.Close()
+		}()
+		for {
+			switch v := psc.Receive().(type) {
+			case redigo.Message:
+				var event progress.Event
+				if err := json.Unmarshal(v.Data, &event); err != nil {
+					log.Printf("decode progress event %v", err)
+					continue
+				}
+				progress.Offer(ch, event)
+			case redigo.Subscription:
+				if v.Count == 0 {
+					return
+				}
+			case error:
+				if ctx.Err() == nil {
+					log.Printf("receive progress of submit %s: %v", submitId, v)
+				}
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
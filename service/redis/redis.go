@@ -3,7 +3,10 @@ package redis
 import (
 	"fmt"
 	redisstore "github.com/gin-contrib/sessions/redis"
+	redigo "github.com/gomodule/redigo/redis"
 	"github.com/spf13/viper"
+	"strconv"
+	"time"
 )
 
 type Config struct {
@@ -41,6 +44,20 @@ func InitRedisStore() redisstore.Store {
 	return store
 }
 
+// newPool 按 conf/redis.yaml 创建连接池，用于 session 以外的功能
+func newPool() *redigo.Pool {
+	config := readConfig("redis")
+	address := fmt.Sprintf("%s:%d", config.Host, config.Port)
+	database, _ := strconv.Atoi(config.Database)
+	return &redigo.Pool{
+		MaxIdle:     10,
+		IdleTimeout: 5 * time.Minute,
+		Dial: func() (redigo.Conn, error) {
+			return redigo.Dial("tcp", address, redigo.DialPassword(config.Password), redigo.DialDatabase(database))
+		},
+	}
+}
+
 //func initRedis() *redis.Client {
 //	config := readConfig("redis")
 //	address := fmt.Sprintf("%s:%d", config.Host, config.Port)
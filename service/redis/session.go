@@ -36,7 +36,7 @@ func (us *SessionService) NewOrUpdateSession(c *gin.Context, user model_user.Use
 	return nil
 }
 
-// GetSession 获取session
+// GetSession 获取session，控制器通过 middleware.CurrentUser 获取当前用户，包括 Bearer 令牌认证的用户
 func (us *SessionService) GetSession(c *gin.Context) (model_user.UserSession, error) {
 
 	session := sessions.Default(c)
@@ -0,0 +1,66 @@
+package token
+
+import (
+	"errors"
+	"github.com/spf13/viper"
+	"os"
+	"time"
+)
+
+// SecretEnv 签名密钥的环境变量，设置后覆盖 conf/token.yaml 中的 secret
+const SecretEnv = "TOKEN_SECRET"
+
+// minSecretLen HS256 密钥的最短长度，与签名的长度相同
+const minSecretLen = 32
+
+// placeholderSecret 旧版本配置文件中的示例密钥
+const placeholderSecret = "change-me-token-secret"
+
+// Config 令牌的签名密钥和有效期
+type Config struct {
+	Secret     string        `mapstructure:"secret"`
+	AccessTTL  time.Duration `mapstructure:"access_ttl"`
+	RefreshTTL time.Duration `mapstructure:"refresh_ttl"`
+}
+
+// DefaultConfig 未配置的有效期使用的默认值
+var DefaultConfig = Config{
+	AccessTTL:  15 * time.Minute,
+	RefreshTTL: 7 * 24 * time.Hour,
+}
+
+// LoadConfig 读取 conf/token.yaml，签名密钥优先从环境变量 TOKEN_SECRET 读取
+func LoadConfig() (Config, error) {
+	v := viper.New()
+	v.AddConfigPath("./conf")
+	v.SetConfigName("token")
+	v.SetConfigType("yaml")
+	if err := v.ReadInConfig(); err != nil {
+		return Config{}, err
+	}
+	config := DefaultConfig
+	if err := v.Unmarshal(&config); err != nil {
+		return Config{}, err
+	}
+	if secret := os.Getenv(SecretEnv); secret != "" {
+		config.Secret = secret
+	}
+	if err := checkSecret(config.Secret); err != nil {
+		return Config{}, err
+	}
+	return config, nil
+}
+
+// checkSecret 拒绝空的、过短的和示例密钥，使用公开的密钥时任何人都可以伪造令牌
+func checkSecret(secret string) error {
+	if secret == "" {
+		return errors.New("token secret is required, set " + SecretEnv)
+	}
+	if secret == placeholderSecret {
+		return errors.New("token secret is the example value, set " + SecretEnv)
+	}
+	if len(secret) < minSecretLen {
+		return errors.New("token secret must be at least 32 bytes")
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package token
+
+import (
+	"context"
+	"errors"
+	"github.com/xissg/userManageSystem/entity/model_user"
+	"github.com/xissg/userManageSystem/utils"
+	"time"
+)
+
+// 令牌的类型，访问令牌用于请求接口，刷新令牌只能用于换取新的令牌
+const (
+	TypeAccess  = "access"
+	TypeRefresh = "refresh"
+)
+
+var (
+	ErrExpired = errors.New("token expired")
+	ErrRevoked = errors.New("token revoked")
+	//刷新令牌只能使用一次，再次使用说明令牌可能已经泄露
+	ErrReused = errors.New("refresh token reused")
+)
+
+// Claims 令牌中保存的用户信息，与 session 中的用户信息相同
+type Claims struct {
+	model_user.UserSession
+	Type      string `json:"typ"`
+	ID        string `json:"jti"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// Denylist 记录已经撤销的令牌id，记录在令牌过期后可以删除
+type Denylist interface {
+	//Deny 撤销令牌，令牌之前已被撤销时返回 false
+	Deny(ctx context.Context, id string, ttl time.Duration) (bool, error)
+	Denied(ctx context.Context, id string) (bool, error)
+}
+
+// Service 签发和校验无状态的令牌，撤销的令牌记录在 Denylist 中
+type Service struct {
+	config   Config
+	denylist Denylist
+	now      func() time.Time
+}
+
+func NewService(config Config, denylist Denylist) *Service {
+	return &Service{config: config, denylist: denylist, now: time.Now}
+}
+
+// Issue 为用户签发访问令牌和刷新令牌
+func (s *Service) Issue(user model_user.UserSession) (model_user.TokenPair, error) {
+	access, err := s.sign(user, TypeAccess, s.config.AccessTTL)
+	if err != nil {
+		return model_user.TokenPair{}, err
+	}
+	refresh, err := s.sign(user, TypeRefresh, s.config.RefreshTTL)
+	if err != nil {
+		return model_user.TokenPair{}, err
+	}
+	return model_user.TokenPair{AccessToken: access, RefreshToken: refresh, ExpiresIn: int64(s.config.AccessTTL / time.Second)}, nil
+}
+
+func (s *Service) sign(user model_user.UserSession, typ string, ttl time.Duration) (string, error) {
+	now := s.now()
+	return utils.SignJWT(Claims{
+		UserSession: user,
+		Type:        typ,
+		ID:          utils.NewUuid(),
+		IssuedAt:    now.Unix(),
+		ExpiresAt:   now.Add(ttl).Unix(),
+	}, []byte(s.config.Secret))
+}
+
+// parse 校验签名、类型和过期时间，不检查是否已撤销
+func (s *Service) parse(token string, typ string) (Claims, error) {
+	var claims Claims
+	if err := utils.ParseJWT(token, []byte(s.config.Secret), &claims); err != nil {
+		return Claims{}, err
+	}
+	if claims.Type != typ || claims.ID == "" {
+		return Claims{}, utils.ErrInvalidToken
+	}
+	if s.now().Unix() >= claims.ExpiresAt {
+		return Claims{}, ErrExpired
+	}
+	return claims, nil
+}
+
+// Verify 校验访问令牌，返回令牌中的用户信息
+func (s *Service) Verify(ctx context.Context, token string) (model_user.UserSession, error) {
+	claims, err := s.parse(token, TypeAccess)
+	if err != nil {
+		return model_user.UserSession{}, err
+	}
+	denied, err := s.denylist.Denied(ctx, claims.ID)
+	if err != nil {
+		return model_user.UserSession{}, err
+	}
+	if denied {
+		return model_user.UserSession{}, ErrRevoked
+	}
+	return claims.UserSession, nil
+}
+
+// Revoke 撤销访问令牌或刷新令牌，已过期的令牌不需要撤销
+func (s *Service) Revoke(ctx context.Context, token string) error {
+	claims, err := s.parse(token, TypeAccess)
+	if err != nil {
+		claims, err = s.parse(token, TypeRefresh)
+	}
+	if errors.Is(err, ErrExpired) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	_, err = s.denylist.Deny(ctx, claims.ID, s.remaining(claims))
+	return err
+}
+
+// Refresh 使用刷新令牌换取新的一对令牌，旧的刷新令牌随之失效
+// load 根据令牌中的用户重新读取用户信息，用户被禁用或角色变化时由 load 返回错误或新的信息
+func (s *Service) Refresh(ctx context.Context, refreshToken string, load func(user model_user.UserSession) (model_user.UserSession, error)) (model_user.TokenPair, error) {
+	claims, err := s.parse(refreshToken, TypeRefresh)
+	if err != nil {
+		return model_user.TokenPair{}, err
+	}
+	//原子地撤销旧令牌，并发使用同一刷新令牌时只有一个请求成功
+	first, err := s.denylist.Deny(ctx, claims.ID, s.remaining(claims))
+	if err != nil {
+		return model_user.TokenPair{}, err
+	}
+	if !first {
+		return model_user.TokenPair{}, ErrReused
+	}
+	user, err := load(claims.UserSession)
+	if err != nil {
+		return model_user.TokenPair{}, err
+	}
+	return s.Issue(user)
+}
+
+// remaining 令牌剩余的有效期，撤销记录至少保留到令牌过期
+func (s *Service) remaining(claims Claims) time.Duration {
+	return time.Unix(claims.ExpiresAt, 0).Sub(s.now()) + time.Second
+}
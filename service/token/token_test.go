@@ -0,0 +1,145 @@
+package token
+
+import (
+	"context"
+	"errors"
+	"github.com/xissg/userManageSystem/entity/model_user"
+	"github.com/xissg/userManageSystem/utils"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryDenylist 测试使用的撤销记录
+type memoryDenylist struct {
+	mu  sync.Mutex
+	ids map[string]bool
+}
+
+func (d *memoryDenylist) Deny(ctx context.Context, id string, ttl time.Duration) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.ids[id] {
+		return false, nil
+	}
+	d.ids[id] = true
+	return true, nil
+}
+
+func (d *memoryDenylist) Denied(ctx context.Context, id string) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.ids[id], nil
+}
+
+func newTestService() (*Service, *time.Time) {
+	now := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	s := NewService(Config{Secret: "secret", AccessTTL: time.Minute, RefreshTTL: time.Hour}, &memoryDenylist{ids: make(map[string]bool)})
+	s.now = func() time.Time { return now }
+	return s, &now
+}
+
+var user = model_user.UserSession{ID: "1", UserAccount: "alice", UserRole: "common"}
+
+func TestVerify(t *testing.T) {
+	s, now := newTestService()
+	ctx := context.Background()
+	pair, err := s.Issue(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pair.ExpiresIn != 60 {
+		t.Errorf("expires in %d, want 60", pair.ExpiresIn)
+	}
+	got, err := s.Verify(ctx, pair.AccessToken)
+	if err != nil || got != user {
+		t.Fatalf("verify = %+v, %v", got, err)
+	}
+
+	//刷新令牌不能用于请求接口
+	if _, err = s.Verify(ctx, pair.RefreshToken); !errors.Is(err, utils.ErrInvalidToken) {
+		t.Errorf("refresh token used as access token: %v", err)
+	}
+	//篡改载荷后签名不匹配
+	parts := strings.Split(pair.AccessToken, ".")
+	forged, _ := utils.SignJWT(Claims{UserSession: model_user.UserSession{UserRole: "admin"}, Type: TypeAccess, ID: "x", ExpiresAt: now.Add(time.Hour).Unix()}, []byte("other"))
+	if _, err = s.Verify(ctx, parts[0]+"."+strings.Split(forged, ".")[1]+"."+parts[2]); !errors.Is(err, utils.ErrInvalidToken) {
+		t.Errorf("forged token accepted: %v", err)
+	}
+	if _, err = s.Verify(ctx, forged); !errors.Is(err, utils.ErrInvalidToken) {
+		t.Errorf("token signed with another secret accepted: %v", err)
+	}
+
+	*now = now.Add(time.Minute)
+	if _, err = s.Verify(ctx, pair.AccessToken); !errors.Is(err, ErrExpired) {
+		t.Errorf("expired token accepted: %v", err)
+	}
+}
+
+func TestRevoke(t *testing.T) {
+	s, _ := newTestService()
+	ctx := context.Background()
+	pair, _ := s.Issue(user)
+	if err := s.Revoke(ctx, pair.AccessToken); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Verify(ctx, pair.AccessToken); !errors.Is(err, ErrRevoked) {
+		t.Errorf("revoked token accepted: %v", err)
+	}
+
+	if err := s.Revoke(ctx, pair.RefreshToken); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Refresh(ctx, pair.RefreshToken, same); !errors.Is(err, ErrReused) {
+		t.Errorf("revoked refresh token accepted: %v", err)
+	}
+}
+
+func same(user model_user.UserSession) (model_user.UserSession, error) {
+	return user, nil
+}
+
+func TestRefreshRotation(t *testing.T) {
+	s, _ := newTestService()
+	ctx := context.Background()
+	pair, _ := s.Issue(user)
+
+	next, err := s.Refresh(ctx, pair.RefreshToken, func(u model_user.UserSession) (model_user.UserSession, error) {
+		u.UserRole = "admin"
+		return u, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next.RefreshToken == pair.RefreshToken {
+		t.Error("refresh token should be rotated")
+	}
+	if got, _ := s.Verify(ctx, next.AccessToken); got.UserRole != "admin" {
+		t.Errorf("new token should carry the reloaded user, got %+v", got)
+	}
+
+	//旧的刷新令牌只能使用一次
+	if _, err = s.Refresh(ctx, pair.RefreshToken, same); !errors.Is(err, ErrReused) {
+		t.Errorf("reused refresh token accepted: %v", err)
+	}
+	if _, err = s.Refresh(ctx, next.AccessToken, same); err == nil {
+		t.Error("access token accepted as refresh token")
+	}
+
+	banned := errors.New("banned")
+	if _, err = s.Refresh(ctx, next.RefreshToken, func(model_user.UserSession) (model_user.UserSession, error) { return model_user.UserSession{}, banned }); !errors.Is(err, banned) {
+		t.Errorf("load error should be returned, got %v", err)
+	}
+}
+
+func TestCheckSecret(t *testing.T) {
+	for _, secret := range []string{"", placeholderSecret, "short-secret"} {
+		if checkSecret(secret) == nil {
+			t.Errorf("secret %q should be rejected", secret)
+		}
+	}
+	if err := checkSecret(strings.Repeat("k", minSecretLen)); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,42 @@
+package utils
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type testClaims struct {
+	Sub string `json:"sub"`
+}
+
+func TestJWT(t *testing.T) {
+	secret := []byte("secret")
+	token, err := SignJWT(testClaims{Sub: "alice"}, secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var claims testClaims
+	if err = ParseJWT(token, secret, &claims); err != nil || claims.Sub != "alice" {
+		t.Fatalf("parse = %+v, %v", claims, err)
+	}
+
+	parts := strings.Split(token, ".")
+	none := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","typ":"JWT"}`))
+	forged := base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"admin"}`))
+	for name, bad := range map[string]string{
+		"wrong secret": token,
+		"tampered":     parts[0] + "." + forged + "." + parts[2],
+		"alg none":     none + "." + parts[1] + ".",
+		"malformed":    parts[0] + "." + parts[1],
+	} {
+		s := secret
+		if name == "wrong secret" {
+			s = []byte("other")
+		}
+		if err = ParseJWT(bad, s, &claims); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("%s: err = %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package utils
+
+import (
+	"crypto/md5"
+	"crypto/subtle"
+	"encoding/hex"
+	"golang.org/x/crypto/bcrypt"
+	"strings"
+	"sync"
+)
+
+// passwordCost bcrypt 的计算代价，提高后旧的哈希在登录时自动升级
+const passwordCost = 12
+
+// bcryptPrefix bcrypt 哈希的格式为 $2a$<cost>$<salt><hash>，算法和代价都保存在哈希中
+const bcryptPrefix = "$2"
+
+// HashPassword 使用加盐的 bcrypt 生成密码哈希
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), passwordCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// VerifyPassword 校验密码，兼容旧版本的 md5 哈希
+// rehash 为 true 表示哈希使用旧算法或较低的代价，密码正确时应重新生成哈希并保存
+func VerifyPassword(hash string, password string) (ok bool, rehash bool) {
+	if !strings.HasPrefix(hash, bcryptPrefix) {
+		ok = subtle.ConstantTimeCompare([]byte(legacyMD5(password)), []byte(hash)) == 1
+		return ok, ok
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil {
+		return false, false
+	}
+	cost, err := bcrypt.Cost([]byte(hash))
+	return true, err != nil || cost < passwordCost
+}
+
+// missingHash 账号不存在时用于校验的哈希，代价与新生成的哈希相同
+var missingHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("missing account"), passwordCost)
+	return hash
+})
+
+// VerifyMissingPassword 账号不存在时执行一次相同代价的校验，避免通过响应时间判断账号是否存在
+func VerifyMissingPassword(password string) {
+	_ = bcrypt.CompareHashAndPassword(missingHash(), []byte(password))
+}
+
+// legacyMD5 旧版本保存的密码哈希，为明文拼接其md5值后的十六进制编码，只用于校验和迁移旧账号
+func legacyMD5(plainText string) string {
+	hash := md5.New()
+	hash.Write([]byte(plainText))
+	cypher := hash.Sum([]byte(plainText))
+	return hex.EncodeToString(cypher)
+}
@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"golang.org/x/crypto/bcrypt"
+	"strings"
+	"testing"
+)
+
+func TestHashPassword(t *testing.T) {
+	hash, err := HashPassword("Passw0rd!")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(hash, bcryptPrefix) || strings.Contains(hash, "Passw0rd!") {
+		t.Fatalf("unexpected hash %s", hash)
+	}
+	if other, _ := HashPassword("Passw0rd!"); other == hash {
+		t.Error("hash should be salted")
+	}
+
+	if ok, rehash := VerifyPassword(hash, "Passw0rd!"); !ok || rehash {
+		t.Errorf("verify = %v, rehash = %v", ok, rehash)
+	}
+	if ok, _ := VerifyPassword(hash, "passw0rd!"); ok {
+		t.Error("wrong password accepted")
+	}
+}
+
+func TestVerifyLegacyPassword(t *testing.T) {
+	legacy := legacyMD5("Passw0rd!")
+	if ok, rehash := VerifyPassword(legacy, "Passw0rd!"); !ok || !rehash {
+		t.Errorf("legacy hash should verify and need rehash, got %v %v", ok, rehash)
+	}
+	if ok, rehash := VerifyPassword(legacy, "Passw0rd?"); ok || rehash {
+		t.Errorf("wrong password accepted for legacy hash")
+	}
+
+	//代价低于当前配置的哈希也需要升级
+	weak, err := bcrypt.GenerateFromPassword([]byte("Passw0rd!"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, rehash := VerifyPassword(string(weak), "Passw0rd!"); !ok || !rehash {
+		t.Errorf("low cost hash should need rehash, got %v %v", ok, rehash)
+	}
+}
@@ -1,21 +1,11 @@
 package utils
 
 import (
-	"crypto/md5"
-	"encoding/hex"
 	"github.com/google/uuid"
 	rands "math/rand"
 	"time"
 )
 
-// MD5Crypt 生成md5 hash
-func MD5Crypt(plainText string) string {
-	hash := md5.New()
-	hash.Write([]byte(plainText))
-	cypher := hash.Sum([]byte(plainText))
-	return hex.EncodeToString(cypher)
-}
-
 // RandomExpireTime 生成一个随机过期时间，过期时间至少为一天
 func RandomExpireTime() time.Duration {
 	rands.Seed(time.Now().UnixNano())